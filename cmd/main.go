@@ -3,9 +3,15 @@ package main
 import (
 	"log"
 	"synth.com/file_converter/internal/router"
+	"synth.com/file_converter/internal/service"
 )
 
 func main() {
+	// Install the bundled font used by PDF watermarks, headers and footers
+	if err := service.InstallFonts(); err != nil {
+		log.Fatal("Unable to install fonts:", err)
+	}
+
 	r := router.NewRouter()
 
 	// Start the server
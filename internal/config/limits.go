@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// Limits holds the resource limits applied to uploaded files
+type Limits struct {
+	MaxUploadBytes       int64 // Maximum size of a request body
+	MaxImagePixels       int64 // Maximum width*height of a decoded image
+	MaxPDFPages          int   // Maximum number of pages in an uploaded PDF
+	MaxSpreadsheetCells  int64 // Maximum number of cells read from a spreadsheet
+	MaxDecompressedBytes int64 // Maximum uncompressed size of a zip based document (DOCX, XLSX)
+}
+
+// Default limits, used when no environment override is set
+const (
+	DefaultMaxUploadBytes       = 32 << 20  // 32 MB
+	DefaultMaxImagePixels       = 100000000 // 100 megapixels
+	DefaultMaxPDFPages          = 1000
+	DefaultMaxSpreadsheetCells  = 5000000
+	DefaultMaxDecompressedBytes = 256 << 20 // 256 MB
+)
+
+// AppLimits are the limits used by the running service
+var AppLimits = LoadLimits()
+
+// LoadLimits reads the limits from the environment, falling back to the defaults
+func LoadLimits() Limits {
+	return Limits{
+		MaxUploadBytes:       envInt64("MAX_UPLOAD_BYTES", DefaultMaxUploadBytes),
+		MaxImagePixels:       envInt64("MAX_IMAGE_PIXELS", DefaultMaxImagePixels),
+		MaxPDFPages:          int(envInt64("MAX_PDF_PAGES", DefaultMaxPDFPages)),
+		MaxSpreadsheetCells:  envInt64("MAX_SPREADSHEET_CELLS", DefaultMaxSpreadsheetCells),
+		MaxDecompressedBytes: envInt64("MAX_DECOMPRESSED_BYTES", DefaultMaxDecompressedBytes),
+	}
+}
+
+// envInt64 returns the positive integer value of an environment variable or the fallback
+func envInt64(key string, fallback int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(key), 10, 64)
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestLoadLimits(t *testing.T) {
+	t.Setenv("MAX_UPLOAD_BYTES", "1024")
+	t.Setenv("MAX_IMAGE_PIXELS", "0")
+	t.Setenv("MAX_PDF_PAGES", "-5")
+	t.Setenv("MAX_SPREADSHEET_CELLS", "many")
+	t.Setenv("MAX_DECOMPRESSED_BYTES", "")
+
+	want := Limits{
+		MaxUploadBytes:       1024,
+		MaxImagePixels:       DefaultMaxImagePixels,
+		MaxPDFPages:          DefaultMaxPDFPages,
+		MaxSpreadsheetCells:  DefaultMaxSpreadsheetCells,
+		MaxDecompressedBytes: DefaultMaxDecompressedBytes,
+	}
+	if got := LoadLimits(); got != want {
+		t.Errorf("LoadLimits() = %+v, want %+v", got, want)
+	}
+}
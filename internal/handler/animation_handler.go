@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/service"
+)
+
+// AnimationHandler combines the uploaded frames, in upload order, into an animated GIF or WebP
+func AnimationHandler(c *gin.Context) {
+	log.Println("Received request for animation generation")
+
+	files, ok := parseUploadedFiles(c)
+	if !ok {
+		return
+	}
+
+	var opts model.AnimationOptions
+	if !bindOptions(c, &opts, "animation") {
+		return
+	}
+	if opts.Format == "" {
+		opts.Format = "gif"
+	}
+
+	resp := service.CreateAnimation(files, opts)
+	sendConvertedFile(c, resp, "animation."+opts.Format)
+}
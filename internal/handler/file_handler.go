@@ -1,9 +1,15 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"log"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
 	"synth.com/file_converter/internal/response"
 	"synth.com/file_converter/internal/service"
 )
@@ -14,11 +20,8 @@ func ConvertFileHandler(c *gin.Context) {
 	log.Println("Received request for file conversion")
 
 	// Parse the file from the form
-	file, header, err := c.Request.FormFile("file")
-	if err != nil {
-		// Log the error for debugging purposes
-		log.Println("Error parsing file:", err)
-		c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "Unable to parse the file"))
+	file, header, ok := parseUploadedFile(c)
+	if !ok {
 		return
 	}
 	defer file.Close()
@@ -30,18 +33,148 @@ func ConvertFileHandler(c *gin.Context) {
 		return
 	}
 
+	// Parse the optional conversion options
+	var opts model.ConvertOptions
+	if !bindOptions(c, &opts, "conversion") {
+		return
+	}
+	// Files to embed in generated PDFs are optional
+	opts.Attachments = formFiles(c, "attachments")
+	if images := formFiles(c, "watermark_image"); len(images) > 0 {
+		opts.WatermarkImage = &images[0]
+	}
+	opts.HeaderFooterOptions.Filename = header.Filename
+
 	// Call the service layer to handle file conversion
-	resp := service.ConvertFile(file, header.Filename, targetFormat)
+	resp := service.ConvertFile(file, header.Filename, targetFormat, opts)
+
+	// Send the converted file as the response
+	sendConvertedFile(c, resp, "converted_file."+targetFormat)
+}
 
+// parseUploadedFile reads the file uploaded under the "file" form field, writing an error response on failure
+func parseUploadedFile(c *gin.Context) (multipart.File, *multipart.FileHeader, bool) {
+	// Reject request bodies larger than the configured upload limit
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.AppLimits.MaxUploadBytes)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		// Log the error for debugging purposes
+		log.Println("Error parsing file:", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, response.NewErrorResponse(413, "Uploaded file exceeds the size limit"))
+			return nil, nil, false
+		}
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "Unable to parse the file"))
+		return nil, nil, false
+	}
+	return file, header, true
+}
+
+// parseUploadedFiles reads every file uploaded under the "files" form field, writing an error response on failure
+func parseUploadedFiles(c *gin.Context) ([]model.File, bool) {
+	// Reject request bodies larger than the configured upload limit
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.AppLimits.MaxUploadBytes)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		log.Println("Error parsing files:", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, response.NewErrorResponse(413, "Uploaded files exceed the size limit"))
+			return nil, false
+		}
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "Unable to parse the files"))
+		return nil, false
+	}
+
+	if len(form.File["files"]) == 0 {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "At least one file is required in the files field"))
+		return nil, false
+	}
+	return formFiles(c, "files"), true
+}
+
+// bindOptions binds the options of a request from the query and the multipart form fields, writing an error response
+// on failure. Form fields keep passwords and long values such as JSON out of URLs and logs. Binding runs after the
+// upload is parsed, so the multipart body is only ever read within the upload size limit.
+func bindOptions(c *gin.Context, opts any, name string) bool {
+	if err := c.ShouldBindWith(opts, binding.Form); err != nil {
+		c.JSON(http.StatusBadRequest, response.NewErrorResponse(400, "Invalid "+name+" options: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+// formFiles returns the files uploaded under a form field of an already parsed multipart form
+func formFiles(c *gin.Context, field string) []model.File {
+	if c.Request.MultipartForm == nil {
+		return nil
+	}
+
+	headers := c.Request.MultipartForm.File[field]
+	files := make([]model.File, 0, len(headers))
+	for _, header := range headers {
+		files = append(files, model.File{
+			Filename:    header.Filename,
+			Filetype:    header.Header.Get("Content-Type"),
+			Size:        header.Size,
+			FileContent: header,
+		})
+	}
+	return files
+}
+
+// sendConvertedFile writes the result of a conversion as a file download, or as JSON for structured results
+func sendConvertedFile(c *gin.Context, resp response.APIResponse, filename string) {
 	// Check if the conversion was successful
 	if resp.Code != 0 {
 		// Log the error from the service layer
 		log.Println("Error during conversion:", resp.Message)
-		c.JSON(http.StatusInternalServerError, resp)
+		c.JSON(resp.Code, resp)
 		return
 	}
 
-	// Send the converted file as the response
-	c.Header("Content-Disposition", "attachment; filename=converted_file."+targetFormat)
-	c.Data(http.StatusOK, "application/octet-stream", resp.Data.([]byte))
+	switch data := resp.Data.(type) {
+	case []byte:
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Data(http.StatusOK, "application/octet-stream", data)
+	case model.ConvertedFile:
+		for key, value := range data.Headers {
+			c.Header(key, value)
+		}
+		if data.Filename != "" {
+			filename = data.Filename
+		}
+		if data.Path != "" {
+			sendTempFile(c, data.Path, filename)
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Data(http.StatusOK, "application/octet-stream", data.Content)
+	default:
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// sendTempFile streams a conversion result from its temporary file and removes the file afterwards
+func sendTempFile(c *gin.Context, path, filename string) {
+	defer os.Remove(path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Println("Error reading converted file:", err)
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(http.StatusInternalServerError, "Failed to read converted file"))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		log.Println("Error reading converted file:", err)
+		c.JSON(http.StatusInternalServerError, response.NewErrorResponse(http.StatusInternalServerError, "Failed to read converted file"))
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.DataFromReader(http.StatusOK, info.Size(), "application/octet-stream", f, nil)
 }
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/service"
+)
+
+// ImageHashHandler computes perceptual hashes of the uploaded images and optionally compares them
+func ImageHashHandler(c *gin.Context) {
+	log.Println("Received request for image hashing")
+
+	files, ok := parseUploadedFiles(c)
+	if !ok {
+		return
+	}
+
+	var opts model.HashOptions
+	if !bindOptions(c, &opts, "hash") {
+		return
+	}
+
+	resp := service.HashImages(files, opts)
+	sendConvertedFile(c, resp, "")
+}
@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/service"
+)
+
+// MergePDFHandler merges the uploaded PDFs and images, in upload order, into one PDF
+func MergePDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF merge")
+
+	files, ok := parseUploadedFiles(c)
+	if !ok {
+		return
+	}
+
+	var opts model.MergeOptions
+	if !bindOptions(c, &opts, "merge") {
+		return
+	}
+
+	resp := service.MergePDFs(files, opts)
+	sendConvertedFile(c, resp, "merged.pdf")
+}
+
+// SplitPDFHandler splits the uploaded PDF by page ranges, into chunks or into single pages
+func SplitPDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF split")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.SplitOptions
+	if !bindOptions(c, &opts, "split") {
+		return
+	}
+
+	resp := service.SplitPDF(file, opts)
+	sendConvertedFile(c, resp, "split.pdf")
+}
+
+// EditPDFPagesHandler rotates, reorders, deletes and inserts pages of the uploaded PDF
+func EditPDFPagesHandler(c *gin.Context) {
+	log.Println("Received request for PDF page manipulation")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.PageOptions
+	if !bindOptions(c, &opts, "page") {
+		return
+	}
+
+	resp := service.EditPDFPages(file, opts)
+	sendConvertedFile(c, resp, "pages.pdf")
+}
+
+// WatermarkPDFHandler stamps the uploaded PDF with a text watermark or the image uploaded in the "image" field
+func WatermarkPDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF watermark")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.WatermarkOptions
+	if !bindOptions(c, &opts, "watermark") {
+		return
+	}
+
+	// The watermark image is optional, text is used without it
+	var stamp *model.File
+	if images := formFiles(c, "image"); len(images) > 0 {
+		stamp = &images[0]
+	}
+
+	resp := service.WatermarkPDF(file, stamp, opts)
+	sendConvertedFile(c, resp, "watermarked.pdf")
+}
+
+// EncryptPDFHandler protects the uploaded PDF with passwords and permissions
+func EncryptPDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF encryption")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.EncryptionOptions
+	if !bindOptions(c, &opts, "encryption") {
+		return
+	}
+
+	resp := service.EncryptPDF(file, opts)
+	sendConvertedFile(c, resp, "encrypted.pdf")
+}
+
+// DecryptPDFHandler removes the password and permission restrictions of the uploaded PDF
+func DecryptPDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF decryption")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.DecryptionOptions
+	if !bindOptions(c, &opts, "decryption") {
+		return
+	}
+
+	resp := service.DecryptPDF(file, opts)
+	sendConvertedFile(c, resp, "decrypted.pdf")
+}
+
+// OptimizePDFHandler shrinks the uploaded PDF and reports the original and optimized size in response headers
+func OptimizePDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF optimization")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.OptimizeOptions
+	if !bindOptions(c, &opts, "optimize") {
+		return
+	}
+
+	resp := service.OptimizePDF(file, opts)
+	sendConvertedFile(c, resp, "optimized.pdf")
+}
+
+// MetadataPDFHandler returns the metadata of the uploaded PDF as JSON, or the PDF with the given metadata fields updated
+func MetadataPDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF metadata")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.MetadataOptions
+	if !bindOptions(c, &opts, "metadata") {
+		return
+	}
+
+	resp := service.ProcessPDFMetadata(file, opts)
+	sendConvertedFile(c, resp, "metadata.pdf")
+}
+
+// FormPDFHandler returns the form fields of the uploaded PDF as JSON, or the PDF with its fields filled
+func FormPDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF form")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.FormOptions
+	if !bindOptions(c, &opts, "form") {
+		return
+	}
+
+	resp := service.ProcessPDFForm(file, opts)
+	sendConvertedFile(c, resp, "filled.pdf")
+}
+
+// AttachmentsPDFHandler lists the files embedded in the uploaded PDF, extracts one of them, or embeds the files
+// uploaded in the "attachments" field
+func AttachmentsPDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF attachments")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.AttachmentOptions
+	if !bindOptions(c, &opts, "attachment") {
+		return
+	}
+
+	resp := service.ProcessPDFAttachments(file, formFiles(c, "attachments"), opts)
+	sendConvertedFile(c, resp, "attachments.pdf")
+}
+
+// BookmarksPDFHandler returns the outline of the uploaded PDF as JSON, or the PDF with the given outline
+func BookmarksPDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF bookmarks")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.BookmarkOptions
+	if !bindOptions(c, &opts, "bookmark") {
+		return
+	}
+
+	resp := service.ProcessPDFBookmarks(file, opts)
+	sendConvertedFile(c, resp, "bookmarks.pdf")
+}
+
+// NUpPDFHandler lays out the pages of the uploaded PDF several to a sheet or as a booklet for printing
+func NUpPDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF N-up layout")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.ImposeOptions
+	if !bindOptions(c, &opts, "layout") {
+		return
+	}
+
+	resp := service.ImposePDF(file, opts)
+	sendConvertedFile(c, resp, "nup.pdf")
+}
+
+// HeaderFooterPDFHandler stamps a header and footer, such as page numbers, onto every page of the uploaded PDF
+func HeaderFooterPDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF header and footer")
+
+	file, header, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.HeaderFooterOptions
+	if !bindOptions(c, &opts, "header and footer") {
+		return
+	}
+	opts.Filename = header.Filename
+
+	resp := service.HeaderFooterPDF(file, opts)
+	sendConvertedFile(c, resp, "numbered.pdf")
+}
+
+// ValidatePDFHandler returns the issues found in the uploaded PDF as JSON, or in repair mode the rewritten PDF
+func ValidatePDFHandler(c *gin.Context) {
+	log.Println("Received request for PDF validation")
+
+	file, _, ok := parseUploadedFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var opts model.ValidateOptions
+	if !bindOptions(c, &opts, "validation") {
+		return
+	}
+
+	resp := service.ProcessPDFValidation(file, opts)
+	sendConvertedFile(c, resp, "repaired.pdf")
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/service"
+)
+
+// SpriteSheetHandler packs the uploaded images into a sprite sheet
+func SpriteSheetHandler(c *gin.Context) {
+	log.Println("Received request for sprite sheet generation")
+
+	files, ok := parseUploadedFiles(c)
+	if !ok {
+		return
+	}
+
+	var opts model.SpriteOptions
+	if !bindOptions(c, &opts, "sprite sheet") {
+		return
+	}
+
+	resp := service.CreateSpriteSheet(files, opts)
+	sendConvertedFile(c, resp, "sprite.zip")
+}
+
+// ContactSheetHandler renders the uploaded images as a captioned contact sheet
+func ContactSheetHandler(c *gin.Context) {
+	log.Println("Received request for contact sheet generation")
+
+	files, ok := parseUploadedFiles(c)
+	if !ok {
+		return
+	}
+
+	var opts model.ContactSheetOptions
+	if !bindOptions(c, &opts, "contact sheet") {
+		return
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = "png"
+	}
+	resp := service.CreateContactSheet(files, opts)
+	sendConvertedFile(c, resp, "contact_sheet."+format)
+}
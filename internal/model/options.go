@@ -0,0 +1,196 @@
+package model
+
+// SpriteOptions holds the query parameters of a sprite sheet request
+type SpriteOptions struct {
+	Format       string `form:"format" binding:"omitempty,oneof=png webp"`
+	Layout       string `form:"layout" binding:"omitempty,oneof=grid packed"`
+	Columns      int    `form:"columns" binding:"min=0,max=1000"`
+	Padding      int    `form:"padding" binding:"min=0,max=1000"`
+	SpriteWidth  uint   `form:"sprite_width" binding:"max=16384"`
+	SpriteHeight uint   `form:"sprite_height" binding:"max=16384"`
+	MaxWidth     int    `form:"max_width" binding:"min=0"`
+	MaxHeight    int    `form:"max_height" binding:"min=0"`
+}
+
+// ContactSheetOptions holds the query parameters of a contact sheet request
+type ContactSheetOptions struct {
+	Format    string  `form:"format" binding:"omitempty,oneof=png webp jpg"`
+	Columns   int     `form:"columns" binding:"min=0,max=1000"`
+	ThumbSize uint    `form:"thumb_size" binding:"max=4096"`
+	Padding   int     `form:"padding" binding:"min=0,max=1000"`
+	FontSize  float64 `form:"font_size" binding:"min=0,max=200"`
+}
+
+// ConvertOptions holds the optional query parameters of a file conversion request
+type ConvertOptions struct {
+	Placeholder string `form:"placeholder" binding:"omitempty,oneof=json headers"`
+	BlurHashX   int    `form:"blurhash_x" binding:"omitempty,min=1,max=9"`
+	BlurHashY   int    `form:"blurhash_y" binding:"omitempty,min=1,max=9"`
+	Colors      int    `form:"colors" binding:"omitempty,min=2,max=256"`
+	Quantizer   string `form:"quantizer" binding:"omitempty,oneof=median_cut octree"`
+	Dither      string `form:"dither" binding:"omitempty,oneof=none floyd_steinberg ordered"`
+	Monochrome  bool   `form:"monochrome"`
+	Threshold   *int   `form:"threshold" binding:"omitempty,min=0,max=255"`
+	TileSize    int    `form:"tile_size" binding:"omitempty,min=16,max=4096"`
+	TileOverlap *int   `form:"tile_overlap" binding:"omitempty,min=0,max=64"`
+	TileFormat  string `form:"tile_format" binding:"omitempty,oneof=jpg png webp"`
+	TileLayout  string `form:"tile_layout" binding:"omitempty,oneof=dzi xyz"`
+	Width       uint   `form:"width"`                                  // SVG raster width in pixels
+	Height      uint   `form:"height"`                                 // SVG raster height in pixels
+	DPI         int    `form:"dpi" binding:"omitempty,min=1,max=2400"` // SVG raster resolution, 96 is 1:1
+	Pages       string `form:"pages"`                                  // PDF page selection, e.g. 1-3,5
+	Layout      bool   `form:"layout"`                                 // PDF text in column reading order
+	Columns     string `form:"table_columns"`                          // PDF table column boundaries in points, e.g. 150,300 or 1:150,300;2-:120,260
+	ImageFormat string `form:"image_format" binding:"omitempty,oneof=jpg png webp gif avif"`
+	Title       string `form:"title"`  // Title of generated PDFs
+	Author      string `form:"author"` // Author of generated PDFs
+
+	// Watermark options stamp PDF output, such as images and Word documents converted to PDF
+	WatermarkOptions
+	// Header and footer options add page furniture such as page numbers to PDF output
+	HeaderFooterOptions
+	// Encryption options protect PDF output with passwords
+	EncryptionOptions
+	Password string `form:"password"` // Password of an encrypted PDF upload
+
+	// Attachments are files uploaded in the "attachments" field, embedded in generated PDFs
+	Attachments []File `form:"-"`
+	// WatermarkImage is the image uploaded in the "watermark_image" field, stamped on generated PDFs instead of text
+	WatermarkImage *File `form:"-"`
+}
+
+// HashOptions holds the query parameters of a perceptual hash request
+type HashOptions struct {
+	Compare   bool   `form:"compare"`
+	Algorithm string `form:"algorithm" binding:"omitempty,oneof=ahash dhash phash"`
+	Threshold *int   `form:"threshold" binding:"omitempty,min=0,max=64"`
+}
+
+// AnimationOptions holds the query parameters of an animation request
+type AnimationOptions struct {
+	Format string `form:"format" binding:"omitempty,oneof=gif webp"`
+	Delay  int    `form:"delay" binding:"min=0"` // Milliseconds per frame
+	Delays string `form:"delays"`                // Comma separated per-frame delays overriding Delay
+	Loop   int    `form:"loop" binding:"min=0"`  // Number of times to play, 0 loops forever
+	Width  uint   `form:"width"`
+	Height uint   `form:"height"`
+}
+
+// MergeOptions holds the query parameters of a PDF merge request
+type MergeOptions struct {
+	Bookmarks bool `form:"bookmarks"` // Add a bookmark per source file
+}
+
+// SplitOptions holds the query parameters of a PDF split request
+type SplitOptions struct {
+	Ranges string `form:"ranges"`                          // Page ranges, one output file each, e.g. 1-3,5,8-
+	Every  int    `form:"every" binding:"omitempty,min=1"` // Pages per output file when no ranges are given, 1 by default
+	Single bool   `form:"single"`                          // Extract the selected pages into one PDF
+}
+
+// PageOptions holds the query parameters of a PDF page manipulation request
+type PageOptions struct {
+	Ops []string `form:"op" binding:"required"` // Page operations applied in order, e.g. rotate:1-3:90
+}
+
+// WatermarkOptions holds the query parameters of a PDF text or image watermark
+type WatermarkOptions struct {
+	Text     string   `form:"watermark"`                                                            // Watermark text
+	Font     string   `form:"watermark_font"`                                                       // Font name, the bundled Arial by default
+	FontSize int      `form:"watermark_size" binding:"omitempty,min=1,max=500"`                     // Font size in points
+	Scale    float64  `form:"watermark_scale" binding:"omitempty,gt=0,max=1"`                       // Image width relative to the page
+	Color    string   `form:"watermark_color"`                                                      // Text colour as #rrggbb or rrggbb
+	Opacity  *float64 `form:"watermark_opacity" binding:"omitempty,min=0,max=1"`                    // 0 is invisible, 1 opaque
+	Rotation *float64 `form:"watermark_rotation" binding:"omitempty,min=-180,max=180"`              // Counterclockwise degrees, diagonal by default
+	Position string   `form:"watermark_position" binding:"omitempty,oneof=c tl tc tr l r bl bc br"` // Anchor on the page, centered by default
+	Pages    string   `form:"watermark_pages"`                                                      // PDF page selection, e.g. 1-3,5
+	Mode     string   `form:"watermark_mode" binding:"omitempty,oneof=stamp watermark"`             // stamp draws over the content, watermark behind it
+}
+
+// HeaderFooterOptions holds the query parameters of PDF headers and footers. The texts may contain the placeholders
+// {page}, {pages}, {filename} and {date}.
+type HeaderFooterOptions struct {
+	Header    string   `form:"header"`                                                   // Text at the top of every page
+	Footer    string   `form:"footer"`                                                   // Text at the bottom of every page
+	Font      string   `form:"header_font"`                                              // Font name, the bundled Arial by default
+	FontSize  int      `form:"header_size" binding:"omitempty,min=1,max=72"`             // Font size in points
+	Color     string   `form:"header_color"`                                             // Text colour as #rrggbb or rrggbb
+	Align     string   `form:"header_align" binding:"omitempty,oneof=left center right"` // Horizontal alignment, centered by default
+	Margin    *float64 `form:"header_margin" binding:"omitempty,min=0,max=200"`          // Distance from the page edge in points
+	SkipFirst bool     `form:"skip_first_page"`                                          // Leave the first page, such as a title page, without header and footer
+
+	// Filename is the name of the uploaded file, substituted for {filename}
+	Filename string `form:"-"`
+}
+
+// EncryptionOptions holds the passwords and permissions of a PDF encryption request
+type EncryptionOptions struct {
+	UserPassword  string `form:"user_password"`  // Password required to open the PDF
+	OwnerPassword string `form:"owner_password"` // Password lifting the permission restrictions, random when omitted
+	Permissions   string `form:"permissions"`    // Comma separated print, copy and modify, or all or none; print by default
+}
+
+// DecryptionOptions holds the password of a PDF decryption request
+type DecryptionOptions struct {
+	Password string `form:"password" binding:"required"` // User or owner password
+}
+
+// OptimizeOptions holds the query parameters of a PDF optimization request
+type OptimizeOptions struct {
+	DPI          int  `form:"dpi" binding:"omitempty,min=36,max=1200"`   // Image resolution to downsample to, 150 by default
+	Quality      int  `form:"quality" binding:"omitempty,min=1,max=100"` // JPEG quality of recompressed images, 75 by default
+	KeepMetadata bool `form:"keep_metadata"`                             // Keep the document information and XMP metadata
+}
+
+// MetadataOptions holds the query parameters of a PDF metadata request. Fields that are given replace the current
+// value, given empty they clear it; without any field the metadata is returned as JSON.
+type MetadataOptions struct {
+	Title        *string `form:"title"`
+	Author       *string `form:"author"`
+	Subject      *string `form:"subject"`
+	Keywords     *string `form:"keywords"`
+	Creator      *string `form:"creator"`
+	Producer     *string `form:"producer"`
+	CreationDate *string `form:"creation_date"` // RFC 3339 timestamp or date
+}
+
+// FormOptions holds the parameters of a PDF form request. Without fields or flattening the form fields are returned
+// as JSON.
+type FormOptions struct {
+	Fields  string `form:"fields"`  // JSON object mapping field names or ids to their new values
+	Flatten bool   `form:"flatten"` // Draw the fields into the page content and remove the form
+}
+
+// AttachmentOptions holds the parameters of a PDF attachment request
+type AttachmentOptions struct {
+	Name        string `form:"name"`        // Attachment to extract, by name
+	Description string `form:"description"` // Description of added attachments
+	Password    string `form:"password"`    // Password of an encrypted PDF
+}
+
+// BookmarkOptions holds the parameters of a PDF bookmark request. Without an outline the bookmarks are returned as JSON.
+type BookmarkOptions struct {
+	Outline string `form:"outline"` // JSON list of bookmarks with title, page and kids, or object mapping titles to pages
+}
+
+// ImposeOptions holds the parameters of an N-up or booklet layout request
+type ImposeOptions struct {
+	N        int      `form:"n" binding:"omitempty,oneof=2 3 4 6 8 9 12 16"` // Pages per sheet side, 2 by default; booklets take 2, 4, 6 or 8
+	Grid     string   `form:"grid"`                                          // Columns x rows, e.g. 3x2, instead of n
+	Sheet    string   `form:"sheet"`                                         // Paper size such as A4, A3L or Letter, or width x height in points; A4 by default
+	Margin   *float64 `form:"margin" binding:"omitempty,min=0,max=200"`      // Space around every page in points
+	Border   *bool    `form:"border"`                                        // Frame every page, on by default except for booklets
+	Order    string   `form:"order" binding:"omitempty,oneof=rd dr ld dl"`   // Grid fill order, right then down by default
+	Booklet  bool     `form:"booklet"`                                       // Saddle-stitch booklet page order
+	Binding  string   `form:"binding" binding:"omitempty,oneof=long short"`  // Booklet binding edge, long by default
+	Guides   bool     `form:"guides"`                                        // Draw booklet folding and cutting lines
+	Pages    string   `form:"pages"`                                         // PDF page selection, e.g. 1-3,5
+	Password string   `form:"password"`                                      // Password of an encrypted PDF
+}
+
+// ValidateOptions holds the parameters of a PDF validation request
+type ValidateOptions struct {
+	Mode     string `form:"mode" binding:"omitempty,oneof=relaxed strict"` // Validation the PDF must pass, relaxed by default
+	Repair   bool   `form:"repair"`                                        // Return the rewritten PDF instead of the issues
+	Password string `form:"password"`                                      // Password of an encrypted PDF
+}
@@ -0,0 +1,10 @@
+package model
+
+// ConvertedFile is a conversion result that is sent with additional response headers
+// or a download name that differs from the target format (e.g. a ZIP of tiles)
+type ConvertedFile struct {
+	Content  []byte
+	Path     string // Temporary file holding a large result instead of Content, removed once it has been sent
+	Filename string
+	Headers  map[string]string
+}
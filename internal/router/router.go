@@ -8,6 +8,24 @@ import (
 // NewRouter sets up the routes and returns a gin.Engine instance
 func NewRouter() *gin.Engine {
 	r := gin.Default()
-	r.POST("/convert", handler.ConvertFileHandler) // POST request for file conversion
+	r.POST("/convert", handler.ConvertFileHandler)           // POST request for file conversion
+	r.POST("/sprite", handler.SpriteSheetHandler)            // POST request for sprite sheet generation
+	r.POST("/contact-sheet", handler.ContactSheetHandler)    // POST request for contact sheet generation
+	r.POST("/hash", handler.ImageHashHandler)                // POST request for perceptual image hashing
+	r.POST("/animate", handler.AnimationHandler)             // POST request for animated GIF/WebP generation
+	r.POST("/merge", handler.MergePDFHandler)                // POST request for merging PDFs and images
+	r.POST("/split", handler.SplitPDFHandler)                // POST request for splitting a PDF
+	r.POST("/pages", handler.EditPDFPagesHandler)            // POST request for rotating, reordering, deleting and inserting PDF pages
+	r.POST("/watermark", handler.WatermarkPDFHandler)        // POST request for stamping text or image watermarks onto a PDF
+	r.POST("/encrypt", handler.EncryptPDFHandler)            // POST request for password protecting a PDF
+	r.POST("/decrypt", handler.DecryptPDFHandler)            // POST request for removing the password of a PDF
+	r.POST("/optimize", handler.OptimizePDFHandler)          // POST request for reducing the size of a PDF
+	r.POST("/metadata", handler.MetadataPDFHandler)          // POST request for reading or updating the metadata of a PDF
+	r.POST("/form", handler.FormPDFHandler)                  // POST request for listing or filling the form fields of a PDF
+	r.POST("/attachments", handler.AttachmentsPDFHandler)    // POST request for listing, extracting or adding the file attachments of a PDF
+	r.POST("/bookmarks", handler.BookmarksPDFHandler)        // POST request for reading or setting the outline of a PDF
+	r.POST("/nup", handler.NUpPDFHandler)                    // POST request for N-up and booklet layouts of a PDF
+	r.POST("/header-footer", handler.HeaderFooterPDFHandler) // POST request for stamping headers, footers and page numbers onto a PDF
+	r.POST("/validate", handler.ValidatePDFHandler)          // POST request for validating and repairing a PDF
 	return r
 }
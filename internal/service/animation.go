@@ -0,0 +1,422 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/chai2010/webp"
+	animwebp "github.com/gen2brain/webp"
+	"github.com/nfnt/resize"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// defaultFrameDelay is the delay between frames in milliseconds when none is given
+const defaultFrameDelay = 100
+
+// FrameInfo describes a single frame exploded from an animation
+type FrameInfo struct {
+	File  string `json:"file"`
+	Delay int    `json:"delay"` // Milliseconds
+}
+
+// CreateAnimation handles the animation request for an ordered set of uploaded frames
+func CreateAnimation(files []model.File, opts model.AnimationOptions) response.APIResponse {
+	data, err := ConvertImagesToAnimation(files, opts)
+	if err != nil {
+		return newConversionErrorResponse("Animation generation failed", err)
+	}
+	return response.NewSuccessResponse("Animation generated successfully", data)
+}
+
+// ConvertImagesToAnimation combines the frames, in upload order, into an animated GIF or WebP
+func ConvertImagesToAnimation(files []model.File, opts model.AnimationOptions) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, &utils.InvalidInputError{Msg: "no images uploaded"}
+	}
+
+	// Fit every frame onto a canvas of common dimensions, by default those of the first frame. Every frame is
+	// held at the canvas size, so the canvas is checked against the pixel limit before any upload is decoded.
+	first, err := uploadedImageConfig(files[0])
+	if err != nil {
+		return nil, err
+	}
+	width, height := resizedSize(first.Width, first.Height, opts.Width, opts.Height)
+	if err := checkImagePixels(width, height); err != nil {
+		return nil, err
+	}
+	if err := checkImagePixels(width*len(files), height); err != nil {
+		return nil, err
+	}
+
+	images, err := decodeUploadedImages(files, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	delays, err := frameDelays(len(images), opts.Delay, opts.Delays)
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]image.Image, len(images))
+	for i, img := range images {
+		frames[i] = fitFrame(img.Image, width, height)
+	}
+
+	if opts.Format == "webp" {
+		return encodeAnimatedWebP(frames, delays, opts.Loop)
+	}
+	return encodeAnimatedGIF(frames, delays, opts.Loop)
+}
+
+// uploadedImageConfig reads the dimensions of an uploaded image without decoding it
+func uploadedImageConfig(f model.File) (image.Config, error) {
+	file, err := f.FileContent.Open()
+	if err != nil {
+		return image.Config{}, fmt.Errorf("failed to open %s: %w", f.Filename, err)
+	}
+	defer file.Close()
+
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return image.Config{}, fmt.Errorf("%s: failed to decode image: %w", f.Filename, err)
+	}
+	return cfg, nil
+}
+
+// ConvertAnimationToFrames explodes an animated GIF or WebP into PNG frames, returned as a ZIP
+// together with a frames.json manifest of the frame delays
+func ConvertAnimationToFrames(file io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read animation: %w", err)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if err := checkImagePixels(cfg.Width, cfg.Height); err != nil {
+		return nil, err
+	}
+	// Every frame is composited onto a full canvas and kept until the ZIP is written, so the frames count
+	// against the pixel limit together
+	if err := checkImagePixels(cfg.Width*animationFrameCount(data, format), cfg.Height); err != nil {
+		return nil, err
+	}
+
+	var frames []image.Image
+	var delays []int
+	switch format {
+	case "gif":
+		frames, delays, err = decodeGIFFrames(data)
+	case "webp":
+		var anim *animwebp.WEBP
+		anim, err = animwebp.DecodeAll(bytes.NewReader(data))
+		if err == nil {
+			frames, delays = anim.Image, anim.Delay
+		}
+	default:
+		// Still images explode into a single frame
+		var img image.Image
+		img, _, err = decodeImage(bytes.NewReader(data))
+		frames, delays = []image.Image{img}, []int{0}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode animation: %w", err)
+	}
+
+	entries := make([]utils.ZipEntry, 0, len(frames)+1)
+	manifest := make([]FrameInfo, len(frames))
+	for i, frame := range frames {
+		frameData, err := encodeImage(frame, "png")
+		if err != nil {
+			return nil, err
+		}
+		manifest[i] = FrameInfo{File: fmt.Sprintf("frame_%04d.png", i+1), Delay: delays[i]}
+		entries = append(entries, utils.ZipEntry{Name: manifest[i].File, Data: frameData})
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write frame manifest: %w", err)
+	}
+	entries = append(entries, utils.ZipEntry{Name: "frames.json", Data: manifestData})
+	return utils.CreateZip(entries)
+}
+
+// frameDelays returns the delay of every frame in milliseconds
+func frameDelays(count, delay int, delays string) ([]int, error) {
+	if delay == 0 {
+		delay = defaultFrameDelay
+	}
+	result := make([]int, count)
+	for i := range result {
+		result[i] = delay
+	}
+	if delays == "" {
+		return result, nil
+	}
+
+	parts := strings.Split(delays, ",")
+	if len(parts) > count {
+		return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("%d delays given for %d frames", len(parts), count)}
+	}
+	for i, part := range parts {
+		d, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || d < 0 {
+			return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("invalid delay %q for frame %d", part, i+1)}
+		}
+		result[i] = d
+	}
+	return result, nil
+}
+
+// fitFrame scales a frame to fit the canvas while keeping its aspect ratio and centers it
+func fitFrame(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	if b.Dx() == width && b.Dy() == height {
+		return img
+	}
+
+	scaled := resize.Thumbnail(uint(width), uint(height), img, resize.Lanczos3)
+	if b.Dx() < width && b.Dy() < height {
+		// Thumbnail never enlarges, so scale smaller frames up explicitly
+		if float64(b.Dx())/float64(width) > float64(b.Dy())/float64(height) {
+			scaled = resize.Resize(uint(width), 0, img, resize.Lanczos3)
+		} else {
+			scaled = resize.Resize(0, uint(height), img, resize.Lanczos3)
+		}
+	}
+
+	canvas := image.NewNRGBA(image.Rect(0, 0, width, height))
+	sb := scaled.Bounds()
+	offset := image.Pt((width-sb.Dx())/2, (height-sb.Dy())/2)
+	draw.Draw(canvas, sb.Sub(sb.Min).Add(offset), scaled, sb.Min, draw.Src)
+	return canvas
+}
+
+// encodeAnimatedGIF quantizes every frame to its own palette and writes an animated GIF
+func encodeAnimatedGIF(frames []image.Image, delays []int, loop int) ([]byte, error) {
+	anim := &gif.GIF{LoopCount: gifLoopCount(loop)}
+	for i, frame := range frames {
+		palette := medianCutPalette(frame, 256)
+		anim.Image = append(anim.Image, ditherImage(frame, palette, "floyd_steinberg"))
+		// GIF delays are in hundredths of a second
+		anim.Delay = append(anim.Delay, (delays[i]+5)/10)
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		return nil, fmt.Errorf("failed to encode GIF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// gifLoopCount converts the number of plays to the GIF loop count, which counts repeats
+func gifLoopCount(loop int) int {
+	switch loop {
+	case 0:
+		return 0
+	case 1:
+		return -1
+	default:
+		return loop - 1
+	}
+}
+
+// encodeAnimatedWebP encodes every frame as a still WebP and assembles them into an animated WebP container
+func encodeAnimatedWebP(frames []image.Image, delays []int, loop int) ([]byte, error) {
+	canvas := frames[0].Bounds()
+
+	var body bytes.Buffer
+	hasAlpha := false
+	for i, frame := range frames {
+		var still bytes.Buffer
+		if err := webp.Encode(&still, frame, nil); err != nil {
+			return nil, fmt.Errorf("failed to encode frame %d: %w", i+1, err)
+		}
+
+		// Keep the bitstream chunks (ALPH, VP8, VP8L) of the still image for the animation frame
+		var frameData bytes.Buffer
+		for _, chunk := range riffChunks(still.Bytes()) {
+			switch chunk.id {
+			case "ALPH", "VP8 ", "VP8L":
+				if chunk.id != "VP8 " {
+					hasAlpha = true
+				}
+				writeRIFFChunk(&frameData, chunk.id, chunk.data)
+			}
+		}
+
+		b := frame.Bounds()
+		header := make([]byte, 16)
+		putUint24(header[0:], 0)          // X offset / 2
+		putUint24(header[3:], 0)          // Y offset / 2
+		putUint24(header[6:], b.Dx()-1)   // Frame width - 1
+		putUint24(header[9:], b.Dy()-1)   // Frame height - 1
+		putUint24(header[12:], delays[i]) // Duration in milliseconds
+		header[15] = 0x02                 // Do not blend, no disposal
+		writeRIFFChunk(&body, "ANMF", append(header, frameData.Bytes()...))
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02 // Animation
+	if hasAlpha {
+		vp8x[0] |= 0x10
+	}
+	putUint24(vp8x[4:], canvas.Dx()-1)
+	putUint24(vp8x[7:], canvas.Dy()-1)
+
+	anim := make([]byte, 6)
+	binary.LittleEndian.PutUint16(anim[4:], uint16(loop)) // Background colour stays transparent
+
+	var payload bytes.Buffer
+	payload.WriteString("WEBP")
+	writeRIFFChunk(&payload, "VP8X", vp8x)
+	writeRIFFChunk(&payload, "ANIM", anim)
+	payload.Write(body.Bytes())
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(payload.Len()))
+	out.Write(payload.Bytes())
+	return out.Bytes(), nil
+}
+
+// riffChunk is a single chunk of a RIFF container
+type riffChunk struct {
+	id   string
+	data []byte
+}
+
+// riffChunks splits a WebP file into its top-level chunks
+func riffChunks(data []byte) []riffChunk {
+	var chunks []riffChunk
+	for i := 12; i+8 <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := min(i+8+size, len(data))
+		chunks = append(chunks, riffChunk{id: string(data[i : i+4]), data: data[i+8 : end]})
+		// Chunks are padded to an even size
+		i = end + size%2
+	}
+	return chunks
+}
+
+func writeRIFFChunk(w *bytes.Buffer, id string, data []byte) {
+	w.WriteString(id)
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+	if len(data)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// animationFrameCount counts the frames of an animated GIF or WebP from its block structure, without decoding them
+func animationFrameCount(data []byte, format string) int {
+	count := 0
+	switch format {
+	case "gif":
+		count = gifFrameCount(data)
+	case "webp":
+		for _, chunk := range riffChunks(data) {
+			if chunk.id == "ANMF" {
+				count++
+			}
+		}
+	}
+	return max(count, 1)
+}
+
+// gifFrameCount counts the image descriptors of a GIF, skipping over colour tables, extensions and image data
+func gifFrameCount(data []byte) int {
+	// Header and logical screen descriptor, followed by the global colour table if present
+	if len(data) < 13 {
+		return 0
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	// skipSubBlocks returns the offset after a sequence of data sub-blocks ending with an empty one
+	skipSubBlocks := func(i int) int {
+		for i < len(data) && data[i] != 0 {
+			i += int(data[i]) + 1
+		}
+		return i + 1
+	}
+
+	count := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // Extension: label and sub-blocks
+			i = skipSubBlocks(i + 2)
+		case 0x2C: // Image descriptor, local colour table, LZW code size and image data
+			if i+10 > len(data) {
+				return count
+			}
+			count++
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			i = skipSubBlocks(i + 1)
+		default: // Trailer or garbage
+			return count
+		}
+	}
+	return count
+}
+
+// decodeGIFFrames composites the frames of an animated GIF onto its canvas, applying the disposal methods
+func decodeGIFFrames(data []byte) ([]image.Image, []int, error) {
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bounds := image.Rect(0, 0, anim.Config.Width, anim.Config.Height)
+	canvas := image.NewNRGBA(bounds)
+	frames := make([]image.Image, 0, len(anim.Image))
+	delays := make([]int, 0, len(anim.Image))
+	for i, frame := range anim.Image {
+		var previous *image.NRGBA
+		if anim.Disposal != nil && anim.Disposal[i] == gif.DisposalPrevious {
+			previous = image.NewNRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, image.Point{}, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		snapshot := image.NewNRGBA(bounds)
+		draw.Draw(snapshot, bounds, canvas, image.Point{}, draw.Src)
+		frames = append(frames, snapshot)
+		delays = append(delays, anim.Delay[i]*10)
+
+		if anim.Disposal != nil {
+			switch anim.Disposal[i] {
+			case gif.DisposalBackground:
+				draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+			case gif.DisposalPrevious:
+				canvas = previous
+			}
+		}
+	}
+	return frames, delays, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"math"
+	"testing"
+
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// testGIF encodes an animation of one-pixel frames on a canvas of the given size
+func testGIF(t *testing.T, width, height, frames int, localPalette bool) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{Config: image.Config{Width: width, Height: height, ColorModel: palette}}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(image.Rect(i%width, 0, i%width+1, 1), palette)
+		if localPalette {
+			frame.Palette = color.Palette{color.White, color.Black, color.Transparent}
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGIFFrameCount(t *testing.T) {
+	tests := []struct {
+		name         string
+		frames       int
+		localPalette bool
+	}{
+		{"single frame", 1, false},
+		{"animation", 25, false},
+		{"local colour tables", 7, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gifFrameCount(testGIF(t, 40, 30, tt.frames, tt.localPalette)); got != tt.frames {
+				t.Errorf("gifFrameCount() = %d, want %d", got, tt.frames)
+			}
+		})
+	}
+
+	if got := gifFrameCount([]byte("GIF89a")); got != 0 {
+		t.Errorf("gifFrameCount(truncated) = %d, want 0", got)
+	}
+}
+
+func TestConvertAnimationToFramesLimit(t *testing.T) {
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxImagePixels = 100 * 100 * 10
+
+	// Small frames on a large canvas are each exploded to the full canvas
+	_, err := ConvertAnimationToFrames(bytes.NewReader(testGIF(t, 100, 100, 11, false)))
+	var limitErr *utils.LimitExceededError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("ConvertAnimationToFrames() error = %v, want LimitExceededError", err)
+	}
+
+	if _, err := ConvertAnimationToFrames(bytes.NewReader(testGIF(t, 100, 100, 10, false))); err != nil {
+		t.Fatalf("ConvertAnimationToFrames() within the limit: %v", err)
+	}
+}
+
+func TestAnimationFrameCountWebP(t *testing.T) {
+	frames := make([]image.Image, 3)
+	for i := range frames {
+		frames[i] = image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	}
+	data, err := encodeAnimatedWebP(frames, []int{100, 100, 100}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := animationFrameCount(data, "webp"); got != 3 {
+		t.Errorf("animationFrameCount() = %d, want 3", got)
+	}
+	// Still images count as a single frame
+	if got := animationFrameCount([]byte{0x89, 'P', 'N', 'G'}, "png"); got != 1 {
+		t.Errorf("animationFrameCount(png) = %d, want 1", got)
+	}
+}
+
+func TestConvertImagesToAnimationLimits(t *testing.T) {
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxImagePixels = 10000
+
+	tests := []struct {
+		name          string
+		files         []model.File
+		width, height uint
+		wantErr       interface{}
+	}{
+		{"no uploads", nil, 0, 0, &utils.InvalidInputError{}},
+		{"within the limit", testUploads(t, image.Pt(50, 50), image.Pt(20, 30)), 0, 0, nil},
+		{"frames over the limit", testUploads(t, image.Pt(50, 50), image.Pt(10, 10), image.Pt(10, 10), image.Pt(10, 10), image.Pt(10, 10)), 0, 0, &utils.LimitExceededError{}},
+		// 2^32 squared wraps a 64-bit pixel count to zero
+		{"huge canvas", testUploads(t, image.Pt(10, 10)), 1 << 32, 1 << 32, &utils.LimitExceededError{}},
+		{"huge width", testUploads(t, image.Pt(10, 10)), math.MaxUint64, 0, &utils.LimitExceededError{}},
+		// The canvas is checked before the frames are decoded
+		{"canvas over the limit before decoding", append(testUploads(t, image.Pt(10, 10)), testUploadFiles(t, testFile{"b.png", []byte("not an image")})...), 200, 200, &utils.LimitExceededError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ConvertImagesToAnimation(tt.files, model.AnimationOptions{Width: tt.width, Height: tt.height})
+			if !errorIsType(err, tt.wantErr) {
+				t.Fatalf("ConvertImagesToAnimation() error = %v, want %T", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			anim, err := gif.DecodeAll(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(anim.Image) != len(tt.files) || anim.Config.Width != 50 || anim.Config.Height != 50 {
+				t.Errorf("animation has %d frames of %dx%d, want %d of 50x50", len(anim.Image), anim.Config.Width, anim.Config.Height, len(tt.files))
+			}
+		})
+	}
+}
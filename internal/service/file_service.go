@@ -219,6 +219,7 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"io"
@@ -228,18 +229,21 @@ import (
 	"strings"
 
 	"github.com/chai2010/webp"
+	"github.com/gen2brain/avif"
 	"github.com/nfnt/resize"
 	"github.com/signintech/gopdf"
-	"github.com/xuri/excelize/v2"
-	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"synth.com/file_converter/internal/model"
 	"synth.com/file_converter/internal/response"
 	"synth.com/file_converter/internal/utils"
 )
 
+// defaultFontPath is the bundled font used when rendering text
+const defaultFontPath = "assets/fonts/ARIAL.TTF"
+
 // ConvertFile handles the logic to convert the file based on target format
-func ConvertFile(file io.Reader, filename, targetFormat string) response.APIResponse {
+func ConvertFile(file io.Reader, filename, targetFormat string, opts model.ConvertOptions) response.APIResponse {
 	// List of valid formats
-	validFormats := []string{"pdf", "txt", "jpg", "png", "webp", "csv"}
+	validFormats := []string{"pdf", "txt", "json", "jpg", "png", "webp", "gif", "avif", "csv", "xlsx", "dzi", "frames", "images", "attachments"}
 	if !utils.Contains(validFormats, targetFormat) {
 		return response.NewErrorResponse(400, "Invalid target format")
 	}
@@ -249,11 +253,20 @@ func ConvertFile(file io.Reader, filename, targetFormat string) response.APIResp
 
 	// Process the file based on its extension
 	switch ext {
-	case ".png", ".jpg", ".jpeg", ".webp":
-		return handleImageConversion(file, targetFormat)
+	case ".svg":
+		if !utils.Contains(svgTargetFormats, targetFormat) {
+			return response.NewErrorResponse(400, "Unsupported target format for SVG")
+		}
+		return handleSVGConversion(file, targetFormat, opts)
+
+	case ".png", ".jpg", ".jpeg", ".webp", ".gif", ".avif", ".heic", ".heif":
+		if targetFormat == "pdf" {
+			return handleDefaultPDFConversion(file, filename, targetFormat, opts)
+		}
+		return handleImageConversion(file, targetFormat, opts)
 
 	case ".docx":
-		return handleWordToPDFConversion(file)
+		return handleWordToPDFConversion(file, opts)
 
 	case ".xlsx":
 		if targetFormat == "csv" {
@@ -262,31 +275,109 @@ func ConvertFile(file io.Reader, filename, targetFormat string) response.APIResp
 
 	case ".pdf":
 		if targetFormat == "txt" {
-			return handlePDFToTextConversion(file) // Handle PDF to Text conversion
+			return handlePDFToTextConversion(file, opts) // Handle PDF to Text conversion
+		}
+		if targetFormat == "json" {
+			return handlePDFToJSONConversion(file, opts)
+		}
+		if targetFormat == "images" {
+			return handlePDFImageExtraction(file, opts)
+		}
+		if targetFormat == "attachments" {
+			return handlePDFAttachmentExtraction(file, opts)
+		}
+		if targetFormat == "csv" || targetFormat == "xlsx" {
+			return handlePDFTableConversion(file, targetFormat, opts)
 		}
 
 	default:
-		return handleDefaultPDFConversion(file, filename, targetFormat)
+		return handleDefaultPDFConversion(file, filename, targetFormat, opts)
 	}
 
 	// Return error for unsupported formats
 	return response.NewErrorResponse(400, "Unsupported file format")
 }
 
-// handleImageConversion processes image files (png, jpg, jpeg, webp)
-func handleImageConversion(file io.Reader, targetFormat string) response.APIResponse {
-	data, err := ConvertImage(file, targetFormat)
+// handleImageConversion processes image files (png, jpg, jpeg, webp, gif, avif, heic)
+func handleImageConversion(file io.Reader, targetFormat string, opts model.ConvertOptions) response.APIResponse {
+	if targetFormat == "dzi" {
+		return handleTilePyramidConversion(file, opts)
+	}
+	if targetFormat == "frames" {
+		return handleFramesConversion(file)
+	}
+	if opts.Placeholder != "" {
+		return handleImagePlaceholderConversion(file, targetFormat, opts)
+	}
+
+	data, err := ConvertImage(file, targetFormat, opts)
 	if err != nil {
-		return response.NewErrorResponse(500, fmt.Sprintf("Image conversion failed: %s", err.Error()))
+		return newConversionErrorResponse("Image conversion failed", err)
 	}
 	return response.NewSuccessResponse("Image converted successfully", data)
 }
 
+// handleImagePlaceholderConversion converts an image and adds its placeholders as JSON or response headers
+func handleImagePlaceholderConversion(file io.Reader, targetFormat string, opts model.ConvertOptions) response.APIResponse {
+	img, _, err := decodeImage(file)
+	if err != nil {
+		return newConversionErrorResponse("Image conversion failed", err)
+	}
+
+	data, err := convertDecodedImage(img, targetFormat, opts)
+	if err != nil {
+		return newConversionErrorResponse("Image conversion failed", err)
+	}
+
+	placeholder, err := GenerateImagePlaceholder(img, opts.BlurHashX, opts.BlurHashY)
+	if err != nil {
+		return newConversionErrorResponse("Image placeholder generation failed", err)
+	}
+
+	if opts.Placeholder == "headers" {
+		return response.NewSuccessResponse("Image converted successfully", model.ConvertedFile{Content: data, Headers: placeholder.Headers()})
+	}
+	return response.NewSuccessResponse("Image converted successfully", ImageConversionResult{Image: data, Format: targetFormat, Placeholder: placeholder})
+}
+
+// handleTilePyramidConversion processes image conversion to a ZIP of deep zoom tiles
+func handleTilePyramidConversion(file io.Reader, opts model.ConvertOptions) response.APIResponse {
+	path, err := ConvertImageToTilePyramid(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("Tile pyramid generation failed", err)
+	}
+	return response.NewSuccessResponse("Tile pyramid generated successfully", model.ConvertedFile{Path: path, Filename: "tiles.zip"})
+}
+
+// handleSVGConversion processes SVG files by rasterizing them
+func handleSVGConversion(file io.Reader, targetFormat string, opts model.ConvertOptions) response.APIResponse {
+	data, err := ConvertSVG(file, targetFormat, opts)
+	if err == nil && targetFormat == "pdf" {
+		data, err = finishGeneratedPDF(data, opts)
+	}
+	if err != nil {
+		return newConversionErrorResponse("SVG conversion failed", err)
+	}
+	return response.NewSuccessResponse("SVG converted successfully", data)
+}
+
+// handleFramesConversion processes animated images into a ZIP of their frames
+func handleFramesConversion(file io.Reader) response.APIResponse {
+	data, err := ConvertAnimationToFrames(file)
+	if err != nil {
+		return newConversionErrorResponse("Frame extraction failed", err)
+	}
+	return response.NewSuccessResponse("Frames extracted successfully", model.ConvertedFile{Content: data, Filename: "frames.zip"})
+}
+
 // handleWordToPDFConversion processes Word document conversion to PDF
-func handleWordToPDFConversion(file io.Reader) response.APIResponse {
+func handleWordToPDFConversion(file io.Reader, opts model.ConvertOptions) response.APIResponse {
 	data, err := ConvertWordToPDF(file)
+	if err == nil {
+		data, err = finishGeneratedPDF(data, opts)
+	}
 	if err != nil {
-		return response.NewErrorResponse(500, fmt.Sprintf("Word to PDF conversion failed: %s", err.Error()))
+		return newConversionErrorResponse("Word to PDF conversion failed", err)
 	}
 	return response.NewSuccessResponse("Word document converted to PDF successfully", data)
 }
@@ -295,77 +386,133 @@ func handleWordToPDFConversion(file io.Reader) response.APIResponse {
 func handleExcelToCSVConversion(file io.Reader) response.APIResponse {
 	data, err := ConvertExcelToCSV(file)
 	if err != nil {
-		return response.NewErrorResponse(500, fmt.Sprintf("Excel to CSV conversion failed: %s", err.Error()))
+		return newConversionErrorResponse("Excel to CSV conversion failed", err)
 	}
 	return response.NewSuccessResponse("Excel document converted to CSV successfully", data)
 }
 
 // handlePDFToTextConversion processes PDF to text conversion
-func handlePDFToTextConversion(file io.Reader) response.APIResponse {
-	data, err := ConvertPDFToText(file)
+func handlePDFToTextConversion(file io.Reader, opts model.ConvertOptions) response.APIResponse {
+	data, err := ConvertPDFToText(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF to text conversion failed", err)
+	}
+	return response.NewSuccessResponse("PDF text extracted successfully", []byte(data))
+}
+
+// handlePDFToJSONConversion processes PDF files into their text page by page with positioned text runs
+func handlePDFToJSONConversion(file io.Reader, opts model.ConvertOptions) response.APIResponse {
+	result, err := ExtractPDFText(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF to JSON conversion failed", err)
+	}
+	return response.NewSuccessResponse("PDF text extracted successfully", result)
+}
+
+// handlePDFImageExtraction processes PDF files into a ZIP of their embedded images
+func handlePDFImageExtraction(file io.Reader, opts model.ConvertOptions) response.APIResponse {
+	data, err := ExtractPDFImages(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF image extraction failed", err)
+	}
+	return response.NewSuccessResponse("PDF images extracted successfully", model.ConvertedFile{Content: data, Filename: "images.zip"})
+}
+
+// handlePDFAttachmentExtraction processes PDF files into a ZIP of their embedded files
+func handlePDFAttachmentExtraction(file io.Reader, opts model.ConvertOptions) response.APIResponse {
+	data, err := ExtractPDFAttachments(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF attachment extraction failed", err)
+	}
+	return response.NewSuccessResponse("PDF attachments extracted successfully", model.ConvertedFile{Content: data, Filename: "attachments.zip"})
+}
+
+// handlePDFTableConversion processes PDF files into the rows and columns of their text as CSV or XLSX
+func handlePDFTableConversion(file io.Reader, targetFormat string, opts model.ConvertOptions) response.APIResponse {
+	data, err := ConvertPDFToTable(file, targetFormat, opts)
 	if err != nil {
-		return response.NewErrorResponse(500, fmt.Sprintf("PDF to text conversion failed: %s", err.Error()))
+		return newConversionErrorResponse("PDF table extraction failed", err)
 	}
-	return response.NewSuccessResponse("PDF text extracted successfully", data)
+	return response.NewSuccessResponse("PDF tables extracted successfully", data)
 }
 
 // handleDefaultPDFConversion processes file conversion to PDF for unsupported formats
-func handleDefaultPDFConversion(file io.Reader, filename, targetFormat string) response.APIResponse {
+func handleDefaultPDFConversion(file io.Reader, filename, targetFormat string, opts model.ConvertOptions) response.APIResponse {
 	if targetFormat == "pdf" {
 		data, err := ConvertToPDF(file, filename)
+		if err == nil {
+			data, err = finishGeneratedPDF(data, opts)
+		}
 		if err != nil {
-			return response.NewErrorResponse(500, fmt.Sprintf("PDF conversion failed: %s", err.Error()))
+			return newConversionErrorResponse("PDF conversion failed", err)
 		}
 		return response.NewSuccessResponse("PDF generated successfully", data)
 	}
 	return response.NewErrorResponse(400, "Unsupported file format for PDF conversion")
 }
 
-// ConvertPDFToText extracts text from a PDF file
-func ConvertPDFToText(file io.Reader) (string, error) {
-	// Create a temporary file to store the input PDF
-	tmpInput, err := os.CreateTemp("", "input-*.pdf")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer os.Remove(tmpInput.Name())
+// newConversionErrorResponse creates an error response whose code reflects the kind of failure
+func newConversionErrorResponse(message string, err error) response.APIResponse {
+	return response.NewErrorResponse(utils.StatusCodeForError(err), fmt.Sprintf("%s: %s", message, err.Error()))
+}
 
-	// Copy the input PDF to the temporary file
-	_, err = io.Copy(tmpInput, file)
+// ConvertPDFToText extracts text from the selected pages of a PDF file, decrypting it with the password if it is encrypted
+func ConvertPDFToText(file io.Reader, opts model.ConvertOptions) (string, error) {
+	result, err := ExtractPDFText(file, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to copy input to temp file: %w", err)
+		return "", err
 	}
-	tmpInput.Close()
 
-	// Extract text from the PDF using pdfcpu
 	var buf bytes.Buffer
-	err = api.ExtractText(tmpInput.Name(), &buf)
-	if err != nil {
-		return "", fmt.Errorf("failed to extract text from PDF: %w", err)
+	for _, page := range result.Pages {
+		buf.WriteString(page.Text)
 	}
 
 	// Return the extracted text as a string
 	return buf.String(), nil
 }
 
-// ConvertImage converts an image file to the target format (PNG, JPEG, or WebP)
-func ConvertImage(file io.Reader, targetFormat string) ([]byte, error) {
-	img, _, err := image.Decode(file)
+// ConvertImage converts an image file to the target format (PNG, JPEG, WebP or GIF)
+func ConvertImage(file io.Reader, targetFormat string, opts model.ConvertOptions) ([]byte, error) {
+	img, _, err := decodeImage(file)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		return nil, err
+	}
+	return convertDecodedImage(img, targetFormat, opts)
+}
+
+// convertDecodedImage resizes a decoded image, reduces its palette if requested and encodes it in the target format
+func convertDecodedImage(img image.Image, targetFormat string, opts model.ConvertOptions) ([]byte, error) {
+	if err := checkQuantizeFormat(targetFormat, opts); err != nil {
+		return nil, err
 	}
 
 	// Resize image while maintaining aspect ratio
-	resizedImg := resize.Resize(800, 0, img, resize.Lanczos3)
+	var resizedImg image.Image = resize.Resize(800, 0, img, resize.Lanczos3)
 
+	// Indexed output is written as PNG-8 (1-bit for monochrome) or GIF by the encoders
+	if opts.Colors > 0 || opts.Monochrome {
+		resizedImg = quantizeImage(resizedImg, opts)
+	}
+
+	return encodeImage(resizedImg, targetFormat)
+}
+
+// encodeImage encodes an image in the target format (PNG, JPEG, WebP, GIF or AVIF)
+func encodeImage(img image.Image, targetFormat string) ([]byte, error) {
 	var buf bytes.Buffer
+	var err error
 	switch targetFormat {
 	case "png":
-		err = png.Encode(&buf, resizedImg)
+		err = png.Encode(&buf, img)
 	case "webp":
-		err = webp.Encode(&buf, resizedImg, nil)
+		err = webp.Encode(&buf, img, nil)
 	case "jpg":
-		err = jpeg.Encode(&buf, resizedImg, nil)
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	case "avif":
+		err = avif.Encode(&buf, img)
 	default:
 		return nil, fmt.Errorf("unsupported image format")
 	}
@@ -376,8 +523,14 @@ func ConvertImage(file io.Reader, targetFormat string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// ConvertWordToPDF converts a Word document to a PDF document
+// ConvertWordToPDF converts a Word document to a PDF document with an outline of its headings
 func ConvertWordToPDF(file io.Reader) ([]byte, error) {
+	// Read the document, rejecting decompression bombs before handing it to pandoc
+	data, err := readZipDocument(file)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a temporary file to store the input
 	tmpInput, err := os.CreateTemp("", "input-*.docx")
 	if err != nil {
@@ -386,17 +539,24 @@ func ConvertWordToPDF(file io.Reader) ([]byte, error) {
 	defer os.Remove(tmpInput.Name())
 
 	// Copy the input to the temporary file
-	_, err = io.Copy(tmpInput, file)
+	_, err = tmpInput.Write(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to copy input to temp file: %w", err)
 	}
 	tmpInput.Close()
 
+	// Headings of the document become the outline of the PDF
+	headings, err := docxHeadings(data)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize PDF document
 	var buf bytes.Buffer
 	pdf := gopdf.GoPdf{}
+	pageSize := gopdf.Rect{W: 595.28, H: 841.89} // A4 size
 	pdf.Start(gopdf.Config{
-		PageSize: gopdf.Rect{W: 595.28, H: 841.89}, // A4 size
+		PageSize: pageSize,
 		Unit:     gopdf.Unit_PT,
 	})
 
@@ -409,7 +569,7 @@ func ConvertWordToPDF(file io.Reader) ([]byte, error) {
 
 	// Add content to PDF
 	pdf.AddPage()
-	err = pdf.AddTTFFont("default", "assets/fonts/Arial.ttf")
+	err = pdf.AddTTFFont("default", defaultFontPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add font: %w", err)
 	}
@@ -419,10 +579,17 @@ func ConvertWordToPDF(file io.Reader) ([]byte, error) {
 		return nil, fmt.Errorf("failed to set font: %w", err)
 	}
 
-	// Split content into paragraphs and add to PDF
+	// Split content into paragraphs and add to PDF, continuing on a new page once a page is full
+	outline := &outlineBuilder{headings: headings}
+	pageNr := 1
 	paragraphs := strings.Split(string(output), "\n\n")
 	for _, para := range paragraphs {
 		if strings.TrimSpace(para) != "" {
+			if pdf.GetY()+20 > pageSize.H {
+				pdf.AddPage()
+				pageNr++
+			}
+			outline.match(para, pageNr)
 			pdf.Cell(nil, para)
 			pdf.Br(20)
 		}
@@ -433,15 +600,20 @@ func ConvertWordToPDF(file io.Reader) ([]byte, error) {
 		return nil, fmt.Errorf("failed to write PDF: %w", err)
 	}
 
-	return buf.Bytes(), nil
+	return addPDFOutline(buf.Bytes(), outline.bookmarks())
 }
 
 // ConvertExcelToCSV converts an Excel document to CSV format
 func ConvertExcelToCSV(file io.Reader) ([]byte, error) {
-	xl, err := excelize.OpenReader(file)
+	xl, err := openSpreadsheet(file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read Excel document: %w", err)
 	}
+	defer xl.Close()
+
+	if err := checkSpreadsheetCells(xl); err != nil {
+		return nil, err
+	}
 
 	var buf bytes.Buffer
 	for _, sheetName := range xl.GetSheetList() {
@@ -460,11 +632,15 @@ func ConvertExcelToCSV(file io.Reader) ([]byte, error) {
 
 // ConvertToPDF converts an image to a PDF document
 func ConvertToPDF(file io.Reader, filename string) ([]byte, error) {
-	img, _, err := image.Decode(file)
+	img, _, err := decodeImage(file)
 	if err != nil {
-		return nil, fmt.Errorf("image decode failed: %w", err)
+		return nil, err
 	}
+	return imageToPDF(img, filename)
+}
 
+// imageToPDF places a decoded image on a PDF page
+func imageToPDF(img image.Image, filename string) ([]byte, error) {
 	tmpFile, err := utils.SaveImageToTempFile(img, filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to save image to temp file: %w", err)
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/signintech/gopdf"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// TestMain runs the tests from the repository root, where the bundled assets are found, with the fonts installed
+// as at startup. The pdfcpu configuration and the installed fonts go to a temporary directory rather than the
+// configuration directory of the user.
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		log.Fatal(err)
+	}
+	configDir, err := os.MkdirTemp("", "pdfcpu-test-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := pdfmodel.EnsureDefaultConfigAt(configDir, false); err != nil {
+		log.Fatal(err)
+	}
+	if err := InstallFonts(); err != nil {
+		log.Fatal(err)
+	}
+	code := m.Run()
+	os.RemoveAll(configDir)
+	os.Exit(code)
+}
+
+// testFile is the name and content of a file uploaded in a test
+type testFile struct {
+	name string
+	data []byte
+}
+
+// testUploadFiles builds multipart uploads of the given files, in order
+func testUploadFiles(t *testing.T, files ...testFile) []model.File {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range files {
+		w, err := mw.CreateFormFile("files", f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(f.data)
+	}
+	mw.Close()
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	var uploads []model.File
+	for _, header := range req.MultipartForm.File["files"] {
+		uploads = append(uploads, model.File{Filename: header.Filename, FileContent: header})
+	}
+	return uploads
+}
+
+// testPDF generates a PDF of A4 pages with a line each, as pdfcpu rejects pages without content
+func testPDF(t *testing.T, pages int) []byte {
+	t.Helper()
+	pdf := gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
+	for i := 0; i < pages; i++ {
+		pdf.AddPage()
+		pdf.Line(50, 50, 100, 100)
+	}
+	data, err := pdf.GetBytesPdfReturnErr()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// errorIsType reports whether err wraps an error of the type of target, one of the utils error types
+func errorIsType(err error, target interface{}) bool {
+	switch target.(type) {
+	case *utils.InvalidInputError:
+		var e *utils.InvalidInputError
+		return errors.As(err, &e)
+	case *utils.LimitExceededError:
+		var e *utils.LimitExceededError
+		return errors.As(err, &e)
+	case *utils.CorruptFileError:
+		var e *utils.CorruptFileError
+		return errors.As(err, &e)
+	}
+	return err == nil
+}
+
+// buildPDF writes a PDF of the given objects, numbered from 1 with the first being the catalog. Objects given as
+// two strings are streams of the dictionary and the content, whose length is filled in.
+func buildPDF(objects ...[]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		if len(obj) == 2 {
+			fmt.Fprintf(&buf, "<< %s /Length %d >>\nstream\n%s\nendstream", obj[0], len(obj[1]), obj[1])
+		} else {
+			buf.WriteString(obj[0])
+		}
+		buf.WriteString("\nendobj\n")
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"io"
+
+	"github.com/gen2brain/avif"
+	"github.com/gen2brain/heic"
+)
+
+// The AVIF and HEIC decoders run libavif and libheif compiled to WebAssembly, so no system libraries are needed.
+// Importing the packages registers them with image.Decode; HEIC only registers the plain "heic" brand,
+// so the 10-bit "heix" brand written by newer phones and the generic HEIF brands "mif1" and "msf1" are added here.
+func init() {
+	image.RegisterFormat("heic", "????ftypheix", heic.Decode, heic.DecodeConfig)
+	for _, brand := range []string{"mif1", "msf1"} {
+		image.RegisterFormat("heif", "????ftyp"+brand, decodeHEIF, decodeHEIFConfig)
+	}
+}
+
+// decodeHEIF decodes an image with a generic HEIF brand, which AVIF encoders use as well
+func decodeHEIF(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data, isAVIF := heifCodec(data)
+	if isAVIF {
+		return avif.Decode(bytes.NewReader(data))
+	}
+	return heic.Decode(bytes.NewReader(data))
+}
+
+// decodeHEIFConfig reads the dimensions of an image with a generic HEIF brand
+func decodeHEIFConfig(r io.Reader) (image.Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	data, isAVIF := heifCodec(data)
+	if isAVIF {
+		return avif.DecodeConfig(bytes.NewReader(data))
+	}
+	return heic.DecodeConfig(bytes.NewReader(data))
+}
+
+// heifCodec tells AVIF from HEVC coded HEIF files by the compatible brands of their ftyp box. libheif only decodes
+// files whose major brand names the codec, so the HEVC brand found is copied over the generic major brand.
+func heifCodec(data []byte) ([]byte, bool) {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return data, false
+	}
+	size := min(int(binary.BigEndian.Uint32(data[:4])), len(data))
+	// The major brand and minor version are followed by the compatible brands
+	for i := 16; i+4 <= size; i += 4 {
+		switch brand := string(data[i : i+4]); brand {
+		case "avif", "avis":
+			return data, true
+		case "heic", "heix", "heim", "heis", "hevc", "hevx", "hevm", "hevs":
+			patched := append([]byte{}, data...)
+			copy(patched[8:12], brand)
+			return patched, false
+		}
+	}
+	return data, false
+}
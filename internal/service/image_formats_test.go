@@ -0,0 +1,68 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/gen2brain/avif"
+)
+
+// testFtyp builds the start of a HEIF file with the given major and compatible brands
+func testFtyp(major string, compatible ...string) []byte {
+	box := []byte{0, 0, 0, byte(16 + 4*len(compatible))}
+	box = append(box, "ftyp"+major+"\x00\x00\x00\x00"...)
+	for _, brand := range compatible {
+		box = append(box, brand...)
+	}
+	return append(box, "rest"...)
+}
+
+func TestHEIFCodec(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		wantAVIF  bool
+		wantMajor string
+	}{
+		{"avif", testFtyp("mif1", "mif1", "miaf", "avif"), true, "mif1"},
+		{"avif sequence", testFtyp("msf1", "msf1", "avis"), true, "msf1"},
+		{"hevc", testFtyp("mif1", "mif1", "heic", "miaf"), false, "heic"},
+		{"hevc sequence", testFtyp("msf1", "msf1", "hevc"), false, "hevc"},
+		{"unknown codec", testFtyp("mif1", "mif1", "miaf"), false, "mif1"},
+		{"truncated", []byte("\x00\x00\x00\x18ftypmif1"), false, "mif1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]byte{}, tt.data...)
+			data, isAVIF := heifCodec(tt.data)
+			if isAVIF != tt.wantAVIF {
+				t.Errorf("AVIF = %v, want %v", isAVIF, tt.wantAVIF)
+			}
+			if major := string(data[8:12]); major != tt.wantMajor {
+				t.Errorf("major brand = %q, want %q", major, tt.wantMajor)
+			}
+			if !bytes.Equal(tt.data, original) {
+				t.Error("heifCodec modified its input")
+			}
+		})
+	}
+}
+
+func TestDecodeGenericHEIFBrand(t *testing.T) {
+	var buf bytes.Buffer
+	if err := avif.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 24, 16))); err != nil {
+		t.Fatal(err)
+	}
+	// Some encoders write the generic HEIF brand as major brand, with AVIF among the compatible brands
+	data := buf.Bytes()
+	copy(data[8:12], "mif1")
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("image.Decode: %v", err)
+	}
+	if format != "heif" || img.Bounds() != image.Rect(0, 0, 24, 16) {
+		t.Errorf("decoded %s %v, want heif (0,0)-(24,16)", format, img.Bounds())
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/nfnt/resize"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// defaultHashThreshold is the largest Hamming distance at which two images are considered similar
+const defaultHashThreshold = 10
+
+// ImageHashes holds the perceptual hashes of a single image as 64-bit hex strings
+type ImageHashes struct {
+	Filename string `json:"filename"`
+	AHash    string `json:"ahash"`
+	DHash    string `json:"dhash"`
+	PHash    string `json:"phash"`
+}
+
+// HashComparison holds the Hamming distances between two images
+type HashComparison struct {
+	First     string         `json:"first"`
+	Second    string         `json:"second"`
+	Distances map[string]int `json:"distances"`
+	Similar   bool           `json:"similar"`
+}
+
+// ImageHashResult is the result of a perceptual hash request
+type ImageHashResult struct {
+	Images      []ImageHashes    `json:"images"`
+	Algorithm   string           `json:"algorithm,omitempty"`
+	Threshold   int              `json:"threshold,omitempty"`
+	Comparisons []HashComparison `json:"comparisons,omitempty"`
+}
+
+// HashImages handles the perceptual hash request for a set of uploaded images
+func HashImages(files []model.File, opts model.HashOptions) response.APIResponse {
+	result, err := ComputeImageHashes(files, opts)
+	if err != nil {
+		return newConversionErrorResponse("Image hashing failed", err)
+	}
+	return response.NewSuccessResponse("Image hashes computed successfully", result)
+}
+
+// ComputeImageHashes computes the aHash, dHash and pHash of every image and, in comparison
+// mode, the Hamming distances between every pair of images
+func ComputeImageHashes(files []model.File, opts model.HashOptions) (*ImageHashResult, error) {
+	if opts.Compare && len(files) < 2 {
+		return nil, &utils.InvalidInputError{Msg: "comparison requires at least two images"}
+	}
+
+	images, err := decodeUploadedImages(files, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]map[string]uint64, len(images))
+	result := &ImageHashResult{Images: make([]ImageHashes, len(images))}
+	for i, img := range images {
+		hashes[i] = map[string]uint64{
+			"ahash": averageHash(img.Image),
+			"dhash": differenceHash(img.Image),
+			"phash": perceptualHash(img.Image),
+		}
+		result.Images[i] = ImageHashes{
+			Filename: img.Name,
+			AHash:    fmt.Sprintf("%016x", hashes[i]["ahash"]),
+			DHash:    fmt.Sprintf("%016x", hashes[i]["dhash"]),
+			PHash:    fmt.Sprintf("%016x", hashes[i]["phash"]),
+		}
+	}
+
+	if !opts.Compare {
+		return result, nil
+	}
+
+	result.Algorithm = opts.Algorithm
+	if result.Algorithm == "" {
+		result.Algorithm = "phash"
+	}
+	result.Threshold = defaultHashThreshold
+	if opts.Threshold != nil {
+		result.Threshold = *opts.Threshold
+	}
+
+	for i := 0; i < len(images); i++ {
+		for j := i + 1; j < len(images); j++ {
+			distances := make(map[string]int, len(hashes[i]))
+			for name, hash := range hashes[i] {
+				distances[name] = bits.OnesCount64(hash ^ hashes[j][name])
+			}
+			result.Comparisons = append(result.Comparisons, HashComparison{
+				First:     images[i].Name,
+				Second:    images[j].Name,
+				Distances: distances,
+				Similar:   distances[result.Algorithm] <= result.Threshold,
+			})
+		}
+	}
+	return result, nil
+}
+
+// averageHash sets a bit for every pixel of an 8x8 grayscale thumbnail that is brighter than the mean
+func averageHash(img image.Image) uint64 {
+	pixels := grayPixels(img, 8, 8)
+
+	var sum float64
+	for _, v := range pixels {
+		sum += v
+	}
+	mean := sum / float64(len(pixels))
+
+	var hash uint64
+	for i, v := range pixels {
+		if v > mean {
+			hash |= 1 << uint(63-i)
+		}
+	}
+	return hash
+}
+
+// differenceHash sets a bit for every pixel of a 9x8 grayscale thumbnail that is brighter than its right neighbour
+func differenceHash(img image.Image) uint64 {
+	pixels := grayPixels(img, 9, 8)
+
+	var hash uint64
+	bit := 63
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if pixels[y*9+x] > pixels[y*9+x+1] {
+				hash |= 1 << uint(bit)
+			}
+			bit--
+		}
+	}
+	return hash
+}
+
+// perceptualHash sets a bit for every low frequency DCT coefficient of a 32x32 grayscale
+// thumbnail that is above the median of those coefficients
+func perceptualHash(img image.Image) uint64 {
+	const size, lowFreq = 32, 8
+	pixels := grayPixels(img, size, size)
+
+	// Separable 2D DCT-II, only the low frequency block is needed
+	var rows [size][lowFreq]float64
+	for y := 0; y < size; y++ {
+		for u := 0; u < lowFreq; u++ {
+			var sum float64
+			for x := 0; x < size; x++ {
+				sum += pixels[y*size+x] * math.Cos(float64(2*x+1)*float64(u)*math.Pi/(2*size))
+			}
+			rows[y][u] = sum
+		}
+	}
+	coefficients := make([]float64, 0, lowFreq*lowFreq)
+	for v := 0; v < lowFreq; v++ {
+		for u := 0; u < lowFreq; u++ {
+			var sum float64
+			for y := 0; y < size; y++ {
+				sum += rows[y][u] * math.Cos(float64(2*y+1)*float64(v)*math.Pi/(2*size))
+			}
+			coefficients = append(coefficients, sum)
+		}
+	}
+
+	// The DC coefficient only carries the overall brightness, leave it out of the median
+	sorted := append([]float64(nil), coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for i, c := range coefficients {
+		if c > median {
+			hash |= 1 << uint(63-i)
+		}
+	}
+	return hash
+}
+
+// grayPixels scales an image to the given size and returns its luminance values row by row
+func grayPixels(img image.Image, width, height uint) []float64 {
+	small := resize.Resize(width, height, img, resize.Bilinear)
+	b := small.Bounds()
+	pixels := make([]float64, 0, width*height)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			pixels = append(pixels, float64(color.GrayModel.Convert(small.At(x, y)).(color.Gray).Y))
+		}
+	}
+	return pixels
+}
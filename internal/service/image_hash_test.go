@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/bits"
+	"testing"
+
+	"github.com/nfnt/resize"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// testPattern returns an image of soft diagonal stripes and a bright square, with enough structure for every hash
+func testPattern(width, height int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8((x*256/width + y*128/height) % 256)
+			if x > width/2 && x < width*3/4 && y > height/4 && y < height/2 {
+				v = 255
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+// invert returns the negative of a grayscale image
+func invert(img image.Image) image.Image {
+	b := img.Bounds()
+	out := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.SetGray(x, y, color.Gray{Y: 255 - color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y})
+		}
+	}
+	return out
+}
+
+func TestImageHashesKnownValues(t *testing.T) {
+	// Left half dark and right half bright: the right four bits of every row are set
+	halves := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 32; x < 64; x++ {
+			halves.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	if got := averageHash(halves); got != 0x0f0f0f0f0f0f0f0f {
+		t.Errorf("averageHash = %016x, want 0f0f0f0f0f0f0f0f", got)
+	}
+
+	// Brightness falling to the right sets every dHash bit, rising to the right none
+	falling := image.NewGray(image.Rect(0, 0, 90, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 90; x++ {
+			falling.SetGray(x, y, color.Gray{Y: uint8(250 - x*2)})
+		}
+	}
+	if got := differenceHash(falling); got != ^uint64(0) {
+		t.Errorf("differenceHash of a falling gradient = %016x, want ffffffffffffffff", got)
+	}
+	if got := differenceHash(invert(falling)); got != 0 {
+		t.Errorf("differenceHash of a rising gradient = %016x, want 0", got)
+	}
+
+	// A flat image has no coefficient above the median
+	if got := perceptualHash(image.NewGray(image.Rect(0, 0, 40, 40))); got != 0 {
+		t.Errorf("perceptualHash of a black image = %016x, want 0", got)
+	}
+}
+
+func TestImageHashDistances(t *testing.T) {
+	original := testPattern(256, 192)
+	scaled := resize.Resize(128, 96, original, resize.Bilinear)
+	inverted := invert(original)
+
+	for name, hash := range map[string]func(image.Image) uint64{
+		"ahash": averageHash,
+		"dhash": differenceHash,
+		"phash": perceptualHash,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if d := bits.OnesCount64(hash(original) ^ hash(scaled)); d > 4 {
+				t.Errorf("distance to the scaled copy = %d, want at most 4", d)
+			}
+			if d := bits.OnesCount64(hash(original) ^ hash(inverted)); d < 32 {
+				t.Errorf("distance to the inverted copy = %d, want at least 32", d)
+			}
+		})
+	}
+}
+
+func TestComputeImageHashes(t *testing.T) {
+	var files []testFile
+	for _, img := range []image.Image{testPattern(256, 192), testPattern(128, 96), invert(testPattern(256, 192))} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, testFile{name: "image.png", data: buf.Bytes()})
+	}
+
+	result, err := ComputeImageHashes(testUploadFiles(t, files...), model.HashOptions{Compare: true})
+	if err != nil {
+		t.Fatalf("ComputeImageHashes: %v", err)
+	}
+	if len(result.Images) != 3 || len(result.Comparisons) != 3 {
+		t.Fatalf("got %d images and %d comparisons, want 3 and 3", len(result.Images), len(result.Comparisons))
+	}
+	if result.Algorithm != "phash" || result.Threshold != defaultHashThreshold {
+		t.Errorf("algorithm %q with threshold %d, want phash with %d", result.Algorithm, result.Threshold, defaultHashThreshold)
+	}
+	// Pairs are compared in upload order: the scaled copy is similar, the inverted one is not
+	for i, want := range []bool{true, false, false} {
+		if result.Comparisons[i].Similar != want {
+			t.Errorf("comparison %d similar = %v, want %v", i, result.Comparisons[i].Similar, want)
+		}
+	}
+
+	_, err = ComputeImageHashes(testUploadFiles(t, files[0]), model.HashOptions{Compare: true})
+	if !errorIsType(err, &utils.InvalidInputError{}) {
+		t.Errorf("comparing a single image: error = %v, want invalid input", err)
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/utils"
+)
+
+// decodeImage decodes an image after checking its dimensions against the pixel limit
+func decodeImage(file io.Reader) (image.Image, string, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image: %w", err)
+	}
+
+	// Read only the header first so oversized images are rejected before allocating pixels
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	if err := checkImagePixels(cfg.Width, cfg.Height); err != nil {
+		return nil, "", err
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, format, nil
+}
+
+// checkImagePixels rejects empty images and images whose pixel count exceeds the configured limit
+func checkImagePixels(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return &utils.InvalidInputError{Msg: fmt.Sprintf("image is %dx%d, both dimensions must be positive", width, height)}
+	}
+	// Divide instead of multiplying so huge dimensions cannot overflow
+	limit := config.AppLimits.MaxImagePixels
+	if int64(height) > limit/int64(width) {
+		return &utils.LimitExceededError{Msg: fmt.Sprintf("image is %dx%d, the limit is %d pixels", width, height, limit)}
+	}
+	return nil
+}
+
+// readZipDocument reads a zip based document (DOCX, XLSX) and checks its uncompressed size. The entries are
+// decompressed and counted, since the sizes declared in the archive may be forged.
+func readZipDocument(file io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open document archive: %w", err)
+	}
+
+	budget := config.AppLimits.MaxDecompressedBytes
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s in document archive: %w", f.Name, err)
+		}
+		n, err := io.Copy(io.Discard, io.LimitReader(rc, budget+1))
+		rc.Close()
+		if err != nil {
+			return nil, &utils.CorruptFileError{Format: "document", Msg: fmt.Sprintf("%s cannot be decompressed: %s", f.Name, err)}
+		}
+		budget -= n
+		if budget < 0 {
+			return nil, &utils.LimitExceededError{Msg: fmt.Sprintf("document expands to more than %d bytes", config.AppLimits.MaxDecompressedBytes)}
+		}
+	}
+	return data, nil
+}
+
+// openSpreadsheet opens an Excel document with the decompression limits applied
+func openSpreadsheet(file io.Reader) (*excelize.File, error) {
+	data, err := readZipDocument(file)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := config.AppLimits.MaxDecompressedBytes
+	return excelize.OpenReader(bytes.NewReader(data), excelize.Options{
+		UnzipSizeLimit:    limit,
+		UnzipXMLSizeLimit: min(limit, excelize.StreamChunkSize),
+	})
+}
+
+// checkSpreadsheetCells rejects spreadsheets with more cells than the configured limit
+func checkSpreadsheetCells(xl *excelize.File) error {
+	var cells int64
+	for _, sheetName := range xl.GetSheetList() {
+		rows, err := xl.Rows(sheetName)
+		if err != nil {
+			return fmt.Errorf("failed to read rows: %w", err)
+		}
+		for rows.Next() {
+			cols, err := rows.Columns()
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to read columns: %w", err)
+			}
+			cells += int64(len(cols))
+			if cells > config.AppLimits.MaxSpreadsheetCells {
+				rows.Close()
+				return &utils.LimitExceededError{Msg: fmt.Sprintf("spreadsheet has more than %d cells", config.AppLimits.MaxSpreadsheetCells)}
+			}
+		}
+		if err := rows.Close(); err != nil {
+			return fmt.Errorf("failed to close rows: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkPDFPages rejects PDFs with more pages than the configured limit
+func checkPDFPages(pageCount int) error {
+	if pageCount > config.AppLimits.MaxPDFPages {
+		return &utils.LimitExceededError{Msg: fmt.Sprintf("PDF has %d pages, the limit is %d pages", pageCount, config.AppLimits.MaxPDFPages)}
+	}
+	return nil
+}
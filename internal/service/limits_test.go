@@ -0,0 +1,169 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/xuri/excelize/v2"
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/utils"
+)
+
+// testZip returns a zip archive of the given entries, each of the given size
+func testZip(t *testing.T, size int, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(make([]byte, size))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageLimits(t *testing.T) {
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxImagePixels = 100
+
+	tests := []struct {
+		width, height int
+		wantErr       interface{}
+	}{
+		{10, 10, nil},
+		{1, 100, nil},
+		{11, 10, &utils.LimitExceededError{}},
+		{101, 1, &utils.LimitExceededError{}},
+		{1 << 31, 1 << 31, &utils.LimitExceededError{}}, // Overflows 32-bit multiplication
+		{1 << 32, 1 << 32, &utils.LimitExceededError{}}, // Overflows 64-bit multiplication
+		{0, 10, &utils.InvalidInputError{}},
+		{-5, 10, &utils.InvalidInputError{}},
+		{10, -5, &utils.InvalidInputError{}},
+	}
+	for _, tt := range tests {
+		if err := checkImagePixels(tt.width, tt.height); !errorIsType(err, tt.wantErr) {
+			t.Errorf("checkImagePixels(%d, %d) = %v, want %T", tt.width, tt.height, err, tt.wantErr)
+		}
+	}
+
+	for size, wantErr := range map[image.Point]interface{}{{10, 10}: nil, {20, 10}: &utils.LimitExceededError{}} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rectangle{Max: size})); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := decodeImage(&buf); !errorIsType(err, wantErr) {
+			t.Errorf("decodeImage of a %v image = %v, want %T", size, err, wantErr)
+		}
+	}
+	if _, _, err := decodeImage(bytes.NewReader([]byte("not an image"))); err == nil {
+		t.Error("decodeImage succeeded on garbage")
+	}
+}
+
+func TestReadZipDocument(t *testing.T) {
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxDecompressedBytes = 1000
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr interface{}
+	}{
+		{"within limit", testZip(t, 500, "a.xml", "b.xml"), nil},
+		{"single entry over limit", testZip(t, 1001, "a.xml"), &utils.LimitExceededError{}},
+		{"entries add up over limit", testZip(t, 400, "a.xml", "b.xml", "c.xml"), &utils.LimitExceededError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := readZipDocument(bytes.NewReader(tt.data))
+			if !errorIsType(err, tt.wantErr) {
+				t.Fatalf("readZipDocument error = %v, want %T", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(data, tt.data) {
+				t.Error("readZipDocument changed the document")
+			}
+		})
+	}
+	if _, err := readZipDocument(bytes.NewReader([]byte("not a zip"))); err == nil {
+		t.Error("readZipDocument succeeded on garbage")
+	}
+	if _, err := readZipDocument(bytes.NewReader(forgedZip(t, 2000, 10))); !errorIsType(err, &utils.CorruptFileError{}) {
+		t.Errorf("readZipDocument of an entry with a forged size = %v, want corrupt file", err)
+	}
+}
+
+// forgedZip returns a zip archive with one deflated entry of the given size that declares a smaller uncompressed size
+func forgedZip(t *testing.T, size int, declared uint64) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(make([]byte, size))
+	fw.Close()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "a.xml",
+		Method:             zip.Deflate,
+		CRC32:              crc32.ChecksumIEEE(make([]byte, size)),
+		CompressedSize64:   uint64(compressed.Len()),
+		UncompressedSize64: declared,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(compressed.Bytes())
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCheckSpreadsheetCells(t *testing.T) {
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxSpreadsheetCells = 6
+
+	xl := excelize.NewFile()
+	defer xl.Close()
+	xl.SetSheetRow("Sheet1", "A1", &[]interface{}{1, 2, 3})
+	xl.NewSheet("Sheet2")
+	xl.SetSheetRow("Sheet2", "A1", &[]interface{}{4, 5, 6})
+	if err := checkSpreadsheetCells(xl); err != nil {
+		t.Errorf("checkSpreadsheetCells at the limit: %v", err)
+	}
+
+	xl.SetSheetRow("Sheet2", "A2", &[]interface{}{7})
+	if err := checkSpreadsheetCells(xl); !errorIsType(err, &utils.LimitExceededError{}) {
+		t.Errorf("checkSpreadsheetCells over the limit = %v, want limit exceeded", err)
+	}
+}
+
+func TestPDFPageLimit(t *testing.T) {
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxPDFPages = 3
+
+	if err := checkPDFPages(3); err != nil {
+		t.Errorf("checkPDFPages(3) = %v, want no error", err)
+	}
+	if err := checkPDFPages(4); !errorIsType(err, &utils.LimitExceededError{}) {
+		t.Errorf("checkPDFPages(4) = %v, want limit exceeded", err)
+	}
+
+	if _, err := readPDF(bytes.NewReader(testPDF(t, 4)), pdfmodel.VALIDATE); !errorIsType(err, &utils.LimitExceededError{}) {
+		t.Errorf("reading a PDF over the page limit: error = %v, want limit exceeded", err)
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// readPDF reads and validates an uploaded PDF for the given pdfcpu command, enforcing the page limit
+func readPDF(file io.Reader, cmd pdfmodel.CommandMode) (*pdfmodel.Context, error) {
+	return readPDFWithPassword(file, cmd, "")
+}
+
+// readPDFWithPassword reads an uploaded PDF like readPDF, decrypting it with the user or owner password
+func readPDFWithPassword(file io.Reader, cmd pdfmodel.CommandMode, password string) (*pdfmodel.Context, error) {
+	conf := pdfmodel.NewDefaultConfiguration()
+	conf.Cmd = cmd
+	conf.UserPW, conf.OwnerPW = password, password
+	return readPDFWithConfig(file, conf)
+}
+
+// readPDFWithConfig reads an uploaded PDF with a configuration prepared for the pdfcpu command
+func readPDFWithConfig(file io.Reader, conf *pdfmodel.Configuration) (*pdfmodel.Context, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+
+	ctx, err := api.ReadAndValidate(bytes.NewReader(data), conf)
+	if err != nil {
+		return nil, pdfReadError(err)
+	}
+	if err := checkPDFPages(ctx.PageCount); err != nil {
+		return nil, err
+	}
+
+	// The steps api.ReadValidateAndOptimize takes after validation work on a PDF that read fine, so their failures
+	// are errors of the service rather than of the upload
+	if conf.Optimize {
+		if err := api.OptimizeContext(ctx); err != nil {
+			return nil, fmt.Errorf("failed to optimize PDF: %w", err)
+		}
+	}
+	if err := pdfcpu.CacheFormFonts(ctx); err != nil {
+		return nil, fmt.Errorf("failed to read PDF form fonts: %w", err)
+	}
+	return ctx, nil
+}
+
+// pdfReadError classifies an error of reading or validating an uploaded PDF. Password and encryption problems are
+// invalid input, I/O errors stay server errors, and the parse and validation failures pdfcpu reports for a damaged
+// file make it a corrupt PDF. Errors of later processing steps must not be passed in.
+func pdfReadError(err error) error {
+	switch {
+	case errors.Is(err, pdfcpu.ErrWrongPassword):
+		return &utils.InvalidInputError{Msg: "the PDF is password protected and the password is missing or incorrect"}
+	case errors.Is(err, pdfcpu.ErrUnknownEncryption):
+		return &utils.InvalidInputError{Msg: "the PDF uses an unsupported encryption"}
+	}
+
+	// pdfcpu checks the encryption state a command needs with plain errors, only their messages tell them apart
+	msg := err.Error()
+	switch {
+	case msg == "pdfcpu: this file is already encrypted":
+		return &utils.InvalidInputError{Msg: "the PDF is already encrypted, decrypt it first"}
+	case msg == "pdfcpu: this file is not encrypted":
+		return &utils.InvalidInputError{Msg: "the PDF is not encrypted"}
+	case strings.HasPrefix(msg, "pdfcpu: operation restriced via pdfcpu's permission bits"):
+		return &utils.InvalidInputError{Msg: "the PDF permissions do not allow this operation, the owner password is required"}
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+	return &utils.CorruptFileError{Format: "PDF", Msg: strings.TrimPrefix(msg, "pdfcpu: ")}
+}
+
+// selectPDFPages resolves a page selection such as "1-3,5,even" into sorted page numbers; an empty selection is every page
+func selectPDFPages(pageCount int, selection string) ([]int, error) {
+	parsed, err := api.ParsePageSelection(selection)
+	if err != nil {
+		return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("invalid page selection %q", selection)}
+	}
+	selected, err := api.PagesForPageSelection(pageCount, parsed, true, false)
+	if err != nil {
+		return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("invalid page selection %q: %s", selection, err.Error())}
+	}
+
+	pages := make([]int, 0, len(selected))
+	for page, ok := range selected {
+		if ok && page >= 1 && page <= pageCount {
+			pages = append(pages, page)
+		}
+	}
+	if len(pages) == 0 {
+		return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("page selection %q matches none of the %d pages", selection, pageCount)}
+	}
+	sort.Ints(pages)
+	return pages, nil
+}
+
+// parsePageRanges parses comma separated pages and ranges such as "1-3,5,8-" into one list of pages per range.
+// A range without an end runs to the last page. Errors name the offending range and what is wrong with it.
+func parsePageRanges(spec string, pageCount int) ([][]int, error) {
+	var ranges [][]int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("empty range in %q", spec)}
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := parsePageNumber(from, part, pageCount)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if to == "" {
+				last = pageCount
+			} else if last, err = parsePageNumber(to, part, pageCount); err != nil {
+				return nil, err
+			}
+		}
+		if last < first {
+			return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("range %q ends before it starts", part)}
+		}
+
+		pages := make([]int, 0, last-first+1)
+		for p := first; p <= last; p++ {
+			pages = append(pages, p)
+		}
+		ranges = append(ranges, pages)
+	}
+	return ranges, nil
+}
+
+// parsePageNumber parses a single page number of a range, checking it lies within the document
+func parsePageNumber(s, part string, pageCount int) (int, error) {
+	page, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, &utils.InvalidInputError{Msg: fmt.Sprintf("range %q: %q is not a page number", part, s)}
+	}
+	if page < 1 {
+		return 0, &utils.InvalidInputError{Msg: fmt.Sprintf("range %q: pages start at 1", part)}
+	}
+	if page > pageCount {
+		return 0, &utils.InvalidInputError{Msg: fmt.Sprintf("range %q: page %d is beyond the last page %d", part, page, pageCount)}
+	}
+	return page, nil
+}
+
+// finishGeneratedPDF applies the requested header and footer, watermark, attachments, metadata and encryption to a
+// PDF generated by a conversion
+func finishGeneratedPDF(data []byte, opts model.ConvertOptions) ([]byte, error) {
+	data, err := headerFooterConvertedPDF(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err = watermarkConvertedPDF(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err = attachConvertedPDF(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err = describeGeneratedPDF(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return encryptConvertedPDF(data, opts)
+}
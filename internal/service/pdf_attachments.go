@@ -0,0 +1,345 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// PDFAttachment describes a file embedded in a PDF
+type PDFAttachment struct {
+	File        string `json:"file,omitempty"` // Name of the extracted file in a ZIP
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Size        int    `json:"size"`
+	Modified    string `json:"modified,omitempty"`
+}
+
+// PDFAttachmentsResult holds the files embedded in a PDF
+type PDFAttachmentsResult struct {
+	AttachmentCount int             `json:"attachment_count"`
+	Attachments     []PDFAttachment `json:"attachments"`
+}
+
+// ProcessPDFAttachments handles the attachment request for an uploaded PDF: the given files are added to it,
+// the named attachment is extracted, or without either the attachments are listed as JSON
+func ProcessPDFAttachments(file io.Reader, attachments []model.File, opts model.AttachmentOptions) response.APIResponse {
+	if len(attachments) > 0 {
+		data, err := AddPDFAttachments(file, attachments, opts)
+		if err != nil {
+			return newConversionErrorResponse("Adding PDF attachments failed", err)
+		}
+		return response.NewSuccessResponse("PDF attachments added successfully", data)
+	}
+
+	if opts.Name != "" {
+		data, filename, err := ExtractPDFAttachment(file, opts.Name, opts.Password)
+		if err != nil {
+			return newConversionErrorResponse("PDF attachment extraction failed", err)
+		}
+		return response.NewSuccessResponse("PDF attachment extracted successfully", model.ConvertedFile{Content: data, Filename: filename})
+	}
+
+	result, err := ListPDFAttachments(file, opts.Password)
+	if err != nil {
+		return newConversionErrorResponse("Reading PDF attachments failed", err)
+	}
+	return response.NewSuccessResponse("PDF attachments read successfully", result)
+}
+
+// ListPDFAttachments returns the files embedded in a PDF
+func ListPDFAttachments(file io.Reader, password string) (*PDFAttachmentsResult, error) {
+	ctx, err := readPDFWithPassword(file, pdfmodel.LISTATTACHMENTS, password)
+	if err != nil {
+		return nil, err
+	}
+	files, err := embeddedFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sizes are taken from the file parameters, only files without them are decoded to measure them
+	result := &PDFAttachmentsResult{Attachments: []PDFAttachment{}}
+	budget := config.AppLimits.MaxDecompressedBytes
+	for _, f := range files {
+		if f.info.Size < 0 {
+			data, err := decodeEmbeddedFile(f, budget)
+			if err != nil {
+				return nil, err
+			}
+			f.info.Size = len(data)
+			budget -= int64(len(data))
+		}
+		result.Attachments = append(result.Attachments, f.info)
+	}
+	result.AttachmentCount = len(result.Attachments)
+	return result, nil
+}
+
+// ExtractPDFAttachment returns the content and file name of the attachment with the given name
+func ExtractPDFAttachment(file io.Reader, name, password string) ([]byte, string, error) {
+	ctx, err := readPDFWithPassword(file, pdfmodel.EXTRACTATTACHMENTS, password)
+	if err != nil {
+		return nil, "", err
+	}
+	files, err := embeddedFiles(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, f := range files {
+		if f.id == name || f.info.Name == name {
+			data, err := decodeEmbeddedFile(f, config.AppLimits.MaxDecompressedBytes)
+			if err != nil {
+				return nil, "", err
+			}
+			return data, attachmentFilename(f.info.Name), nil
+		}
+	}
+	return nil, "", &utils.InvalidInputError{Msg: fmt.Sprintf("the PDF has no attachment %q", name)}
+}
+
+// ExtractPDFAttachments returns the files embedded in a PDF as a ZIP with a manifest.json
+func ExtractPDFAttachments(file io.Reader, opts model.ConvertOptions) ([]byte, error) {
+	ctx, err := readPDFWithPassword(file, pdfmodel.EXTRACTATTACHMENTS, opts.Password)
+	if err != nil {
+		return nil, err
+	}
+	files, err := embeddedFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []utils.ZipEntry
+	manifest := []PDFAttachment{}
+	used := map[string]bool{"manifest.json": true}
+	budget := config.AppLimits.MaxDecompressedBytes
+	for _, f := range files {
+		data, err := decodeEmbeddedFile(f, budget)
+		if err != nil {
+			return nil, err
+		}
+		budget -= int64(len(data))
+
+		info := f.info
+		info.Size = len(data)
+		// Attachments may share a file name, the later ones get a numbered suffix
+		info.File = attachmentFilename(info.Name)
+		ext := path.Ext(info.File)
+		base := strings.TrimSuffix(info.File, ext)
+		for i := 2; used[info.File]; i++ {
+			info.File = fmt.Sprintf("%s_%d%s", base, i, ext)
+		}
+		used[info.File] = true
+		manifest = append(manifest, info)
+		entries = append(entries, utils.ZipEntry{Name: info.File, Data: data})
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write attachment manifest: %w", err)
+	}
+	entries = append(entries, utils.ZipEntry{Name: "manifest.json", Data: manifestData})
+	return utils.CreateZip(entries)
+}
+
+// AddPDFAttachments embeds the uploaded files in a PDF under their file names, replacing attachments of the same name
+func AddPDFAttachments(file io.Reader, attachments []model.File, opts model.AttachmentOptions) ([]byte, error) {
+	ctx, err := readPDFWithPassword(file, pdfmodel.ADDATTACHMENTS, opts.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := embeddedFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, f := range existing {
+		names[f.id] = true
+	}
+
+	for _, attachment := range attachments {
+		name := attachmentFilename(attachment.Filename)
+		data, err := readUploadedFile(attachment)
+		if err != nil {
+			return nil, err
+		}
+
+		if names[name] {
+			if _, err := ctx.RemoveAttachments([]string{name}); err != nil {
+				return nil, fmt.Errorf("failed to replace attachment %s: %w", name, err)
+			}
+		}
+		modified := time.Now()
+		a := pdfmodel.Attachment{Reader: bytes.NewReader(data), ID: name, FileName: name, Desc: opts.Description, ModTime: &modified}
+		if err := ctx.AddAttachment(a, false); err != nil {
+			return nil, fmt.Errorf("failed to add attachment %s: %w", name, err)
+		}
+		names[name] = true
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// attachConvertedPDF embeds the files uploaded with a conversion in the generated PDF
+func attachConvertedPDF(data []byte, opts model.ConvertOptions) ([]byte, error) {
+	if len(opts.Attachments) == 0 {
+		return data, nil
+	}
+	return AddPDFAttachments(bytes.NewReader(data), opts.Attachments, model.AttachmentOptions{})
+}
+
+// pdfEmbeddedFile is a file embedded in a PDF together with its name tree key and its still encoded stream
+type pdfEmbeddedFile struct {
+	id     string
+	info   PDFAttachment // Size is -1 when the file parameters do not record it
+	stream *types.StreamDict
+}
+
+// embeddedFiles lists the files embedded in a PDF without decoding them. pdfcpu's attachment stubs decode every
+// stream in full, so the embedded files name tree is walked here instead.
+func embeddedFiles(ctx *pdfmodel.Context) ([]pdfEmbeddedFile, error) {
+	if !ctx.XRefTable.Valid {
+		if err := ctx.XRefTable.LocateNameTree("EmbeddedFiles", false); err != nil {
+			return nil, fmt.Errorf("failed to read attachments: %w", err)
+		}
+	}
+	tree := ctx.XRefTable.Names["EmbeddedFiles"]
+	if tree == nil {
+		return nil, nil
+	}
+
+	var files []pdfEmbeddedFile
+	err := tree.Process(ctx.XRefTable, func(xRefTable *pdfmodel.XRefTable, id string, o *types.Object) error {
+		spec, err := xRefTable.DereferenceDict(*o)
+		if err != nil || spec == nil {
+			return err
+		}
+
+		f := pdfEmbeddedFile{id: id, info: PDFAttachment{Name: id, Size: -1}}
+		for _, key := range []string{"UF", "F"} {
+			if obj, found := spec.Find(key); found {
+				if name, err := xRefTable.DereferenceStringOrHexLiteral(obj, pdfmodel.V10, nil); err == nil && name != "" {
+					f.info.Name = name
+					break
+				}
+			}
+		}
+		if obj, found := spec.Find("Desc"); found {
+			f.info.Description, _ = xRefTable.DereferenceStringOrHexLiteral(obj, pdfmodel.V10, nil)
+		}
+
+		ef, err := xRefTable.DereferenceDict(spec["EF"])
+		if err != nil || ef == nil {
+			return err
+		}
+		if f.stream, _, err = xRefTable.DereferenceStreamDict(ef["F"]); err != nil || f.stream == nil {
+			return err
+		}
+		if params := f.stream.DictEntry("Params"); params != nil {
+			if size := params.IntEntry("Size"); size != nil && *size >= 0 {
+				f.info.Size = *size
+			}
+			if date := params.StringEntry("ModDate"); date != nil {
+				if t, ok := types.DateTime(*date, true); ok {
+					f.info.Modified = t.Format(time.RFC3339)
+				}
+			}
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachments: %w", err)
+	}
+	return files, nil
+}
+
+// decodeEmbeddedFile decodes the stream of an embedded file, failing as soon as it expands beyond the limit.
+// Every filter decodes at most one byte more than the limit; pdfcpu's filters report a shorter stream with
+// io.EOF, which means the stream fits and is decoded in full. Image encoded streams are returned as they are, as
+// pdfcpu does.
+func decodeEmbeddedFile(f pdfEmbeddedFile, limit int64) ([]byte, error) {
+	limitErr := &utils.LimitExceededError{Msg: fmt.Sprintf("attachments expand to more than %d bytes", config.AppLimits.MaxDecompressedBytes)}
+	data := f.stream.Raw
+	for _, pf := range f.stream.FilterPipeline {
+		if pf.Name == filter.DCT || pf.Name == filter.JPX {
+			break
+		}
+		parms := map[string]int{}
+		for k, v := range pf.DecodeParms {
+			switch v := v.(type) {
+			case types.Integer:
+				parms[k] = v.Value()
+			case types.Boolean:
+				parms[k] = 0
+				if v.Value() {
+					parms[k] = 1
+				}
+			}
+		}
+		var r io.Reader
+		fi, err := filter.NewFilter(pf.Name, parms)
+		if err == nil {
+			r, err = fi.DecodeLength(bytes.NewReader(data), limit+1)
+			if errors.Is(err, io.EOF) {
+				r, err = fi.Decode(bytes.NewReader(data))
+			}
+		}
+		if err != nil {
+			return nil, &utils.CorruptFileError{Format: "PDF", Msg: fmt.Sprintf("attachment %s cannot be decoded: %s", f.info.Name, strings.TrimPrefix(err.Error(), "pdfcpu: "))}
+		}
+		if data, err = io.ReadAll(io.LimitReader(r, limit+1)); err != nil {
+			return nil, fmt.Errorf("failed to extract attachment %s: %w", f.info.Name, err)
+		}
+		if int64(len(data)) > limit {
+			return nil, limitErr
+		}
+	}
+	if int64(len(data)) > limit {
+		return nil, limitErr
+	}
+	return data, nil
+}
+
+// attachmentFilename strips directories from the name of an attachment, which may use either separator
+func attachmentFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		return "attachment"
+	}
+	return name
+}
+
+// readUploadedFile reads the content of an uploaded file
+func readUploadedFile(f model.File) ([]byte, error) {
+	src, err := f.FileContent.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Filename, err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Filename, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+func TestPDFAttachmentsDecompressionLimit(t *testing.T) {
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+
+	// Repeated content compresses to a fraction of its size
+	content := bytes.Repeat([]byte("attachment "), 1000)
+	pdf, err := AddPDFAttachments(bytes.NewReader(testPDF(t, 1)), testUploadFiles(t, testFile{"data.txt", content}), model.AttachmentOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.AppLimits.MaxDecompressedBytes = int64(len(content))
+	data, filename, err := ExtractPDFAttachment(bytes.NewReader(pdf), "data.txt", "")
+	if err != nil {
+		t.Fatalf("ExtractPDFAttachment() error = %v", err)
+	}
+	if filename != "data.txt" || !bytes.Equal(data, content) {
+		t.Fatalf("ExtractPDFAttachment() = %q with %d bytes, want data.txt with %d bytes", filename, len(data), len(content))
+	}
+
+	config.AppLimits.MaxDecompressedBytes = int64(len(content)) - 1
+	var limitErr *utils.LimitExceededError
+	if _, _, err := ExtractPDFAttachment(bytes.NewReader(pdf), "data.txt", ""); !errors.As(err, &limitErr) {
+		t.Fatalf("ExtractPDFAttachment() error = %v, want LimitExceededError", err)
+	}
+	if _, err := ExtractPDFAttachments(bytes.NewReader(pdf), model.ConvertOptions{}); !errors.As(err, &limitErr) {
+		t.Fatalf("ExtractPDFAttachments() error = %v, want LimitExceededError", err)
+	}
+
+	// Listing reads the recorded sizes without decoding the files
+	result, err := ListPDFAttachments(bytes.NewReader(pdf), "")
+	if err != nil {
+		t.Fatalf("ListPDFAttachments() error = %v", err)
+	}
+	if result.AttachmentCount != 1 || result.Attachments[0].Name != "data.txt" || result.Attachments[0].Size != len(content) {
+		t.Fatalf("ListPDFAttachments() = %+v", result)
+	}
+}
+
+func TestAttachmentFilename(t *testing.T) {
+	tests := map[string]string{
+		"report.pdf":            "report.pdf",
+		"dir/report.pdf":        "report.pdf",
+		`C:\Users\me\data.xlsx`: "data.xlsx",
+		"../../etc/passwd":      "passwd",
+		"..":                    "attachment",
+		"/":                     "attachment",
+	}
+	for name, want := range tests {
+		if got := attachmentFilename(name); got != want {
+			t.Errorf("attachmentFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// pdfPermissions are the permission names accepted by encryption requests and the flags they grant
+var pdfPermissions = map[string]pdfmodel.PermissionFlags{
+	"print":  pdfmodel.PermissionPrintRev2 | pdfmodel.PermissionPrintRev3,
+	"copy":   pdfmodel.PermissionExtract | pdfmodel.PermissionExtractRev3,
+	"modify": pdfmodel.PermissionModify | pdfmodel.PermissionModAnnFillForm | pdfmodel.PermissionFillRev3 | pdfmodel.PermissionAssembleRev3,
+}
+
+// EncryptPDF handles the encryption request for an uploaded PDF
+func EncryptPDF(file io.Reader, opts model.EncryptionOptions) response.APIResponse {
+	data, err := ProtectPDF(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF encryption failed", err)
+	}
+	return response.NewSuccessResponse("PDF encrypted successfully", data)
+}
+
+// DecryptPDF handles the decryption request for an uploaded PDF
+func DecryptPDF(file io.Reader, opts model.DecryptionOptions) response.APIResponse {
+	data, err := RemovePDFPassword(file, opts.Password)
+	if err != nil {
+		return newConversionErrorResponse("PDF decryption failed", err)
+	}
+	return response.NewSuccessResponse("PDF decrypted successfully", data)
+}
+
+// ProtectPDF encrypts a PDF with AES-256. Without an owner password a random one is set, so the permissions
+// cannot be lifted by anyone.
+func ProtectPDF(file io.Reader, opts model.EncryptionOptions) ([]byte, error) {
+	data, _, err := protectPDF(file, opts)
+	return data, err
+}
+
+// protectPDF encrypts a PDF like ProtectPDF and returns the owner password it was encrypted with
+func protectPDF(file io.Reader, opts model.EncryptionOptions) ([]byte, string, error) {
+	if opts.UserPassword == "" && opts.OwnerPassword == "" {
+		return nil, "", &utils.InvalidInputError{Msg: "a user or owner password is required"}
+	}
+	permissions, err := parsePDFPermissions(opts.Permissions)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ownerPassword := opts.OwnerPassword
+	if ownerPassword == "" {
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return nil, "", fmt.Errorf("failed to generate owner password: %w", err)
+		}
+		ownerPassword = hex.EncodeToString(random)
+	}
+
+	conf := pdfmodel.NewAESConfiguration(opts.UserPassword, ownerPassword, 256)
+	conf.Cmd = pdfmodel.ENCRYPT
+	conf.Permissions = permissions
+	ctx, err := readPDFWithConfig(file, conf)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, "", fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), ownerPassword, nil
+}
+
+// RemovePDFPassword decrypts a PDF with its user or owner password and removes the permission restrictions
+func RemovePDFPassword(file io.Reader, password string) ([]byte, error) {
+	ctx, err := readPDFWithPassword(file, pdfmodel.DECRYPT, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encryptConvertedPDF encrypts a PDF generated by a conversion when a password is requested. Encryption rewrites
+// the PDF, so the service is recorded as the producer again afterwards.
+func encryptConvertedPDF(data []byte, opts model.ConvertOptions) ([]byte, error) {
+	if opts.UserPassword == "" && opts.OwnerPassword == "" {
+		return data, nil
+	}
+	data, ownerPassword, err := protectPDF(bytes.NewReader(data), opts.EncryptionOptions)
+	if err != nil {
+		return nil, err
+	}
+	return recordPDFProducer(data, ownerPassword)
+}
+
+// parsePDFPermissions parses a comma separated list of permissions into pdfcpu permission flags
+func parsePDFPermissions(spec string) (pdfmodel.PermissionFlags, error) {
+	switch strings.TrimSpace(spec) {
+	case "":
+		return pdfmodel.PermissionsPrint, nil
+	case "none":
+		return pdfmodel.PermissionsNone, nil
+	case "all":
+		return pdfmodel.PermissionsAll, nil
+	}
+
+	flags := pdfmodel.PermissionsNone
+	for _, name := range strings.Split(spec, ",") {
+		flag, ok := pdfPermissions[strings.TrimSpace(name)]
+		if !ok {
+			return 0, &utils.InvalidInputError{Msg: fmt.Sprintf("unknown permission %q, expected print, copy, modify, all or none", name)}
+		}
+		flags |= flag
+	}
+	return flags, nil
+}
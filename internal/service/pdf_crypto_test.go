@@ -0,0 +1,101 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+func TestProtectPDFRoundTrip(t *testing.T) {
+	tests := []struct {
+		permissions      string
+		wantPrint        bool
+		wantCopy         bool
+		wantModify       bool
+		wantPermissionOK bool
+	}{
+		{"", true, false, false, true}, // Printing only by default
+		{"print", true, false, false, true},
+		{"none", false, false, false, true},
+		{"all", true, true, true, true},
+		{"copy,modify", false, true, true, true},
+		{"print,scan", false, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.permissions, func(t *testing.T) {
+			opts := model.EncryptionOptions{UserPassword: "user", OwnerPassword: "owner", Permissions: tt.permissions}
+			data, err := ProtectPDF(bytes.NewReader(testPDF(t, 2)), opts)
+			if !tt.wantPermissionOK {
+				if !errorIsType(err, &utils.InvalidInputError{}) {
+					t.Errorf("ProtectPDF with permissions %q = %v, want invalid input", tt.permissions, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProtectPDF: %v", err)
+			}
+
+			if _, err := readPDF(bytes.NewReader(data), pdfmodel.VALIDATE); !errorIsType(err, &utils.InvalidInputError{}) {
+				t.Errorf("reading without the password = %v, want invalid input", err)
+			}
+			if _, err := readPDFWithPassword(bytes.NewReader(data), pdfmodel.VALIDATE, "wrong"); !errorIsType(err, &utils.InvalidInputError{}) {
+				t.Errorf("reading with a wrong password = %v, want invalid input", err)
+			}
+			ctx, err := readPDFWithPassword(bytes.NewReader(data), pdfmodel.VALIDATE, "user")
+			if err != nil {
+				t.Fatalf("reading with the user password: %v", err)
+			}
+			if ctx.PageCount != 2 {
+				t.Errorf("PageCount = %d, want 2", ctx.PageCount)
+			}
+
+			p := ctx.E.P
+			for _, check := range []struct {
+				name string
+				flag pdfmodel.PermissionFlags
+				want bool
+			}{
+				{"print", pdfmodel.PermissionPrintRev2, tt.wantPrint},
+				{"copy", pdfmodel.PermissionExtract, tt.wantCopy},
+				{"modify", pdfmodel.PermissionModify, tt.wantModify},
+			} {
+				if got := p&int(check.flag) != 0; got != check.want {
+					t.Errorf("%s permission = %v, want %v (P = %#x)", check.name, got, check.want, p)
+				}
+			}
+
+			decrypted, err := RemovePDFPassword(bytes.NewReader(data), "owner")
+			if err != nil {
+				t.Fatalf("RemovePDFPassword with the owner password: %v", err)
+			}
+			ctx, err = readPDF(bytes.NewReader(decrypted), pdfmodel.VALIDATE)
+			if err != nil {
+				t.Fatalf("reading the decrypted PDF without a password: %v", err)
+			}
+			if ctx.Encrypt != nil {
+				t.Error("the decrypted PDF is still encrypted")
+			}
+		})
+	}
+}
+
+func TestProtectPDFRequiresPassword(t *testing.T) {
+	if _, err := ProtectPDF(bytes.NewReader(testPDF(t, 1)), model.EncryptionOptions{}); !errorIsType(err, &utils.InvalidInputError{}) {
+		t.Errorf("ProtectPDF without passwords = %v, want invalid input", err)
+	}
+
+	// Without an owner password a random one keeps the permissions in place
+	data, err := ProtectPDF(bytes.NewReader(testPDF(t, 1)), model.EncryptionOptions{UserPassword: "user", Permissions: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RemovePDFPassword(bytes.NewReader(data), ""); !errorIsType(err, &utils.InvalidInputError{}) {
+		t.Errorf("RemovePDFPassword without a password = %v, want invalid input", err)
+	}
+	if _, err := RemovePDFPassword(bytes.NewReader(testPDF(t, 1)), "user"); !errorIsType(err, &utils.InvalidInputError{}) {
+		t.Errorf("RemovePDFPassword of an unencrypted PDF = %v, want invalid input", err)
+	}
+}
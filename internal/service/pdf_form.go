@@ -0,0 +1,612 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/form"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/primitives"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// Field types reported for the fields of a PDF form
+const (
+	formFieldText     = "text"
+	formFieldDate     = "date"
+	formFieldCheckBox = "checkbox"
+	formFieldRadio    = "radio"
+	formFieldComboBox = "combobox"
+	formFieldListBox  = "listbox"
+)
+
+// Annotation flags hiding a widget from view, see table 167 of the PDF specification
+const annotationHiddenFlags = 1<<1 | 1<<5
+
+// PDFFormField describes a field of a PDF form. Check box values are booleans, list box values lists of strings
+// and all other values strings.
+type PDFFormField struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Type      string      `json:"type"`
+	Pages     []int       `json:"pages"`
+	Value     interface{} `json:"value"`
+	Default   interface{} `json:"default,omitempty"`
+	Options   []string    `json:"options,omitempty"`
+	Format    string      `json:"format,omitempty"`
+	Multiline bool        `json:"multiline,omitempty"`
+	Multi     bool        `json:"multi,omitempty"`
+	Editable  bool        `json:"editable,omitempty"`
+	Locked    bool        `json:"locked"`
+}
+
+// PDFFormResult holds the fields of a PDF form
+type PDFFormResult struct {
+	FieldCount int            `json:"field_count"`
+	Fields     []PDFFormField `json:"fields"`
+}
+
+// ProcessPDFForm handles the form request for an uploaded PDF, returning its fields as JSON or, when values are
+// given or flattening is requested, the filled PDF
+func ProcessPDFForm(file io.Reader, opts model.FormOptions) response.APIResponse {
+	if strings.TrimSpace(opts.Fields) == "" && !opts.Flatten {
+		result, err := ListPDFFormFields(file)
+		if err != nil {
+			return newConversionErrorResponse("Reading PDF form failed", err)
+		}
+		return response.NewSuccessResponse("PDF form read successfully", result)
+	}
+
+	data, err := FillPDFForm(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("Filling PDF form failed", err)
+	}
+	return response.NewSuccessResponse("PDF form filled successfully", data)
+}
+
+// ListPDFFormFields returns the fields of the form of a PDF in page order
+func ListPDFFormFields(file io.Reader) (*PDFFormResult, error) {
+	ctx, err := readPDF(file, pdfmodel.LISTFORMFIELDS)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := pdfFormFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &PDFFormResult{FieldCount: len(fields), Fields: fields}, nil
+}
+
+// FillPDFForm sets the given field values and regenerates the appearance of the changed fields. With flattening
+// the fields are drawn into the page content and the form is removed, so the values can no longer be edited.
+func FillPDFForm(file io.Reader, opts model.FormOptions) ([]byte, error) {
+	values, err := parseFormValues(opts.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := readPDF(file, pdfmodel.FILLFORMFIELDS)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := pdfFormFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fill, err := resolveFormValues(fields, values)
+	if err != nil {
+		return nil, err
+	}
+
+	// Signatures no longer match the document once the values change or the form is flattened
+	if len(fill) > 0 || opts.Flatten {
+		ctx.RemoveSignature()
+	}
+	if len(fill) > 0 {
+		// Read-only fields were rejected above, so the filled fields stay editable
+		fillDetails := func(id, name string, fieldType form.FieldType, format form.DataFormat) ([]string, bool, bool) {
+			values, ok := fill[id]
+			return values, false, ok
+		}
+		if _, _, err := form.FillForm(ctx, fillDetails, nil, form.JSON); err != nil {
+			return nil, fmt.Errorf("failed to fill form: %w", err)
+		}
+	}
+
+	if opts.Flatten {
+		if err := flattenPDFForm(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// parseFormValues parses the JSON object mapping field names or ids to their values
+func parseFormValues(spec string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if strings.TrimSpace(spec) == "" {
+		return values, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(spec))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, &utils.InvalidInputError{Msg: "fields must be a JSON object mapping field names to values: " + err.Error()}
+	}
+	return values, nil
+}
+
+// pdfFormFields collects the fields of the form of a PDF, ordered by the page they first appear on
+func pdfFormFields(ctx *pdfmodel.Context) ([]PDFFormField, error) {
+	if ctx.Form == nil {
+		return nil, &utils.InvalidInputError{Msg: "the PDF has no form fields"}
+	}
+	group, _, err := form.ExportForm(ctx.XRefTable, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read form: %w", err)
+	}
+
+	var fields []PDFFormField
+	for _, f := range group.Forms {
+		for _, tf := range f.TextFields {
+			fields = append(fields, PDFFormField{ID: tf.ID, Name: tf.Name, Type: formFieldText, Pages: tf.Pages,
+				Value: tf.Value, Default: optionalString(tf.Default), Multiline: tf.Multiline, Locked: tf.Locked})
+		}
+		for _, df := range f.DateFields {
+			fields = append(fields, PDFFormField{ID: df.ID, Name: df.Name, Type: formFieldDate, Pages: df.Pages,
+				Value: df.Value, Default: optionalString(df.Default), Format: df.Format, Locked: df.Locked})
+		}
+		for _, cb := range f.CheckBoxes {
+			field := PDFFormField{ID: cb.ID, Name: cb.Name, Type: formFieldCheckBox, Pages: cb.Pages, Value: cb.Value, Locked: cb.Locked}
+			if cb.Default {
+				field.Default = true
+			}
+			fields = append(fields, field)
+		}
+		for _, rb := range f.RadioButtonGroups {
+			fields = append(fields, PDFFormField{ID: rb.ID, Name: rb.Name, Type: formFieldRadio, Pages: rb.Pages,
+				Value: rb.Value, Default: optionalString(rb.Default), Options: rb.Options, Locked: rb.Locked})
+		}
+		for _, cb := range f.ComboBoxes {
+			fields = append(fields, PDFFormField{ID: cb.ID, Name: cb.Name, Type: formFieldComboBox, Pages: cb.Pages,
+				Value: cb.Value, Default: optionalString(cb.Default), Options: cb.Options, Editable: cb.Editable, Locked: cb.Locked})
+		}
+		for _, lb := range f.ListBoxes {
+			field := PDFFormField{ID: lb.ID, Name: lb.Name, Type: formFieldListBox, Pages: lb.Pages,
+				Value: nonNilStrings(lb.Values), Options: lb.Options, Multi: lb.Multi, Locked: lb.Locked}
+			if len(lb.Defaults) > 0 {
+				field.Default = lb.Defaults
+			}
+			fields = append(fields, field)
+		}
+	}
+
+	// Fields on the same page keep the order of the form's field tree
+	listed, _, err := form.FormFields(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read form: %w", err)
+	}
+	order := map[string]int{}
+	for i, field := range listed {
+		order[field.ID] = i
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		pi, pj := firstFormPage(fields[i]), firstFormPage(fields[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return order[fields[i].ID] < order[fields[j].ID]
+	})
+	return fields, nil
+}
+
+// resolveFormValues matches the given values to the form fields by name or id and converts them to the values pdfcpu
+// fills them with, keyed by field id
+func resolveFormValues(fields []PDFFormField, values map[string]interface{}) (map[string][]string, error) {
+	byName := map[string]*PDFFormField{}
+	byID := map[string]*PDFFormField{}
+	for i := range fields {
+		byName[fields[i].Name] = &fields[i]
+		byID[fields[i].ID] = &fields[i]
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fill := map[string][]string{}
+	for _, key := range keys {
+		field, ok := byName[key]
+		if !ok {
+			field, ok = byID[key]
+		}
+		if !ok {
+			return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("the PDF has no form field %q", key)}
+		}
+		if field.Locked {
+			return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("form field %q is read-only", key)}
+		}
+		converted, err := formFieldValue(field, values[key])
+		if err != nil {
+			return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("form field %q: %s", key, err.Error())}
+		}
+		fill[field.ID] = converted
+	}
+	return fill, nil
+}
+
+// formFieldValue checks a JSON value against the type and options of a field and converts it for pdfcpu
+func formFieldValue(field *PDFFormField, value interface{}) ([]string, error) {
+	switch field.Type {
+	case formFieldCheckBox:
+		checked, err := formBool(value)
+		if err != nil {
+			return nil, err
+		}
+		if checked {
+			return []string{"t"}, nil
+		}
+		return []string{"f"}, nil
+
+	case formFieldListBox:
+		selected, err := formStrings(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(selected) > 1 && !field.Multi {
+			return nil, fmt.Errorf("only one option can be selected")
+		}
+		for _, s := range selected {
+			if !slices.Contains(field.Options, s) {
+				return nil, fmt.Errorf("%q is not one of the options %s", s, strings.Join(field.Options, ", "))
+			}
+		}
+		return selected, nil
+	}
+
+	s, err := formString(value)
+	if err != nil {
+		return nil, err
+	}
+	switch field.Type {
+	case formFieldRadio:
+		if !slices.Contains(field.Options, s) {
+			return nil, fmt.Errorf("%q is not one of the options %s", s, strings.Join(field.Options, ", "))
+		}
+	case formFieldComboBox:
+		if s != "" && !field.Editable && !slices.Contains(field.Options, s) {
+			return nil, fmt.Errorf("%q is not one of the options %s", s, strings.Join(field.Options, ", "))
+		}
+	case formFieldDate:
+		if s != "" && field.Format != "" {
+			df, err := primitives.DateFormatForFmtExt(field.Format)
+			if err == nil {
+				if _, err := time.Parse(df.Int, s); err != nil {
+					return nil, fmt.Errorf("%q is not a date formatted as %s", s, field.Format)
+				}
+			}
+		}
+	}
+	return []string{s}, nil
+}
+
+// formString accepts strings and numbers, null clears the field
+func formString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	}
+	return "", fmt.Errorf("expected a string value")
+}
+
+// formStrings accepts a list of strings or a single string, null clears the selection
+func formStrings(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return []string{}, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		selected := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings")
+			}
+			selected = append(selected, s)
+		}
+		return selected, nil
+	}
+	return nil, fmt.Errorf("expected a list of strings")
+}
+
+// formBool accepts booleans as well as the usual spellings of yes and no
+func formBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0", "":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("expected true or false")
+}
+
+// flattenPDFForm draws the appearance of every visible widget into the content of its page, then removes the
+// widgets and the form
+func flattenPDFForm(ctx *pdfmodel.Context) error {
+	// Appearances may rely on the default resources of the form, which go away with it
+	var defaults types.Dict
+	if ctx.Form != nil {
+		if obj, ok := ctx.Form.Find("DR"); ok {
+			defaults, _ = ctx.DereferenceDict(obj)
+		}
+	}
+
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		pageDict, _, inherited, err := ctx.PageDict(pageNr, false)
+		if err != nil {
+			return fmt.Errorf("failed to read page %d: %w", pageNr, err)
+		}
+		if err := flattenPageWidgets(ctx, pageDict, inherited, defaults); err != nil {
+			return fmt.Errorf("failed to flatten page %d: %w", pageNr, err)
+		}
+	}
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return fmt.Errorf("failed to read document catalog: %w", err)
+	}
+	root.Delete("AcroForm")
+	ctx.Form = nil
+	return nil
+}
+
+// flattenPageWidgets replaces the widget annotations of a page by drawing their normal appearance
+func flattenPageWidgets(ctx *pdfmodel.Context, pageDict types.Dict, inherited *pdfmodel.InheritedPageAttrs, defaults types.Dict) error {
+	obj, ok := pageDict.Find("Annots")
+	if !ok {
+		return nil
+	}
+	annots, err := ctx.DereferenceArray(obj)
+	if err != nil {
+		return err
+	}
+
+	var kept types.Array
+	var content bytes.Buffer
+	var xObjects types.Dict
+	for _, annot := range annots {
+		d, err := ctx.DereferenceDict(annot)
+		if err != nil {
+			return err
+		}
+		if d == nil || d.NameEntry("Subtype") == nil || *d.NameEntry("Subtype") != "Widget" {
+			kept = append(kept, annot)
+			continue
+		}
+		if flags := d.IntEntry("F"); flags != nil && *flags&annotationHiddenFlags != 0 {
+			continue
+		}
+
+		appearance, matrix, err := widgetAppearance(ctx, d, defaults)
+		if err != nil || appearance == nil {
+			continue
+		}
+		if xObjects == nil {
+			if xObjects, err = pageXObjects(ctx, pageDict, inherited); err != nil {
+				return err
+			}
+		}
+		name := fmt.Sprintf("Fm%d", len(xObjects))
+		for xObjects[name] != nil {
+			name += "x"
+		}
+		xObjects[name] = *appearance
+		fmt.Fprintf(&content, "q %.4f %.4f %.4f %.4f %.4f %.4f cm /%s Do Q\n",
+			matrix[0], matrix[1], matrix[2], matrix[3], matrix[4], matrix[5], name)
+	}
+
+	if len(kept) > 0 {
+		pageDict["Annots"] = kept
+	} else {
+		pageDict.Delete("Annots")
+	}
+	if content.Len() == 0 {
+		return nil
+	}
+	return appendPageContent(ctx, pageDict, content.Bytes())
+}
+
+// widgetAppearance returns the normal appearance stream of a widget in its current state and the matrix that maps
+// the stream's bounding box onto the widget's rectangle
+func widgetAppearance(ctx *pdfmodel.Context, d types.Dict, defaults types.Dict) (*types.IndirectRef, []float64, error) {
+	ap := d.DictEntry("AP")
+	if ap == nil {
+		return nil, nil, nil
+	}
+	obj, ok := ap.Find("N")
+	if !ok {
+		return nil, nil, nil
+	}
+	// Buttons keep one appearance per state
+	if states, err := ctx.DereferenceDict(obj); err == nil && states != nil {
+		state := d.NameEntry("AS")
+		if state == nil {
+			return nil, nil, nil
+		}
+		if obj, ok = states.Find(*state); !ok {
+			return nil, nil, nil
+		}
+	}
+	indRef, ok := obj.(types.IndirectRef)
+	if !ok {
+		return nil, nil, nil
+	}
+	sd, _, err := ctx.DereferenceStreamDict(indRef)
+	if err != nil || sd == nil {
+		return nil, nil, err
+	}
+
+	rect, err := ctx.RectForArray(d.ArrayEntry("Rect"))
+	if err != nil || rect == nil {
+		return nil, nil, err
+	}
+	bbox, err := ctx.RectForArray(sd.ArrayEntry("BBox"))
+	if err != nil || bbox == nil {
+		return nil, nil, err
+	}
+	form := textMatrix{1, 0, 0, 1, 0, 0}
+	if m := sd.ArrayEntry("Matrix"); len(m) == 6 {
+		for i, v := range m {
+			if n, ok := dereferenceNumber(ctx, v); ok {
+				form[i] = n
+			}
+		}
+	}
+
+	// Transform the bounding box by the form matrix and map the result onto the rectangle (PDF 32000 12.5.5)
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{bbox.LL.X, bbox.LL.Y}, {bbox.UR.X, bbox.LL.Y}, {bbox.LL.X, bbox.UR.Y}, {bbox.UR.X, bbox.UR.Y}} {
+		x, y := form.apply(corner[0], corner[1])
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	if maxX-minX <= 0 || maxY-minY <= 0 {
+		return nil, nil, nil
+	}
+	sx := rect.Width() / (maxX - minX)
+	sy := rect.Height() / (maxY - minY)
+
+	// Appearance streams written without a subtype are still form XObjects
+	if sd.Dict.NameEntry("Subtype") == nil {
+		sd.Dict.InsertName("Type", "XObject")
+		sd.Dict.InsertName("Subtype", "Form")
+	}
+	if _, ok := sd.Dict.Find("Resources"); !ok && defaults != nil {
+		sd.Dict["Resources"] = defaults.Clone()
+	}
+	return &indRef, []float64{sx, 0, 0, sy, rect.LL.X - minX*sx, rect.LL.Y - minY*sy}, nil
+}
+
+// pageXObjects returns the XObject resources of a page for adding entries, giving the page resources of its own
+// when it inherits them
+func pageXObjects(ctx *pdfmodel.Context, pageDict types.Dict, inherited *pdfmodel.InheritedPageAttrs) (types.Dict, error) {
+	var resources types.Dict
+	if obj, ok := pageDict.Find("Resources"); ok {
+		d, err := ctx.DereferenceDict(obj)
+		if err != nil {
+			return nil, err
+		}
+		resources = d
+	}
+	if resources == nil {
+		resources = types.NewDict()
+		if inherited != nil && inherited.Resources != nil {
+			resources = inherited.Resources.Clone().(types.Dict)
+		}
+		pageDict["Resources"] = resources
+	}
+
+	if obj, ok := resources.Find("XObject"); ok {
+		d, err := ctx.DereferenceDict(obj)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			return d, nil
+		}
+	}
+	xObjects := types.NewDict()
+	resources["XObject"] = xObjects
+	return xObjects, nil
+}
+
+// appendPageContent adds a content stream drawn after the existing content of a page, isolating the existing content
+// in its own graphics state
+func appendPageContent(ctx *pdfmodel.Context, pageDict types.Dict, content []byte) error {
+	var streams types.Array
+	if obj, ok := pageDict.Find("Contents"); ok {
+		if arr, err := ctx.DereferenceArray(obj); err == nil && arr != nil {
+			streams = append(streams, arr...)
+		} else {
+			streams = append(streams, obj)
+		}
+	}
+
+	newStream := func(buf []byte) (*types.IndirectRef, error) {
+		sd, err := ctx.NewStreamDictForBuf(buf)
+		if err != nil {
+			return nil, err
+		}
+		if err := sd.Encode(); err != nil {
+			return nil, err
+		}
+		return ctx.IndRefForNewObject(*sd)
+	}
+	before, err := newStream([]byte("q\n"))
+	if err != nil {
+		return err
+	}
+	after, err := newStream(append([]byte("Q\n"), content...))
+	if err != nil {
+		return err
+	}
+
+	contents := types.Array{*before}
+	contents = append(contents, streams...)
+	pageDict["Contents"] = append(contents, *after)
+	return nil
+}
+
+// optionalString returns nil for an empty string so it is left out of the JSON
+func optionalString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nonNilStrings returns an empty list instead of nil so it is rendered as [] in JSON
+func nonNilStrings(ss []string) []string {
+	if ss == nil {
+		return []string{}
+	}
+	return ss
+}
+
+// firstFormPage returns the first page a field appears on
+func firstFormPage(field PDFFormField) int {
+	if len(field.Pages) == 0 {
+		return 0
+	}
+	return slices.Min(field.Pages)
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// testFormPDF builds a one-page PDF with a text field, a check box and a read-only text field. The text field's
+// appearance relies on the form's default resources and the form is marked as signed.
+func testFormPDF() []byte {
+	return buildPDF(
+		[]string{"<< /Type /Catalog /Pages 2 0 R /Perms << /DocMDP 12 0 R >> /AcroForm << /Fields [4 0 R 5 0 R 6 0 R] /SigFlags 3 /DA (/Helv 0 Tf 0 g) /DR << /Font << /Helv 7 0 R >> >> >> >>"},
+		[]string{"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+		[]string{"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Annots [4 0 R 5 0 R 6 0 R] /Contents 8 0 R >>"},
+		[]string{"<< /FT /Tx /T (name) /V (Old) /Type /Annot /Subtype /Widget /Rect [10 150 110 170] /P 3 0 R /DA (/Helv 12 Tf 0 g) /AP << /N 9 0 R >> >>"},
+		[]string{"<< /FT /Btn /T (agree) /V /Off /AS /Off /Type /Annot /Subtype /Widget /Rect [10 120 22 132] /P 3 0 R /AP << /N << /Yes 10 0 R /Off 11 0 R >> >> >>"},
+		[]string{"<< /FT /Tx /T (id) /V (42) /Ff 1 /Type /Annot /Subtype /Widget /Rect [10 90 110 110] /P 3 0 R /DA (/Helv 12 Tf 0 g) >>"},
+		[]string{"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"},
+		[]string{"", "0 0 m 10 10 l S"},
+		[]string{"/Type /XObject /Subtype /Form /BBox [0 0 100 20]", "BT /Helv 12 Tf 2 5 Td (Old) Tj ET"},
+		[]string{"/Type /XObject /Subtype /Form /BBox [0 0 12 12]", "0 0 12 12 re f"},
+		[]string{"/Type /XObject /Subtype /Form /BBox [0 0 12 12]", "0 0 m 12 12 l S"},
+		[]string{"<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /adbe.pkcs7.detached /ByteRange [0 0 0 0] /Contents <00> >>"},
+	)
+}
+
+// formValues returns the values of the fields of a PDF form by name
+func formValues(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	result, err := ListPDFFormFields(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ListPDFFormFields: %v", err)
+	}
+	values := map[string]interface{}{}
+	for _, field := range result.Fields {
+		values[field.Name] = field.Value
+	}
+	return values
+}
+
+func TestListPDFFormFields(t *testing.T) {
+	result, err := ListPDFFormFields(bytes.NewReader(testFormPDF()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FieldCount != 3 {
+		t.Fatalf("FieldCount = %d, want 3", result.FieldCount)
+	}
+	want := []struct {
+		name, fieldType string
+		value           interface{}
+		locked          bool
+	}{
+		{"name", formFieldText, "Old", false},
+		{"agree", formFieldCheckBox, false, false},
+		{"id", formFieldText, "42", true},
+	}
+	for i, w := range want {
+		f := result.Fields[i]
+		if f.Name != w.name || f.Type != w.fieldType || f.Value != w.value || f.Locked != w.locked || !reflect.DeepEqual(f.Pages, []int{1}) {
+			t.Errorf("field %d = %+v, want %s %s %v locked %v on page 1", i, f, w.name, w.fieldType, w.value, w.locked)
+		}
+	}
+
+	if _, err := ListPDFFormFields(bytes.NewReader(testPDF(t, 1))); !errorIsType(err, &utils.InvalidInputError{}) {
+		t.Errorf("ListPDFFormFields without a form = %v, want invalid input", err)
+	}
+}
+
+func TestFillPDFForm(t *testing.T) {
+	data, err := FillPDFForm(bytes.NewReader(testFormPDF()), model.FormOptions{Fields: `{"name": "New", "agree": true}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"name": "New", "agree": true, "id": "42"}
+	if got := formValues(t, data); !reflect.DeepEqual(got, want) {
+		t.Errorf("filled values = %v, want %v", got, want)
+	}
+
+	for _, fields := range []string{`{"id": "7"}`, `{"missing": "x"}`, `{"agree": "maybe"}`, `["name"]`} {
+		if _, err := FillPDFForm(bytes.NewReader(testFormPDF()), model.FormOptions{Fields: fields}); !errorIsType(err, &utils.InvalidInputError{}) {
+			t.Errorf("FillPDFForm(%s) = %v, want invalid input", fields, err)
+		}
+	}
+}
+
+func TestFlattenPDFForm(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+	}{
+		{"flatten only", ""},
+		{"fill and flatten", `{"agree": true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := FillPDFForm(bytes.NewReader(testFormPDF()), model.FormOptions{Fields: tt.fields, Flatten: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, err := readPDF(bytes.NewReader(data), pdfmodel.VALIDATE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := ctx.RootDict.Find("AcroForm"); ok {
+				t.Error("the flattened PDF still has a form")
+			}
+			if _, ok := ctx.RootDict.Find("Perms"); ok {
+				t.Error("the flattened PDF still has the signature permissions")
+			}
+			pageDict, _, _, err := ctx.PageDict(1, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := pageDict.Find("Annots"); ok {
+				t.Error("the flattened page still has widgets")
+			}
+			content, err := ctx.PageContent(pageDict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The read-only field has no appearance, so only the other two are drawn
+			if got := strings.Count(string(content), " Do Q"); got != 2 {
+				t.Errorf("the page draws %d appearances, want 2:\n%s", got, content)
+			}
+			if !strings.HasPrefix(string(content), "q\n") || !strings.Contains(string(content), "0 0 m 10 10 l S") {
+				t.Errorf("the original content is not kept in its own graphics state:\n%s", content)
+			}
+		})
+	}
+}
+
+func TestAppendPageContent(t *testing.T) {
+	data := buildPDF(
+		[]string{"<< /Type /Catalog /Pages 2 0 R >>"},
+		[]string{"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+		[]string{"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents [4 0 R 5 0 R] >>"},
+		[]string{"", "1 0 0 RG"},
+		[]string{"", "0 0 m 10 10 l S"},
+	)
+	ctx, err := readPDF(bytes.NewReader(data), pdfmodel.VALIDATE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageDict, _, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := appendPageContent(ctx, pageDict, []byte("/Fm0 Do\n")); err != nil {
+		t.Fatal(err)
+	}
+	if contents := pageDict.ArrayEntry("Contents"); len(contents) != 4 {
+		t.Fatalf("Contents = %v, want the two streams wrapped by two new ones", contents)
+	}
+	content, err := ctx.PageContent(pageDict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pdfcpu joins the streams without the whitespace a viewer puts between them
+	if want := "q\n1 0 0 RG0 0 m 10 10 l SQ\n/Fm0 Do\n"; string(content) != want {
+		t.Errorf("page content = %q, want %q", content, want)
+	}
+}
+
+func TestWidgetAppearanceMatrix(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []float64
+	}{
+		{"bounding box matches", "/BBox [0 0 100 20]", []float64{1, 0, 0, 1, 10, 150}},
+		{"scaled and offset bounding box", "/BBox [10 10 60 20]", []float64{2, 0, 0, 2, -10, 130}},
+		// The matrix rotates the box by 90 degrees onto [-20 0 0 100]
+		{"rotated by the form matrix", "/BBox [0 0 100 20] /Matrix [0 1 -1 0 0 0]", []float64{5, 0, 0, 0.2, 110, 150}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildPDF(
+				[]string{"<< /Type /Catalog /Pages 2 0 R >>"},
+				[]string{"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+				[]string{"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents 5 0 R >>"},
+				[]string{"/Type /XObject /Subtype /Form " + tt.stream, "0 0 m 1 1 l S"},
+				[]string{"", "0 0 m 10 10 l S"},
+			)
+			ctx, err := readPDF(bytes.NewReader(data), pdfmodel.VALIDATE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			widget := types.Dict{
+				"Rect": types.NewNumberArray(10, 150, 110, 170),
+				"AP":   types.Dict{"N": *types.NewIndirectRef(4, 0)},
+			}
+			appearance, matrix, err := widgetAppearance(ctx, widget, nil)
+			if err != nil || appearance == nil {
+				t.Fatalf("widgetAppearance = %v, %v", appearance, err)
+			}
+			for i := range tt.want {
+				if math.Abs(matrix[i]-tt.want[i]) > 1e-9 {
+					t.Fatalf("matrix = %v, want %v", matrix, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFormFieldValue(t *testing.T) {
+	date := &PDFFormField{Type: formFieldDate, Format: "yyyy-mm-dd"}
+	radio := &PDFFormField{Type: formFieldRadio, Options: []string{"a", "b"}}
+	combo := &PDFFormField{Type: formFieldComboBox, Options: []string{"a", "b"}}
+	list := &PDFFormField{Type: formFieldListBox, Options: []string{"a", "b"}}
+	multi := &PDFFormField{Type: formFieldListBox, Options: []string{"a", "b"}, Multi: true}
+
+	tests := []struct {
+		name    string
+		field   *PDFFormField
+		value   interface{}
+		want    []string
+		wantErr bool
+	}{
+		{"text", &PDFFormField{Type: formFieldText}, "hello", []string{"hello"}, false},
+		{"text cleared", &PDFFormField{Type: formFieldText}, nil, []string{""}, false},
+		{"text number", &PDFFormField{Type: formFieldText}, json.Number("12.5"), []string{"12.5"}, false},
+		{"text list", &PDFFormField{Type: formFieldText}, []interface{}{"a"}, nil, true},
+		{"checked", &PDFFormField{Type: formFieldCheckBox}, "yes", []string{"t"}, false},
+		{"unchecked", &PDFFormField{Type: formFieldCheckBox}, false, []string{"f"}, false},
+		{"checkbox text", &PDFFormField{Type: formFieldCheckBox}, "maybe", nil, true},
+		{"radio option", radio, "b", []string{"b"}, false},
+		{"radio other", radio, "c", nil, true},
+		{"combo option", combo, "a", []string{"a"}, false},
+		{"combo other", combo, "c", nil, true},
+		{"editable combo", &PDFFormField{Type: formFieldComboBox, Options: []string{"a"}, Editable: true}, "c", []string{"c"}, false},
+		{"list single", list, "a", []string{"a"}, false},
+		{"list several", list, []interface{}{"a", "b"}, nil, true},
+		{"multi several", multi, []interface{}{"a", "b"}, []string{"a", "b"}, false},
+		{"multi other", multi, []interface{}{"a", "c"}, nil, true},
+		{"date", date, "2024-05-01", []string{"2024-05-01"}, false},
+		{"date other format", date, "01.05.2024", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formFieldValue(tt.field, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("formFieldValue() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("formFieldValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// defaultHeaderSize is the font size of headers and footers in points
+const defaultHeaderSize = 10
+
+// headerPercent is a literal percent sign in the text of a pdfcpu stamp
+const headerPercent = "%%\u200d"
+
+// headerPercentPattern matches literal percent signs whose joiner is not needed, those not followed by a character
+// pdfcpu reads as a placeholder
+var headerPercentPattern = regexp.MustCompile("%%\u200d([^%pPtv]|$)")
+
+// defaultHeaderMargin is the distance of headers and footers from the page edge in points
+const defaultHeaderMargin = 20
+
+// HeaderFooterPDF handles the header and footer request for an uploaded PDF
+func HeaderFooterPDF(file io.Reader, opts model.HeaderFooterOptions) response.APIResponse {
+	data, err := AddPDFHeaderFooter(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("Adding PDF header and footer failed", err)
+	}
+	return response.NewSuccessResponse("PDF header and footer added successfully", data)
+}
+
+// AddPDFHeaderFooter stamps the header at the top and the footer at the bottom of every page of a PDF
+func AddPDFHeaderFooter(file io.Reader, opts model.HeaderFooterOptions) ([]byte, error) {
+	if opts.Header == "" && opts.Footer == "" {
+		return nil, &utils.InvalidInputError{Msg: "a header or footer text is required"}
+	}
+
+	ctx, err := readPDF(file, pdfmodel.ADDWATERMARKS)
+	if err != nil {
+		return nil, err
+	}
+
+	// A skipped first page stays in the set as false, an empty set would stamp every page
+	selected := types.IntSet{}
+	for page := 1; page <= ctx.PageCount; page++ {
+		selected[page] = !opts.SkipFirst || page > 1
+	}
+
+	for _, band := range []struct {
+		text string
+		top  bool
+	}{{opts.Header, true}, {opts.Footer, false}} {
+		if band.text == "" {
+			continue
+		}
+		wm, err := headerStamp(band.text, band.top, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err := api.WatermarkContext(ctx, selected, wm); err != nil {
+			return nil, fmt.Errorf("failed to add header and footer: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// headerFooterConvertedPDF adds the requested header and footer to a PDF generated by a conversion
+func headerFooterConvertedPDF(data []byte, opts model.ConvertOptions) ([]byte, error) {
+	if opts.Header == "" && opts.Footer == "" {
+		return data, nil
+	}
+	return AddPDFHeaderFooter(bytes.NewReader(data), opts.HeaderFooterOptions)
+}
+
+// headerStamp builds the text stamp of a header or footer, anchored at the top or bottom edge of the page
+func headerStamp(text string, top bool, opts model.HeaderFooterOptions) (*pdfmodel.Watermark, error) {
+	fontName, err := stampFont(opts.Font)
+	if err != nil {
+		return nil, err
+	}
+
+	size := opts.FontSize
+	if size == 0 {
+		size = defaultHeaderSize
+	}
+	margin := float64(defaultHeaderMargin)
+	if opts.Margin != nil {
+		margin = *opts.Margin
+	}
+
+	// The stamp is anchored at the page corner or edge centre and moved inwards by the margin
+	position, dx := "c", 0.0
+	switch opts.Align {
+	case "left":
+		position, dx = "l", margin
+	case "right":
+		position, dx = "r", -margin
+	}
+	dy := margin
+	if top {
+		position, dy = "t"+position, -margin
+	} else {
+		position = "b" + position
+	}
+
+	params := []string{
+		"fontname:" + fontName,
+		fmt.Sprintf("points:%d", size),
+		"scalefactor:1 abs",
+		"rotation:0",
+		"position:" + position,
+		fmt.Sprintf("offset:%g %g", dx, dy),
+	}
+	if opts.Color != "" {
+		if !hexColorPattern.MatchString(opts.Color) {
+			return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("invalid header colour %q, expected #rrggbb", opts.Color)}
+		}
+		params = append(params, "fillcolor:#"+strings.TrimPrefix(opts.Color, "#"))
+	}
+
+	wm, err := api.TextWatermark(headerText(text, opts.Filename), strings.Join(params, ", "), true, false, types.POINTS)
+	if err != nil {
+		return nil, &utils.InvalidInputError{Msg: "invalid header or footer: " + err.Error()}
+	}
+	return wm, nil
+}
+
+// headerText resolves the {filename} and {date} placeholders of a header or footer and turns {page} and {pages}
+// into the pdfcpu placeholders filled in on every page. pdfcpu drops a lone % and, after %%, still reads the next
+// character as a placeholder, so a literal percent sign becomes %% and is followed by a zero-width joiner where the
+// next character would otherwise be taken as one. Core fonts show the joiner as a space, hence it is only added there.
+func headerText(text, filename string) string {
+	text = strings.NewReplacer(
+		"%", headerPercent,
+		"{page}", "%p",
+		"{pages}", "%P",
+		"{filename}", strings.ReplaceAll(filename, "%", headerPercent),
+		"{date}", time.Now().Format("2006-01-02"),
+	).Replace(text)
+	return headerPercentPattern.ReplaceAllString(text, "%%$1")
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/format"
+	"synth.com/file_converter/internal/model"
+)
+
+func TestHeaderText(t *testing.T) {
+	tests := []struct {
+		text, filename string
+		want           string // As stamped on page 3 of 7
+	}{
+		{"Page {page} of {pages}", "report.pdf", "Page 3 of 7"},
+		{"{filename}", "report.pdf", "report.pdf"},
+		{"50% off", "", "50% off"},
+		{"50%% off", "", "50%% off"},
+		{"100%", "", "100%"},
+		{"%p and %P", "", "%p and %P"},
+		{"%%p", "", "%%p"},
+		{"{page}%", "", "3%"},
+		{"{filename} {page}", "a%p%P.pdf", "a%p%P.pdf 3"},
+		{"{date}", "", time.Now().Format("2006-01-02")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			stamped, _ := format.Text(headerText(tt.text, tt.filename), "", 3, 7)
+			if got := strings.ReplaceAll(stamped, "\u200d", ""); got != tt.want {
+				t.Errorf("headerText(%q, %q) stamps %q, want %q", tt.text, tt.filename, got, tt.want)
+			}
+		})
+	}
+
+	// The joiner is only kept where pdfcpu would otherwise read a placeholder
+	if text := headerText("50% off", ""); strings.Contains(text, "\u200d") {
+		t.Errorf("headerText(%q) = %q, want no zero-width joiner", "50% off", text)
+	}
+}
+
+func TestAddPDFHeaderFooterPercent(t *testing.T) {
+	opts := model.HeaderFooterOptions{Header: "50% of {page}", Footer: "{filename}", Filename: "100%p.pdf"}
+	if _, err := AddPDFHeaderFooter(bytes.NewReader(testPDF(t, 2)), opts); err != nil {
+		t.Fatalf("AddPDFHeaderFooter: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	_ "golang.org/x/image/tiff" // CCITT fax images are extracted as TIFF
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// PDFImageInfo describes an image extracted from a PDF
+type PDFImageInfo struct {
+	File       string `json:"file"`
+	Page       int    `json:"page"`  // First page using the image
+	Pages      []int  `json:"pages"` // Every selected page using the image
+	Name       string `json:"name"`  // Resource name on the page
+	Object     int    `json:"object"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Format     string `json:"format"`
+	ColorSpace string `json:"color_space,omitempty"`
+}
+
+// ExtractPDFImages returns the images embedded in the selected pages of a PDF as a ZIP with a manifest.json.
+// Images keep their native format (jpg, png, tif, jpx) unless opts.ImageFormat asks for them to be transcoded.
+func ExtractPDFImages(file io.Reader, opts model.ConvertOptions) ([]byte, error) {
+	ctx, err := readPDFWithPassword(file, pdfmodel.EXTRACTIMAGES, opts.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := selectPDFPages(ctx.PageCount, opts.Pages)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []utils.ZipEntry
+	manifest := []PDFImageInfo{}
+	// Images shared between pages, such as logos, are stored once
+	seen := make(map[int]int)
+	for _, pageNr := range pages {
+		images, err := pdfcpu.ExtractPageImages(ctx, pageNr, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract images from page %d: %w", pageNr, err)
+		}
+		// Only stubs carry the dimensions and colour space of the image dictionaries
+		stubs, err := pdfcpu.ExtractPageImages(ctx, pageNr, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract images from page %d: %w", pageNr, err)
+		}
+
+		// Map iteration order is random, keep the output stable by object number
+		objNrs := make([]int, 0, len(images))
+		for objNr := range images {
+			objNrs = append(objNrs, objNr)
+		}
+		sort.Ints(objNrs)
+
+		for _, objNr := range objNrs {
+			img, stub := images[objNr], stubs[objNr]
+			// Page thumbnails are previews generated by the writer, not content
+			if img.Thumb {
+				continue
+			}
+			if i, ok := seen[objNr]; ok {
+				manifest[i].Pages = append(manifest[i].Pages, pageNr)
+				continue
+			}
+
+			format := img.FileType
+			var data []byte
+			// There is no JPEG 2000 decoder, so JPX images are always returned as they are. Transcoded images
+			// keep their native resolution.
+			if opts.ImageFormat != "" && img.FileType != "jpx" {
+				format = opts.ImageFormat
+				var decoded image.Image
+				if decoded, _, err = decodeImage(img); err == nil {
+					data, err = encodeImage(decoded, format)
+				}
+			} else {
+				data, err = io.ReadAll(img)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract image %s on page %d: %w", img.Name, pageNr, err)
+			}
+
+			info := PDFImageInfo{
+				File:       fmt.Sprintf("page_%03d_obj_%d.%s", pageNr, objNr, format),
+				Page:       pageNr,
+				Pages:      []int{pageNr},
+				Name:       img.Name,
+				Object:     objNr,
+				Width:      stub.Width,
+				Height:     stub.Height,
+				Format:     format,
+				ColorSpace: stub.Cs,
+			}
+			seen[objNr] = len(manifest)
+			manifest = append(manifest, info)
+			entries = append(entries, utils.ZipEntry{Name: info.File, Data: data})
+		}
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write image manifest: %w", err)
+	}
+	entries = append(entries, utils.ZipEntry{Name: "manifest.json", Data: manifestData})
+	return utils.CreateZip(entries)
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/signintech/gopdf"
+	"synth.com/file_converter/internal/model"
+)
+
+func TestExtractPDFImagesKeepsResolution(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1200, 900))
+	for x := 0; x < 1200; x++ {
+		src.Set(x, x%900, color.RGBA{R: 255, A: 255})
+	}
+	pdf := gopdf.GoPdf{}
+	pdf.Start(gopdf.Config{PageSize: *gopdf.PageSizeA4})
+	pdf.AddPage()
+	if err := pdf.ImageFrom(src, 50, 50, &gopdf.Rect{W: 400, H: 300}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := pdf.GetBytesPdfReturnErr()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ExtractPDFImages(bytes.NewReader(data), model.ConvertOptions{ImageFormat: "png"})
+	if err != nil {
+		t.Fatalf("ExtractPDFImages() error = %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	var manifest []PDFImageInfo
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil || len(manifest) != 1 {
+		t.Fatalf("manifest = %s, %v", files["manifest.json"], err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(files[manifest[0].File]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 1200 || cfg.Height != 900 || manifest[0].Width != cfg.Width || manifest[0].Height != cfg.Height {
+		t.Errorf("extracted %dx%d, manifest %dx%d, want 1200x900", cfg.Width, cfg.Height, manifest[0].Width, manifest[0].Height)
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// MergePDFs handles the merge request for an ordered set of uploaded PDFs and images
+func MergePDFs(files []model.File, opts model.MergeOptions) response.APIResponse {
+	data, err := MergeFilesToPDF(files, opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF merge failed", err)
+	}
+	return response.NewSuccessResponse("PDF merged successfully", data)
+}
+
+// MergeFilesToPDF merges the uploaded files, in upload order, into a single PDF. Images and SVGs become pages
+// through ConvertToPDF; with opts.Bookmarks every file gets a bookmark to its first page, under which its own
+// outline is kept.
+func MergeFilesToPDF(files []model.File, opts model.MergeOptions) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, &utils.InvalidInputError{Msg: "no files uploaded"}
+	}
+
+	conf := pdfmodel.NewDefaultConfiguration()
+	conf.Cmd = pdfmodel.MERGECREATE
+	conf.ValidationMode = pdfmodel.ValidationRelaxed
+	conf.CreateBookmarks = opts.Bookmarks
+
+	var dest *pdfmodel.Context
+	for _, f := range files {
+		data, err := uploadedFileToPDF(f)
+		if err != nil {
+			return nil, err
+		}
+
+		// Read through the shared path so encrypted, corrupt and oversized inputs are reported like any other upload
+		ctx, err := readPDFWithConfig(bytes.NewReader(data), conf)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Filename, err)
+		}
+
+		title := strings.TrimSuffix(f.Filename, filepath.Ext(f.Filename))
+		if dest == nil {
+			dest = ctx
+			if opts.Bookmarks {
+				if err := pdfcpu.EnsureOutlines(dest, title, false); err != nil {
+					return nil, fmt.Errorf("%s: failed to add bookmark: %w", f.Filename, err)
+				}
+			}
+			dest.EnsureVersionForWriting()
+		} else {
+			if dest.XRefTable.Version() < pdfmodel.V20 && ctx.XRefTable.Version() == pdfmodel.V20 {
+				return nil, &utils.InvalidInputError{Msg: f.Filename + " is a PDF 2.0 file, which cannot be merged into older versions"}
+			}
+			if err := pdfcpu.MergeXRefTables(title, ctx, dest, false, false); err != nil {
+				return nil, fmt.Errorf("%s: failed to merge: %w", f.Filename, err)
+			}
+		}
+
+		if err := checkPDFPages(dest.PageCount); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := api.OptimizeContext(dest); err != nil {
+		return nil, fmt.Errorf("failed to optimize merged PDF: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(dest, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write merged PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// uploadedFileToPDF returns an uploaded PDF as is and converts images to a single page PDF
+func uploadedFileToPDF(f model.File) ([]byte, error) {
+	file, err := f.FileContent.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Filename, err)
+	}
+	defer file.Close()
+
+	var data []byte
+	switch strings.ToLower(filepath.Ext(f.Filename)) {
+	case ".pdf":
+		data, err = io.ReadAll(file)
+	case ".png", ".jpg", ".jpeg", ".webp", ".gif", ".avif", ".heic", ".heif":
+		data, err = ConvertToPDF(file, f.Filename)
+	case ".svg":
+		data, err = ConvertSVG(file, "pdf", model.ConvertOptions{})
+	default:
+		return nil, &utils.InvalidInputError{Msg: f.Filename + " is neither a PDF nor an image"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Filename, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+func TestMergeFilesToPDF(t *testing.T) {
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxPDFPages = 4
+
+	data, err := MergeFilesToPDF(testUploadFiles(t, testFile{"a.pdf", testPDF(t, 2)}, testFile{"b.pdf", testPDF(t, 1)}), model.MergeOptions{})
+	if err != nil {
+		t.Fatalf("MergeFilesToPDF() error = %v", err)
+	}
+	if pages, err := api.PageCount(bytes.NewReader(data), nil); err != nil || pages != 3 {
+		t.Fatalf("merged PDF has %d pages (%v), want 3", pages, err)
+	}
+
+	tests := []struct {
+		name    string
+		files   []testFile
+		wantErr interface{}
+	}{
+		{"no files", nil, &utils.InvalidInputError{}},
+		{"corrupt input", []testFile{{"a.pdf", testPDF(t, 1)}, {"b.pdf", []byte("%PDF-1.4\ngarbage")}}, &utils.CorruptFileError{}},
+		{"input over the page limit", []testFile{{"a.pdf", testPDF(t, 5)}}, &utils.LimitExceededError{}},
+		{"merged over the page limit", []testFile{{"a.pdf", testPDF(t, 3)}, {"b.pdf", testPDF(t, 2)}}, &utils.LimitExceededError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MergeFilesToPDF(testUploadFiles(t, tt.files...), model.MergeOptions{})
+			if !errorIsType(err, tt.wantErr) {
+				t.Fatalf("MergeFilesToPDF() error = %v, want %T", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergeFilesToPDFBookmarks(t *testing.T) {
+	img, err := encodeImage(testColorGradient(), "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := testUploadFiles(t, testFile{"report.pdf", testPDF(t, 2)}, testFile{"appendix.pdf", testPDF(t, 1)}, testFile{"photo.png", img})
+	data, err := MergeFilesToPDF(files, model.MergeOptions{Bookmarks: true})
+	if err != nil {
+		t.Fatalf("MergeFilesToPDF() error = %v", err)
+	}
+
+	outline, err := ReadPDFOutline(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outline.PageCount != 4 {
+		t.Errorf("merged PDF has %d pages, want 4", outline.PageCount)
+	}
+	// Every file is bookmarked by its name without the extension at its first page
+	want := []PDFBookmark{{Title: "report", Page: 1}, {Title: "appendix", Page: 3}, {Title: "photo", Page: 4}}
+	if !reflect.DeepEqual(outline.Bookmarks, want) {
+		t.Errorf("bookmarks = %+v, want %+v", outline.Bookmarks, want)
+	}
+
+	// Without the option no outline is added
+	data, err = MergeFilesToPDF(testUploadFiles(t, testFile{"a.pdf", testPDF(t, 1)}, testFile{"b.pdf", testPDF(t, 1)}), model.MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outline, err := ReadPDFOutline(bytes.NewReader(data)); err != nil || len(outline.Bookmarks) != 0 {
+		t.Errorf("merged PDF without bookmarks has the outline %+v (%v)", outline, err)
+	}
+}
@@ -0,0 +1,668 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// pdfProducer is the producer recorded in PDFs generated by conversions
+const pdfProducer = "file_converter"
+
+// XML namespaces of the XMP properties mirroring the document information
+const (
+	xmpNamespaceRDF    = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmpNamespaceDC     = "http://purl.org/dc/elements/1.1/"
+	xmpNamespaceXMP    = "http://ns.adobe.com/xap/1.0/"
+	xmpNamespacePDF    = "http://ns.adobe.com/pdf/1.3/"
+	xmpNamespacePDFAID = "http://www.aiim.org/pdfa/ns/id/"
+)
+
+// DocumentMetadata holds the document information of a PDF and the XMP metadata of its catalog
+type DocumentMetadata struct {
+	Title        string       `json:"title,omitempty"`
+	Author       string       `json:"author,omitempty"`
+	Subject      string       `json:"subject,omitempty"`
+	Keywords     string       `json:"keywords,omitempty"`
+	Creator      string       `json:"creator,omitempty"`
+	Producer     string       `json:"producer,omitempty"`
+	CreationDate string       `json:"creation_date,omitempty"`
+	ModDate      string       `json:"mod_date,omitempty"`
+	XMP          *XMPMetadata `json:"xmp,omitempty"`
+}
+
+// XMPMetadata holds the XMP properties corresponding to the document information and the PDF/A identification
+type XMPMetadata struct {
+	Title           string   `json:"title,omitempty"`
+	Authors         []string `json:"authors,omitempty"`
+	Subject         string   `json:"subject,omitempty"`
+	Keywords        string   `json:"keywords,omitempty"`
+	Creator         string   `json:"creator,omitempty"`
+	Producer        string   `json:"producer,omitempty"`
+	CreationDate    string   `json:"creation_date,omitempty"`
+	ModDate         string   `json:"mod_date,omitempty"`
+	PDFAPart        string   `json:"pdfa_part,omitempty"`
+	PDFAConformance string   `json:"pdfa_conformance,omitempty"`
+}
+
+// ProcessPDFMetadata handles the metadata request for an uploaded PDF, returning the metadata as JSON
+// or, when fields are given, the PDF with the fields updated
+func ProcessPDFMetadata(file io.Reader, opts model.MetadataOptions) response.APIResponse {
+	if !metadataUpdateRequested(opts) {
+		metadata, err := ReadPDFMetadata(file)
+		if err != nil {
+			return newConversionErrorResponse("Reading PDF metadata failed", err)
+		}
+		return response.NewSuccessResponse("PDF metadata read successfully", metadata)
+	}
+
+	data, err := UpdatePDFMetadata(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("Updating PDF metadata failed", err)
+	}
+	return response.NewSuccessResponse("PDF metadata updated successfully", data)
+}
+
+// ReadPDFMetadata reads the document information dictionary and the XMP metadata of a PDF
+func ReadPDFMetadata(file io.Reader) (*DocumentMetadata, error) {
+	ctx, err := readPDFForMetadata(file, "")
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &DocumentMetadata{}
+	info, err := pdfInfoDict(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for key, field := range map[string]*string{
+		"Title":    &metadata.Title,
+		"Author":   &metadata.Author,
+		"Subject":  &metadata.Subject,
+		"Keywords": &metadata.Keywords,
+		"Creator":  &metadata.Creator,
+		"Producer": &metadata.Producer,
+	} {
+		*field = pdfInfoText(ctx, info, key)
+	}
+	metadata.CreationDate = pdfInfoDate(ctx, info, "CreationDate")
+	metadata.ModDate = pdfInfoDate(ctx, info, "ModDate")
+
+	content, err := catalogXMP(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if content != nil {
+		// Unreadable XMP is left out rather than failing the whole request
+		if xmp, err := parseXMP(content); err == nil {
+			metadata.XMP = xmp
+		}
+	}
+	return metadata, nil
+}
+
+// UpdatePDFMetadata sets or clears the requested document information fields. The changes are appended to the
+// original file as an incremental update, so everything else, including the producer and creation date unless they
+// are given, stays as it is. The matching properties of existing XMP metadata are updated in place.
+func UpdatePDFMetadata(file io.Reader, opts model.MetadataOptions) ([]byte, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	return updatePDFMetadata(data, opts, "")
+}
+
+// updatePDFMetadata updates the metadata of a PDF like UpdatePDFMetadata. Encrypted PDFs are only updated when the
+// owner password is given, which is the case for PDFs the service encrypted itself.
+func updatePDFMetadata(data []byte, opts model.MetadataOptions, ownerPassword string) ([]byte, error) {
+	ctx, err := readPDFForMetadata(bytes.NewReader(data), ownerPassword)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Encrypt != nil && ownerPassword == "" {
+		return nil, &utils.InvalidInputError{Msg: "the metadata of encrypted PDFs cannot be changed, decrypt it first"}
+	}
+
+	var creationDate string
+	if opts.CreationDate != nil && *opts.CreationDate != "" {
+		t, err := parseMetadataDate(*opts.CreationDate)
+		if err != nil {
+			return nil, err
+		}
+		creationDate = types.DateString(t)
+	}
+
+	// Start from the current dictionary to keep entries such as Trapped and custom properties
+	info := types.NewDict()
+	current, err := pdfInfoDict(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		info = current.Clone().(types.Dict)
+	}
+	for key, value := range map[string]*string{
+		"Title":    opts.Title,
+		"Author":   opts.Author,
+		"Subject":  opts.Subject,
+		"Keywords": opts.Keywords,
+		"Creator":  opts.Creator,
+		"Producer": opts.Producer,
+	} {
+		if value == nil {
+			continue
+		}
+		if *value == "" {
+			info.Delete(key)
+			continue
+		}
+		s, err := pdfTextString(*value)
+		if err != nil {
+			return nil, err
+		}
+		info.Update(key, s)
+	}
+	if opts.CreationDate != nil {
+		if creationDate == "" {
+			info.Delete("CreationDate")
+		} else {
+			info.Update("CreationDate", types.StringLiteral(creationDate))
+		}
+	}
+	info.Update("ModDate", types.StringLiteral(types.DateString(time.Now())))
+
+	ctx.Write.Increment = true
+	ctx.Write.Offset = ctx.Read.FileSize
+	ctx.WriteObjectStream = false
+	ctx.WriteXRefStream = ctx.Read.UsingXRefStreams
+
+	// Replace the information dictionary in place, or add one
+	if ctx.Info != nil {
+		objNr := ctx.Info.ObjectNumber.Value()
+		ctx.Table[objNr].Object = info
+		ctx.Write.IncrementWithObjNr(objNr)
+	} else {
+		indRef, err := ctx.IndRefForNewObject(info)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add document information: %w", err)
+		}
+		ctx.Info = indRef
+		ctx.Write.IncrementWithObjNr(indRef.ObjectNumber.Value())
+	}
+
+	if err := updateCatalogXMP(ctx, opts, info); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data)
+	if err := api.WriteIncrement(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// describeGeneratedPDF records the producer, the creation date and the requested title and author in a PDF
+// generated by a conversion. Rewriting a PDF records pdfcpu as its producer, so this comes after the other steps.
+func describeGeneratedPDF(data []byte, opts model.ConvertOptions) ([]byte, error) {
+	producer := pdfProducer
+	creationDate := time.Now().Format(time.RFC3339)
+	metadata := model.MetadataOptions{Producer: &producer, CreationDate: &creationDate}
+	if opts.Title != "" {
+		metadata.Title = &opts.Title
+	}
+	if opts.Author != "" {
+		metadata.Author = &opts.Author
+	}
+	return updatePDFMetadata(data, metadata, "")
+}
+
+// recordPDFProducer records the service as the producer of a PDF it encrypted with the given owner password
+func recordPDFProducer(data []byte, ownerPassword string) ([]byte, error) {
+	producer := pdfProducer
+	return updatePDFMetadata(data, model.MetadataOptions{Producer: &producer}, ownerPassword)
+}
+
+// metadataUpdateRequested reports whether a metadata request gives any field to change
+func metadataUpdateRequested(opts model.MetadataOptions) bool {
+	for _, value := range []*string{opts.Title, opts.Author, opts.Subject, opts.Keywords, opts.Creator, opts.Producer, opts.CreationDate} {
+		if value != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// readPDFForMetadata reads a PDF without optimizing it, so the object numbers match the file for incremental updates
+func readPDFForMetadata(file io.Reader, ownerPassword string) (*pdfmodel.Context, error) {
+	conf := pdfmodel.NewDefaultConfiguration()
+	conf.Cmd = pdfmodel.ADDPROPERTIES
+	conf.Optimize = false
+	conf.OwnerPW = ownerPassword
+	return readPDFWithConfig(file, conf)
+}
+
+// pdfInfoDict returns the document information dictionary of a PDF, or nil when it has none
+func pdfInfoDict(ctx *pdfmodel.Context) (types.Dict, error) {
+	if ctx.Info == nil {
+		return nil, nil
+	}
+	info, err := ctx.DereferenceDict(*ctx.Info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document information: %w", err)
+	}
+	return info, nil
+}
+
+// pdfInfoText returns a text entry of the document information, empty when it is missing or unreadable
+func pdfInfoText(ctx *pdfmodel.Context, info types.Dict, key string) string {
+	obj, ok := info.Find(key)
+	if !ok {
+		return ""
+	}
+	s, err := ctx.DereferenceText(obj)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(s)
+}
+
+// pdfInfoDate returns a date entry of the document information as RFC 3339, or as found when it cannot be parsed
+func pdfInfoDate(ctx *pdfmodel.Context, info types.Dict, key string) string {
+	s := pdfInfoText(ctx, info, key)
+	if t, ok := types.DateTime(s, true); ok {
+		return t.Format(time.RFC3339)
+	}
+	return s
+}
+
+// pdfTextString encodes a text string for the document information, as PDFDocEncoding where possible and UTF-16 otherwise
+func pdfTextString(s string) (types.StringLiteral, error) {
+	encoded := s
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			encoded = types.EncodeUTF16String(s)
+			break
+		}
+	}
+	escaped, err := types.Escape(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %q: %w", s, err)
+	}
+	return types.StringLiteral(*escaped), nil
+}
+
+// parseMetadataDate parses a creation date given as RFC 3339 timestamp or as date
+func parseMetadataDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, &utils.InvalidInputError{Msg: fmt.Sprintf("invalid creation date %q, expected an RFC 3339 timestamp or YYYY-MM-DD", s)}
+}
+
+// catalogXMP returns the decoded XMP metadata stream of the document catalog, or nil when there is none
+func catalogXMP(ctx *pdfmodel.Context) ([]byte, error) {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document catalog: %w", err)
+	}
+	obj, ok := root.Find("Metadata")
+	if !ok {
+		return nil, nil
+	}
+	sd, _, err := ctx.DereferenceStreamDict(obj)
+	if err != nil || sd == nil {
+		return nil, nil
+	}
+	if err := sd.Decode(); err != nil {
+		return nil, nil
+	}
+	return sd.Content, nil
+}
+
+// parseXMP extracts the document information properties and the PDF/A identification from an XMP packet. Properties
+// are read from elements as well as from the attributes of rdf:Description, the two forms XMP allows for simple values.
+func parseXMP(content []byte) (*XMPMetadata, error) {
+	xmp := &XMPMetadata{}
+	simple := map[xml.Name]*string{
+		{Space: xmpNamespacePDF, Local: "Keywords"}:       &xmp.Keywords,
+		{Space: xmpNamespacePDF, Local: "Producer"}:       &xmp.Producer,
+		{Space: xmpNamespaceXMP, Local: "CreatorTool"}:    &xmp.Creator,
+		{Space: xmpNamespaceXMP, Local: "CreateDate"}:     &xmp.CreationDate,
+		{Space: xmpNamespaceXMP, Local: "ModifyDate"}:     &xmp.ModDate,
+		{Space: xmpNamespacePDFAID, Local: "part"}:        &xmp.PDFAPart,
+		{Space: xmpNamespacePDFAID, Local: "conformance"}: &xmp.PDFAConformance,
+	}
+	// Language alternatives keep their first entry, which by convention is x-default
+	alternatives := map[xml.Name]*string{
+		{Space: xmpNamespaceDC, Local: "title"}:       &xmp.Title,
+		{Space: xmpNamespaceDC, Local: "description"}: &xmp.Subject,
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var property xml.Name
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space == xmpNamespaceRDF && t.Name.Local == "Description" {
+				for _, attr := range t.Attr {
+					if field, ok := simple[attr.Name]; ok {
+						*field = strings.TrimSpace(attr.Value)
+					}
+				}
+				continue
+			}
+			if t.Name.Space != xmpNamespaceRDF {
+				property = t.Name
+			}
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			text.Reset()
+			switch {
+			case t.Name.Space == xmpNamespaceRDF && t.Name.Local == "li":
+				if property == (xml.Name{Space: xmpNamespaceDC, Local: "creator"}) {
+					xmp.Authors = append(xmp.Authors, value)
+				} else if field, ok := alternatives[property]; ok && *field == "" {
+					*field = value
+				}
+			case t.Name == property:
+				if field, ok := simple[property]; ok {
+					*field = value
+				}
+				property = xml.Name{}
+			}
+		}
+	}
+	return xmp, nil
+}
+
+// xmpProperty is an XMP property mirroring a document information field
+type xmpProperty struct {
+	space, prefix, local string
+	container            string // Alt or Seq for array values, empty for simple values
+}
+
+// XMP properties updated together with the document information
+var (
+	xmpTitle        = xmpProperty{xmpNamespaceDC, "dc", "title", "Alt"}
+	xmpCreator      = xmpProperty{xmpNamespaceDC, "dc", "creator", "Seq"}
+	xmpDescription  = xmpProperty{xmpNamespaceDC, "dc", "description", "Alt"}
+	xmpKeywords     = xmpProperty{xmpNamespacePDF, "pdf", "Keywords", ""}
+	xmpProducer     = xmpProperty{xmpNamespacePDF, "pdf", "Producer", ""}
+	xmpCreatorTool  = xmpProperty{xmpNamespaceXMP, "xmp", "CreatorTool", ""}
+	xmpCreateDate   = xmpProperty{xmpNamespaceXMP, "xmp", "CreateDate", ""}
+	xmpModifyDate   = xmpProperty{xmpNamespaceXMP, "xmp", "ModifyDate", ""}
+	xmpMetadataDate = xmpProperty{xmpNamespaceXMP, "xmp", "MetadataDate", ""}
+)
+
+// emptyXMPPacket is the XMP packet unreadable metadata is replaced by before the properties are added
+const emptyXMPPacket = "<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+	"<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n" +
+	" <rdf:RDF xmlns:rdf=\"" + xmpNamespaceRDF + "\">\n" +
+	"  <rdf:Description rdf:about=\"\">\n" +
+	"  </rdf:Description>\n" +
+	" </rdf:RDF>\n" +
+	"</x:xmpmeta>\n" +
+	"<?xpacket end=\"w\"?>"
+
+// element renders the property with the given values as an element declaring its namespace
+func (p xmpProperty) element(values []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "   <%s:%s xmlns:%s=%q>", p.prefix, p.local, p.prefix, p.space)
+	if p.container == "" {
+		b.WriteString(xmlText(values[0]))
+	} else {
+		lang := ""
+		if p.container == "Alt" {
+			lang = ` xml:lang="x-default"`
+		}
+		fmt.Fprintf(&b, "<rdf:%s>", p.container)
+		for _, value := range values {
+			fmt.Fprintf(&b, "<rdf:li%s>%s</rdf:li>", lang, xmlText(value))
+		}
+		fmt.Fprintf(&b, "</rdf:%s>", p.container)
+	}
+	fmt.Fprintf(&b, "</%s:%s>\n", p.prefix, p.local)
+	return b.String()
+}
+
+// updateCatalogXMP updates the XMP metadata of the catalog, if it has any, with the requested fields and the new
+// modification date. Only those properties are replaced within the existing packet; every other property, extension
+// schemas and the PDF/A identification stay as they are.
+func updateCatalogXMP(ctx *pdfmodel.Context, opts model.MetadataOptions, info types.Dict) error {
+	root, err := ctx.Catalog()
+	if err != nil {
+		return fmt.Errorf("failed to read document catalog: %w", err)
+	}
+	obj, ok := root.Find("Metadata")
+	if !ok {
+		return nil
+	}
+	// Metadata embedded directly in the catalog is left alone, it cannot be replaced without rewriting the catalog
+	indRef, ok := obj.(types.IndirectRef)
+	if !ok {
+		return nil
+	}
+
+	// Requested fields follow the document information, cleared fields are removed
+	changes := map[xmpProperty][]string{}
+	for property, value := range map[xmpProperty]*string{
+		xmpTitle:       opts.Title,
+		xmpCreator:     opts.Author,
+		xmpDescription: opts.Subject,
+		xmpKeywords:    opts.Keywords,
+		xmpCreatorTool: opts.Creator,
+		xmpProducer:    opts.Producer,
+	} {
+		if value != nil {
+			changes[property] = nil
+			if *value != "" {
+				changes[property] = []string{*value}
+			}
+		}
+	}
+	if opts.CreationDate != nil {
+		changes[xmpCreateDate] = nil
+		if date := pdfInfoDate(ctx, info, "CreationDate"); date != "" {
+			changes[xmpCreateDate] = []string{date}
+		}
+	}
+	modDate := pdfInfoDate(ctx, info, "ModDate")
+	changes[xmpModifyDate] = []string{modDate}
+	changes[xmpMetadataDate] = []string{modDate}
+
+	current, err := catalogXMP(ctx)
+	var content []byte
+	if err == nil && current != nil {
+		content, err = editXMP(current, changes)
+	}
+	if err != nil || current == nil {
+		// Unreadable metadata is replaced by a packet of the requested fields
+		if content, err = editXMP([]byte(emptyXMPPacket), changes); err != nil {
+			return fmt.Errorf("failed to write XMP metadata: %w", err)
+		}
+	}
+
+	length := int64(len(content))
+	dict := types.NewDict()
+	dict.InsertName("Type", "Metadata")
+	dict.InsertName("Subtype", "XML")
+	dict.InsertInt("Length", len(content))
+	// XMP stays uncompressed so that tools unaware of PDF can find it
+	sd := types.StreamDict{Dict: dict, Content: content, Raw: content, StreamLength: &length}
+
+	objNr := indRef.ObjectNumber.Value()
+	ctx.Table[objNr].Object = sd
+	ctx.Write.IncrementWithObjNr(objNr)
+	return nil
+}
+
+// editXMP replaces properties of the top-level descriptions of an XMP packet, given as elements or as attributes,
+// leaving the rest of the packet untouched. New values are added to the first description; properties without values
+// are only removed.
+func editXMP(content []byte, changes map[xmpProperty][]string) ([]byte, error) {
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	changed := func(name xml.Name) bool {
+		for property := range changes {
+			if property.space == name.Space && property.local == name.Local {
+				return true
+			}
+		}
+		return false
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	var stack []xml.Name
+	var scopes []map[string]string // Namespace prefixes in scope of the open elements
+	propertyStart, propertyDepth := -1, 0
+	inserted := false
+	for {
+		start := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		end := int(decoder.InputOffset())
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			scope := map[string]string{}
+			if len(scopes) > 0 {
+				for prefix, space := range scopes[len(scopes)-1] {
+					scope[prefix] = space
+				}
+			}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" {
+					scope[attr.Name.Local] = attr.Value
+				}
+			}
+
+			inDescription := len(stack) >= 2 && isXMPDescription(stack[len(stack)-1]) && stack[len(stack)-2] == (xml.Name{Space: xmpNamespaceRDF, Local: "RDF"})
+			switch {
+			case isXMPDescription(t.Name) && len(stack) > 0 && stack[len(stack)-1] == (xml.Name{Space: xmpNamespaceRDF, Local: "RDF"}):
+				tag := string(content[start:end])
+				for _, attr := range t.Attr {
+					if changed(attr.Name) {
+						tag = removeXMLAttribute(tag, scope, attr.Name)
+					}
+				}
+				// A self-closing description is opened up to take the new properties
+				if !inserted && strings.HasSuffix(tag, "/>") {
+					qualified := strings.Fields(tag[1:])[0]
+					tag = strings.TrimSuffix(tag, "/>") + ">\n" + renderXMPChanges(changes) + "  </" + qualified + ">"
+					inserted = true
+				}
+				if tag != string(content[start:end]) {
+					edits = append(edits, edit{start, end, tag})
+				}
+			case inDescription && propertyStart < 0 && changed(t.Name):
+				propertyStart, propertyDepth = start, len(stack)
+			}
+			stack = append(stack, t.Name)
+			scopes = append(scopes, scope)
+
+		case xml.EndElement:
+			stack, scopes = stack[:len(stack)-1], scopes[:len(scopes)-1]
+			switch {
+			case propertyStart >= 0 && len(stack) == propertyDepth:
+				// The property is removed together with the indentation before it
+				lineStart := propertyStart
+				for lineStart > 0 && (content[lineStart-1] == ' ' || content[lineStart-1] == '\t') {
+					lineStart--
+				}
+				if end < len(content) && content[end] == '\n' {
+					end++
+				}
+				edits = append(edits, edit{lineStart, end, ""})
+				propertyStart = -1
+			case !inserted && start < end && isXMPDescription(t.Name) && len(stack) > 0 && stack[len(stack)-1] == (xml.Name{Space: xmpNamespaceRDF, Local: "RDF"}):
+				lineStart := start
+				for lineStart > 0 && (content[lineStart-1] == ' ' || content[lineStart-1] == '\t') {
+					lineStart--
+				}
+				edits = append(edits, edit{lineStart, lineStart, renderXMPChanges(changes)})
+				inserted = true
+			}
+		}
+	}
+	if !inserted {
+		return nil, fmt.Errorf("the XMP packet has no rdf:Description")
+	}
+
+	// Edits are found in document order and never overlap, so they are applied from the back
+	result := append([]byte{}, content...)
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		result = append(result[:e.start], append([]byte(e.text), result[e.end:]...)...)
+	}
+	return result, nil
+}
+
+// isXMPDescription reports whether an element is an rdf:Description
+func isXMPDescription(name xml.Name) bool {
+	return name == xml.Name{Space: xmpNamespaceRDF, Local: "Description"}
+}
+
+// renderXMPChanges renders the changed properties that have values, in a stable order
+func renderXMPChanges(changes map[xmpProperty][]string) string {
+	var b strings.Builder
+	for _, property := range []xmpProperty{xmpTitle, xmpCreator, xmpDescription, xmpKeywords, xmpProducer, xmpCreatorTool, xmpCreateDate, xmpModifyDate, xmpMetadataDate} {
+		if values := changes[property]; len(values) > 0 {
+			b.WriteString(property.element(values))
+		}
+	}
+	return b.String()
+}
+
+// removeXMLAttribute removes an attribute from the text of a start tag, whatever prefix its namespace is bound to
+func removeXMLAttribute(tag string, scope map[string]string, name xml.Name) string {
+	for prefix, space := range scope {
+		if space != name.Space {
+			continue
+		}
+		pattern := regexp.MustCompile(`\s+` + regexp.QuoteMeta(prefix+":"+name.Local) + `\s*=\s*("[^"]*"|'[^']*')`)
+		tag = pattern.ReplaceAllString(tag, "")
+	}
+	return tag
+}
+
+// xmlText escapes a value for use as XML character data
+func xmlText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/model"
+)
+
+// pdfaXMP is the XMP packet of a PDF/A file with an extension schema and a property given as attribute
+const pdfaXMP = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/" pdfaid:part="2" pdfaid:conformance="B"/>
+  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:p="http://ns.adobe.com/pdf/1.3/" xmlns:ext="http://example.com/ext/" p:Producer="Old Producer">
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Old Title</rdf:li></rdf:Alt></dc:title>
+   <dc:creator><rdf:Seq><rdf:li>Old Author</rdf:li></rdf:Seq></dc:creator>
+   <ext:Project>Apollo</ext:Project>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func TestEditXMP(t *testing.T) {
+	tests := []struct {
+		name    string
+		packet  string
+		changes map[xmpProperty][]string
+		want    XMPMetadata
+		keep    []string // Text that must survive the edit
+		drop    []string // Text that must be gone after the edit
+	}{
+		{
+			name:    "replace element and attribute",
+			packet:  pdfaXMP,
+			changes: map[xmpProperty][]string{xmpTitle: {"New & Title"}, xmpProducer: {"New Producer"}},
+			want:    XMPMetadata{Title: "New & Title", Authors: []string{"Old Author"}, Producer: "New Producer", PDFAPart: "2", PDFAConformance: "B"},
+			keep:    []string{"<ext:Project>Apollo</ext:Project>", `pdfaid:part="2"`},
+			drop:    []string{"Old Title", "Old Producer"},
+		},
+		{
+			name:    "remove cleared property",
+			packet:  pdfaXMP,
+			changes: map[xmpProperty][]string{xmpCreator: nil, xmpModifyDate: {"2024-05-01T10:00:00Z"}},
+			want:    XMPMetadata{Title: "Old Title", Producer: "Old Producer", ModDate: "2024-05-01T10:00:00Z", PDFAPart: "2", PDFAConformance: "B"},
+			keep:    []string{"<ext:Project>Apollo</ext:Project>"},
+			drop:    []string{"Old Author", "dc:creator"},
+		},
+		{
+			name:    "empty packet",
+			packet:  emptyXMPPacket,
+			changes: map[xmpProperty][]string{xmpTitle: {"Title"}, xmpCreator: {"Author"}, xmpKeywords: {"a, b"}},
+			want:    XMPMetadata{Title: "Title", Authors: []string{"Author"}, Keywords: "a, b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := editXMP([]byte(tt.packet), tt.changes)
+			if err != nil {
+				t.Fatalf("editXMP: %v", err)
+			}
+			got, err := parseXMP(content)
+			if err != nil {
+				t.Fatalf("parseXMP of the edited packet: %v\n%s", err, content)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("metadata = %+v, want %+v", *got, tt.want)
+			}
+			for _, s := range tt.keep {
+				if !strings.Contains(string(content), s) {
+					t.Errorf("edited packet lost %q:\n%s", s, content)
+				}
+			}
+			for _, s := range tt.drop {
+				if strings.Contains(string(content), s) {
+					t.Errorf("edited packet still contains %q:\n%s", s, content)
+				}
+			}
+		})
+	}
+}
+
+func TestEditXMPWithoutDescription(t *testing.T) {
+	packet := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"/></x:xmpmeta>`
+	if _, err := editXMP([]byte(packet), map[xmpProperty][]string{xmpTitle: {"Title"}}); err == nil {
+		t.Error("editXMP succeeded on a packet without rdf:Description")
+	}
+}
+
+func TestFinishGeneratedPDFMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		opts model.ConvertOptions
+	}{
+		{"plain", model.ConvertOptions{Title: "Report"}},
+		{"footer", model.ConvertOptions{Title: "Report", HeaderFooterOptions: model.HeaderFooterOptions{Footer: "{page}"}}},
+		{"encrypted", model.ConvertOptions{Title: "Report", HeaderFooterOptions: model.HeaderFooterOptions{Footer: "{page}"},
+			EncryptionOptions: model.EncryptionOptions{UserPassword: "user"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := finishGeneratedPDF(testPDF(t, 2), tt.opts)
+			if err != nil {
+				t.Fatalf("finishGeneratedPDF: %v", err)
+			}
+			ctx, err := readPDFWithPassword(bytes.NewReader(data), pdfmodel.VALIDATE, tt.opts.UserPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := pdfInfoDict(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pdfInfoText(ctx, info, "Producer"); got != pdfProducer {
+				t.Errorf("Producer = %q, want %q", got, pdfProducer)
+			}
+			if got := pdfInfoText(ctx, info, "Title"); got != "Report" {
+				t.Errorf("Title = %q, want %q", got, "Report")
+			}
+		})
+	}
+}
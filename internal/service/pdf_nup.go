@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// defaultNUp is the number of pages per sheet side when neither n nor a grid is given
+const defaultNUp = 2
+
+var (
+	gridPattern      = regexp.MustCompile(`^([1-9][0-9]?)x([1-9][0-9]?)$`)
+	sheetDimsPattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)x([0-9]+(?:\.[0-9]+)?)$`)
+	paperNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+// ImposePDF handles the N-up or booklet layout request for an uploaded PDF
+func ImposePDF(file io.Reader, opts model.ImposeOptions) response.APIResponse {
+	data, err := NUpPDF(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF layout failed", err)
+	}
+	return response.NewSuccessResponse("PDF layout created successfully", data)
+}
+
+// NUpPDF places the selected pages on sheets of the given size, several per sheet side in a grid, or in saddle-stitch
+// booklet order where the sheets printed double-sided, folded and stacked read in page order
+func NUpPDF(file io.Reader, opts model.ImposeOptions) ([]byte, error) {
+	nup, err := nupConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := pdfmodel.NUP
+	if opts.Booklet {
+		cmd = pdfmodel.BOOKLET
+	}
+	ctx, err := readPDFWithPassword(file, cmd, opts.Password)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := selectPDFPages(ctx.PageCount, opts.Pages)
+	if err != nil {
+		return nil, err
+	}
+	selected := types.IntSet{}
+	for _, page := range pages {
+		selected[page] = true
+	}
+
+	// The sheets are added to the document and the original pages removed
+	if opts.Booklet {
+		err = pdfcpu.BookletFromPDF(ctx, selected, nup)
+	} else {
+		err = pdfcpu.NUpFromPDF(ctx, selected, nup)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lay out pages: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// nupConfig translates the layout options into a pdfcpu N-up configuration. The grid of n pages follows the
+// orientation of the sheet, so 2-up on a portrait sheet stacks the pages and on a landscape sheet puts them side by side.
+func nupConfig(opts model.ImposeOptions) (*pdfmodel.NUp, error) {
+	var cols, rows int
+	if opts.Grid != "" {
+		if opts.Booklet {
+			return nil, &utils.InvalidInputError{Msg: "booklets are laid out by n, a grid cannot be given"}
+		}
+		if opts.N != 0 {
+			return nil, &utils.InvalidInputError{Msg: "n and grid cannot be combined"}
+		}
+		m := gridPattern.FindStringSubmatch(strings.ToLower(opts.Grid))
+		if m == nil {
+			return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("invalid grid %q, expected columns x rows such as 3x2", opts.Grid)}
+		}
+		cols, _ = strconv.Atoi(m[1])
+		rows, _ = strconv.Atoi(m[2])
+	}
+
+	// pdfcpu reads the layout from its own parameter syntax, which also validates it
+	var params []string
+	if opts.Sheet != "" {
+		param, err := sheetParam(opts.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+	}
+	if opts.Margin != nil {
+		params = append(params, "margin:"+strconv.FormatFloat(*opts.Margin, 'f', -1, 64))
+	}
+	if opts.Border != nil {
+		params = append(params, "border:"+strconv.FormatBool(*opts.Border))
+	}
+	if opts.Order != "" {
+		params = append(params, "orientation:"+opts.Order)
+	}
+	if opts.Booklet {
+		if opts.Binding != "" {
+			params = append(params, "binding:"+opts.Binding)
+		}
+		params = append(params, "guides:"+strconv.FormatBool(opts.Guides))
+	}
+	desc := strings.Join(params, ", ")
+
+	n := opts.N
+	if n == 0 {
+		n = defaultNUp
+	}
+	var (
+		nup *pdfmodel.NUp
+		err error
+	)
+	switch {
+	case opts.Booklet:
+		nup, err = pdfcpu.PDFBookletConfig(n, desc, nil)
+	case cols > 0:
+		nup, err = pdfcpu.PDFGridConfig(rows, cols, desc, nil)
+		if err == nil {
+			// A pdfcpu grid enlarges the sheet to fit the pages at their own size, the grid goes on the sheet given instead
+			nup.PageGrid = false
+		}
+	default:
+		nup, err = pdfcpu.PDFNUpConfig(n, desc, nil)
+	}
+	if err != nil {
+		return nil, &utils.InvalidInputError{Msg: "invalid layout: " + strings.TrimPrefix(err.Error(), "pdfcpu: ")}
+	}
+	return nup, nil
+}
+
+// sheetParam turns a paper size such as A4, A3L or Letter, or width x height in points, into a pdfcpu layout parameter
+func sheetParam(sheet string) (string, error) {
+	if m := sheetDimsPattern.FindStringSubmatch(strings.ToLower(sheet)); m != nil {
+		return fmt.Sprintf("dimensions:%s %s", m[1], m[2]), nil
+	}
+	if !paperNamePattern.MatchString(sheet) {
+		return "", &utils.InvalidInputError{Msg: fmt.Sprintf("invalid sheet size %q, expected a paper size such as A4 or width x height in points", sheet)}
+	}
+	name := strings.TrimSuffix(strings.TrimSuffix(sheet, "L"), "P")
+	if _, ok := types.PaperSize[name]; !ok {
+		return "", &utils.InvalidInputError{Msg: fmt.Sprintf("unknown sheet size %q", sheet)}
+	}
+	return "formsize:" + sheet, nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+func TestNUpConfig(t *testing.T) {
+	margin, border := 10.0, false
+	tests := []struct {
+		name       string
+		opts       model.ImposeOptions
+		wantCols   float64
+		wantRows   float64
+		wantErr    interface{}
+		checkNUp   func(*pdfmodel.NUp) bool
+		checkLabel string
+	}{
+		{"default stacks two pages on a portrait sheet", model.ImposeOptions{}, 1, 2, nil, nil, ""},
+		{"2-up on a landscape sheet", model.ImposeOptions{N: 2, Sheet: "A4L"}, 2, 1, nil, nil, ""},
+		{"4-up", model.ImposeOptions{N: 4}, 2, 2, nil, nil, ""},
+		{"grid", model.ImposeOptions{Grid: "3x2"}, 3, 2, nil,
+			func(nup *pdfmodel.NUp) bool { return !nup.PageGrid }, "grid on the sheet"},
+		{"grid on a sheet", model.ImposeOptions{Grid: "1X4", Sheet: "A3"}, 1, 4, nil,
+			func(nup *pdfmodel.NUp) bool { return nup.PageDim != nil && nup.PageDim.Height > nup.PageDim.Width }, "A3 portrait sheet"},
+		{"landscape sheet", model.ImposeOptions{N: 4, Sheet: "A4L"}, 2, 2, nil,
+			func(nup *pdfmodel.NUp) bool { return nup.PageDim != nil && nup.PageDim.Width > nup.PageDim.Height }, "landscape sheet"},
+		{"sheet in points", model.ImposeOptions{N: 2, Sheet: "800x400"}, 2, 1, nil,
+			func(nup *pdfmodel.NUp) bool {
+				return nup.PageDim != nil && nup.PageDim.Width == 800 && nup.PageDim.Height == 400
+			}, "800x400 sheet"},
+		{"margin, border and order", model.ImposeOptions{N: 4, Margin: &margin, Border: &border, Order: "dl"}, 2, 2, nil,
+			func(nup *pdfmodel.NUp) bool {
+				return nup.Margin == 10 && !nup.Border && nup.Orient == pdfmodel.DownLeft
+			}, "margin 10, no border, down then left"},
+		{"booklet", model.ImposeOptions{Booklet: true, N: 4, Binding: "short", Guides: true}, 2, 2, nil,
+			func(nup *pdfmodel.NUp) bool { return nup.BookletGuides && nup.BookletBinding == pdfmodel.ShortEdge }, "short edge binding with guides"},
+		{"grid and n", model.ImposeOptions{Grid: "3x2", N: 4}, 0, 0, &utils.InvalidInputError{}, nil, ""},
+		{"grid and booklet", model.ImposeOptions{Grid: "2x2", Booklet: true}, 0, 0, &utils.InvalidInputError{}, nil, ""},
+		{"invalid grid", model.ImposeOptions{Grid: "0x2"}, 0, 0, &utils.InvalidInputError{}, nil, ""},
+		{"malformed grid", model.ImposeOptions{Grid: "3 by 2"}, 0, 0, &utils.InvalidInputError{}, nil, ""},
+		{"booklet n", model.ImposeOptions{Booklet: true, N: 3}, 0, 0, &utils.InvalidInputError{}, nil, ""},
+		{"unknown sheet", model.ImposeOptions{Sheet: "B99"}, 0, 0, &utils.InvalidInputError{}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nup, err := nupConfig(tt.opts)
+			if !errorIsType(err, tt.wantErr) {
+				t.Fatalf("nupConfig(%+v) error = %v, want %T", tt.opts, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if nup.Grid.Width != tt.wantCols || nup.Grid.Height != tt.wantRows {
+				t.Errorf("grid = %vx%v, want %vx%v", nup.Grid.Width, nup.Grid.Height, tt.wantCols, tt.wantRows)
+			}
+			if tt.checkNUp != nil && !tt.checkNUp(nup) {
+				t.Errorf("configuration %s, want %s", nup, tt.checkLabel)
+			}
+		})
+	}
+}
+
+func TestSheetParam(t *testing.T) {
+	tests := []struct {
+		sheet   string
+		want    string
+		wantErr interface{}
+	}{
+		{"A4", "formsize:A4", nil},
+		{"A3L", "formsize:A3L", nil},
+		{"LetterP", "formsize:LetterP", nil},
+		{"595x842", "dimensions:595 842", nil},
+		{"612.5X792", "dimensions:612.5 792", nil},
+		{"B99", "", &utils.InvalidInputError{}},
+		{"A4, margin:100", "", &utils.InvalidInputError{}},
+		{"x842", "", &utils.InvalidInputError{}},
+	}
+	for _, tt := range tests {
+		got, err := sheetParam(tt.sheet)
+		if !errorIsType(err, tt.wantErr) {
+			t.Errorf("sheetParam(%q) error = %v, want %T", tt.sheet, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("sheetParam(%q) = %q, want %q", tt.sheet, got, tt.want)
+		}
+	}
+}
+
+func TestNUpPDF(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      model.ImposeOptions
+		wantPages int
+	}{
+		// Five pages on 4-up sheets leave a second sheet with one page
+		{"4-up", model.ImposeOptions{N: 4}, 2},
+		{"grid", model.ImposeOptions{Grid: "3x1", Sheet: "A4L"}, 2},
+		{"selected pages", model.ImposeOptions{N: 2, Pages: "1-4"}, 2},
+		// A 4-page booklet sheet holds 4 pages per side, so 5 pages pad to 8 on one sheet
+		{"booklet", model.ImposeOptions{Booklet: true, N: 4}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := NUpPDF(bytes.NewReader(testPDF(t, 5)), tt.opts)
+			if err != nil {
+				t.Fatalf("NUpPDF: %v", err)
+			}
+			ctx, err := readPDF(bytes.NewReader(data), pdfmodel.VALIDATE)
+			if err != nil {
+				t.Fatalf("reading the laid out PDF: %v", err)
+			}
+			if ctx.PageCount != tt.wantPages {
+				t.Errorf("PageCount = %d, want %d", ctx.PageCount, tt.wantPages)
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/nfnt/resize"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+)
+
+// defaultOptimizeDPI is the resolution images are downsampled to when none is given
+const defaultOptimizeDPI = 150
+
+// defaultOptimizeQuality is the JPEG quality images are recompressed with when none is given
+const defaultOptimizeQuality = 75
+
+// OptimizePDF handles the optimization request for an uploaded PDF, reporting the sizes in response headers
+func OptimizePDF(file io.Reader, opts model.OptimizeOptions) response.APIResponse {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return newConversionErrorResponse("PDF optimization failed", err)
+	}
+
+	optimized, err := ShrinkPDF(bytes.NewReader(data), opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF optimization failed", err)
+	}
+	// Never hand back a larger file than the upload
+	if len(optimized) >= len(data) {
+		optimized = data
+	}
+
+	headers := map[string]string{
+		"X-Original-Size":  strconv.Itoa(len(data)),
+		"X-Optimized-Size": strconv.Itoa(len(optimized)),
+	}
+	return response.NewSuccessResponse("PDF optimized successfully", model.ConvertedFile{Content: optimized, Headers: headers})
+}
+
+// ShrinkPDF removes redundant objects, downsamples and recompresses images, drops unused fonts and strips metadata
+func ShrinkPDF(file io.Reader, opts model.OptimizeOptions) ([]byte, error) {
+	conf := pdfmodel.NewDefaultConfiguration()
+	conf.Cmd = pdfmodel.OPTIMIZE
+	conf.OptimizeDuplicateContentStreams = true
+	ctx, err := readPDFWithConfig(file, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := recompressPDFImages(ctx, opts); err != nil {
+		return nil, err
+	}
+	if err := removeUnusedFonts(ctx); err != nil {
+		return nil, err
+	}
+	if !opts.KeepMetadata {
+		if err := stripPDFMetadata(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Objects no longer referenced, such as replaced images and dropped fonts, are not written
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// recompressPDFImages downsamples images to the target DPI and recompresses them as JPEG where that makes them
+// smaller. The resolution of an image is measured against the size of the largest page showing it, so images are
+// never reduced below the target however they are placed. Masks, indexed, CMYK and low bit depth images are kept.
+func recompressPDFImages(ctx *pdfmodel.Context, opts model.OptimizeOptions) error {
+	dpi := opts.DPI
+	if dpi == 0 {
+		dpi = defaultOptimizeDPI
+	}
+	quality := opts.Quality
+	if quality == 0 {
+		quality = defaultOptimizeQuality
+	}
+
+	// Find the largest scale every image may be reduced to over all pages using it
+	scales := make(map[int]float64)
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		_, _, inh, err := ctx.PageDict(pageNr, false)
+		if err != nil {
+			return fmt.Errorf("failed to read page %d: %w", pageNr, err)
+		}
+		stubs, err := pdfcpu.ExtractPageImages(ctx, pageNr, true)
+		if err != nil {
+			return fmt.Errorf("failed to read images of page %d: %w", pageNr, err)
+		}
+
+		for objNr, stub := range stubs {
+			if !recompressibleImage(stub) || inh.MediaBox == nil {
+				continue
+			}
+			// Resolution the image would have when stretched over the whole page
+			dpiX := float64(stub.Width) * 72 / inh.MediaBox.Width()
+			dpiY := float64(stub.Height) * 72 / inh.MediaBox.Height()
+			scales[objNr] = math.Max(scales[objNr], math.Min(1, float64(dpi)/math.Min(dpiX, dpiY)))
+		}
+	}
+
+	for objNr, scale := range scales {
+		if err := recompressPDFImage(ctx, objNr, scale, quality); err != nil {
+			return fmt.Errorf("failed to recompress image object %d: %w", objNr, err)
+		}
+	}
+	return nil
+}
+
+// recompressibleImage reports whether an image can be stored as a JPEG without changing its appearance
+func recompressibleImage(img pdfmodel.Image) bool {
+	if img.Thumb || img.IsImgMask || img.HasImgMask || img.Bpc != 8 {
+		return false
+	}
+	if img.Comp != 1 && img.Comp != 3 {
+		return false
+	}
+	switch img.Filter {
+	case "", filter.Flate, filter.DCT:
+		return true
+	}
+	return false
+}
+
+// recompressPDFImage replaces an image object by a scaled JPEG version of it, if that is smaller
+func recompressPDFImage(ctx *pdfmodel.Context, objNr int, scale float64, quality int) error {
+	indRef := types.NewIndirectRef(objNr, 0)
+	sd, _, err := ctx.DereferenceStreamDict(*indRef)
+	if err != nil || sd == nil {
+		return err
+	}
+	// Decode arrays invert or remap samples, which the decoded image already reflects
+	if _, ok := sd.Find("Decode"); ok {
+		return nil
+	}
+
+	pdfImage, err := pdfcpu.ExtractImage(ctx, sd, false, "", objNr, false)
+	if err != nil || pdfImage == nil {
+		return err
+	}
+	img, _, err := decodeImage(pdfImage)
+	if err != nil {
+		return err
+	}
+
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if scale < 1 {
+		width = max(1, int(math.Round(float64(width)*scale)))
+		height = max(1, int(math.Round(float64(height)*scale)))
+		img = resize.Resize(uint(width), uint(height), img, resize.Lanczos3)
+	}
+
+	// Soft masks stay separate objects, so only the colour channels are encoded
+	var opaque image.Image
+	if pdfImage.Comp == 1 {
+		gray := image.NewGray(img.Bounds())
+		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+				gray.Set(x, y, color.GrayModel.Convert(opaqueColor(img.At(x, y))))
+			}
+		}
+		opaque = gray
+	} else {
+		rgba := image.NewRGBA(img.Bounds())
+		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+				rgba.Set(x, y, opaqueColor(img.At(x, y)))
+			}
+		}
+		opaque = rgba
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, opaque, &jpeg.Options{Quality: quality}); err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	if buf.Len() >= len(sd.Raw) {
+		return nil
+	}
+
+	// Keep the colour space, masks and other entries of the original image dictionary
+	dict := sd.Dict.Clone().(types.Dict)
+	dict.Update("Width", types.Integer(width))
+	dict.Update("Height", types.Integer(height))
+	dict.Update("BitsPerComponent", types.Integer(8))
+	dict.Update("Filter", types.Name(filter.DCT))
+	dict.Delete("DecodeParms")
+	dict.Update("Length", types.Integer(buf.Len()))
+
+	streamLength := int64(buf.Len())
+	replacement := types.StreamDict{
+		Dict:           dict,
+		StreamLength:   &streamLength,
+		Raw:            buf.Bytes(),
+		FilterPipeline: []types.PDFFilter{{Name: filter.DCT}},
+	}
+	ctx.XRefTable.Table[objNr].Object = replacement
+	return nil
+}
+
+// opaqueColor returns the colour of a pixel without its alpha, undoing the premultiplication
+func opaqueColor(c color.Color) color.RGBA {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return color.RGBA{R: n.R, G: n.G, B: n.B, A: 0xff}
+}
+
+// removeUnusedFonts drops fonts from the page resources that the content streams never select. Resources shared
+// between pages keep every font used by any of them, and the form XObjects and annotation appearances drawn on a
+// page count as its content, as they may inherit its resources.
+func removeUnusedFonts(ctx *pdfmodel.Context) error {
+	type fontResources struct {
+		fonts types.Dict
+		used  map[string]bool
+	}
+	shared := make(map[string]*fontResources)
+
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		pageDict, _, _, err := ctx.PageDict(pageNr, false)
+		if err != nil {
+			return fmt.Errorf("failed to read page %d: %w", pageNr, err)
+		}
+
+		// Inherited resources are left alone, they may serve pages of other documents merged into this one
+		resObj, ok := pageDict.Find("Resources")
+		if !ok {
+			continue
+		}
+		resources, err := ctx.DereferenceDict(resObj)
+		if err != nil || resources == nil {
+			continue
+		}
+		fontObj, ok := resources.Find("Font")
+		if !ok {
+			continue
+		}
+		fonts, err := ctx.DereferenceDict(fontObj)
+		if err != nil || fonts == nil {
+			continue
+		}
+
+		// Identify the font dictionary so pages sharing it are considered together
+		key := fmt.Sprintf("page %d", pageNr)
+		if ref, ok := fontObj.(types.IndirectRef); ok {
+			key = fmt.Sprintf("font %d", ref.ObjectNumber.Value())
+		} else if ref, ok := resObj.(types.IndirectRef); ok {
+			key = fmt.Sprintf("resources %d", ref.ObjectNumber.Value())
+		}
+		res, ok := shared[key]
+		if !ok {
+			res = &fontResources{fonts: fonts, used: make(map[string]bool)}
+			shared[key] = res
+		}
+
+		if err := pageContentNames(ctx, pageNr, pageDict, resources, res.used); err != nil {
+			return err
+		}
+	}
+
+	for _, res := range shared {
+		for name := range res.fonts {
+			if !res.used[name] {
+				res.fonts.Delete(name)
+			}
+		}
+	}
+	return nil
+}
+
+// pageContentNames adds the names used as operands in the content of a page to names, including the content of the
+// form XObjects it draws and of its annotation appearances. Any name counts, which errs on the side of keeping fonts.
+func pageContentNames(ctx *pdfmodel.Context, pageNr int, pageDict, resources types.Dict, names map[string]bool) error {
+	r, err := pdfcpu.ExtractPageContent(ctx, pageNr)
+	if err != nil {
+		return fmt.Errorf("failed to read content of page %d: %w", pageNr, err)
+	}
+	if r != nil {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to read content of page %d: %w", pageNr, err)
+		}
+		addContentNames(content, names)
+	}
+
+	visited := make(map[int]bool)
+	// scanForm adds the names of a form stream and of the forms drawn with its own resources
+	var scanForm func(obj types.Object) error
+	scanForms := func(resources types.Dict) error {
+		xobjects, err := ctx.DereferenceDict(resources["XObject"])
+		if err != nil || xobjects == nil {
+			return err
+		}
+		for _, obj := range xobjects {
+			if err := scanForm(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	scanForm = func(obj types.Object) error {
+		if ref, ok := obj.(types.IndirectRef); ok {
+			if visited[ref.ObjectNumber.Value()] {
+				return nil
+			}
+			visited[ref.ObjectNumber.Value()] = true
+		}
+		sd, _, err := ctx.DereferenceStreamDict(obj)
+		if err != nil || sd == nil {
+			return err
+		}
+		if subtype := sd.Subtype(); subtype != nil && *subtype != "Form" {
+			return nil
+		}
+		if err := sd.Decode(); err != nil {
+			return fmt.Errorf("failed to read form on page %d: %w", pageNr, err)
+		}
+		addContentNames(sd.Content, names)
+
+		formResources, err := ctx.DereferenceDict(sd.Dict["Resources"])
+		if err != nil || formResources == nil {
+			return err
+		}
+		return scanForms(formResources)
+	}
+
+	if err := scanForms(resources); err != nil {
+		return err
+	}
+
+	annots, err := ctx.DereferenceArray(pageDict["Annots"])
+	if err != nil {
+		return fmt.Errorf("failed to read annotations of page %d: %w", pageNr, err)
+	}
+	for _, annotObj := range annots {
+		annot, err := ctx.DereferenceDict(annotObj)
+		if err != nil || annot == nil {
+			continue
+		}
+		appearances, err := ctx.DereferenceDict(annot["AP"])
+		if err != nil || appearances == nil {
+			continue
+		}
+		// Each appearance is a form, or a dictionary of forms for the states of the annotation
+		for _, obj := range appearances {
+			if states, err := ctx.DereferenceDict(obj); err == nil && states != nil {
+				for _, state := range states {
+					if err := scanForm(state); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err := scanForm(obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addContentNames adds the name operands of a content stream, also those in arrays, to names
+func addContentNames(content []byte, names map[string]bool) {
+	var add func(operands []interface{})
+	add = func(operands []interface{}) {
+		for _, operand := range operands {
+			switch v := operand.(type) {
+			case pdfName:
+				names[string(v)] = true
+			case []interface{}:
+				add(v)
+			}
+		}
+	}
+	for _, op := range parseContentStream(content) {
+		add(op.Operands)
+	}
+}
+
+// stripPDFMetadata removes the document information, XMP metadata, private application data and page thumbnails
+func stripPDFMetadata(ctx *pdfmodel.Context) error {
+	// The writer recreates a minimal information dictionary with the producer and dates
+	ctx.Info = nil
+
+	root, err := ctx.Catalog()
+	if err != nil {
+		return fmt.Errorf("failed to read document catalog: %w", err)
+	}
+	root.Delete("Metadata")
+	root.Delete("PieceInfo")
+
+	for pageNr := 1; pageNr <= ctx.PageCount; pageNr++ {
+		pageDict, _, _, err := ctx.PageDict(pageNr, false)
+		if err != nil {
+			return fmt.Errorf("failed to read page %d: %w", pageNr, err)
+		}
+		pageDict.Delete("Metadata")
+		pageDict.Delete("PieceInfo")
+		pageDict.Delete("Thumb")
+	}
+	return nil
+}
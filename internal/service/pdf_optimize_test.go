@@ -0,0 +1,132 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/filter"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/model"
+)
+
+// testImagePDF builds a PDF with a 100 by 100 point page showing a Flate-compressed RGB image of the given size, whose
+// noisy samples compress badly
+func testImagePDF(t *testing.T, size int) []byte {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]byte, size*size*3)
+	for i := range samples {
+		samples[i] = byte(i/3%size) + byte(rng.Intn(32))
+	}
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(samples); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+
+	return buildPDF(
+		[]string{"<< /Type /Catalog /Pages 2 0 R >>"},
+		[]string{"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+		[]string{"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Contents 4 0 R /Resources << /XObject << /Im1 5 0 R >> >> >>"},
+		[]string{"", "q 100 0 0 100 0 0 cm /Im1 Do Q"},
+		[]string{fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode", size, size),
+			compressed.String()},
+	)
+}
+
+func TestOptimizePDFRecompressesImages(t *testing.T) {
+	data := testImagePDF(t, 600)
+	resp := OptimizePDF(bytes.NewReader(data), model.OptimizeOptions{})
+	if resp.Status != "success" {
+		t.Fatalf("OptimizePDF failed: %s", resp.Message)
+	}
+	file := resp.Data.(model.ConvertedFile)
+	original, _ := strconv.Atoi(file.Headers["X-Original-Size"])
+	optimized, _ := strconv.Atoi(file.Headers["X-Optimized-Size"])
+	if original != len(data) || optimized != len(file.Content) {
+		t.Errorf("size headers %d and %d, want %d and %d", original, optimized, len(data), len(file.Content))
+	}
+	if optimized >= original {
+		t.Errorf("optimized size %d is not below the original size %d", optimized, original)
+	}
+
+	ctx, err := readPDF(bytes.NewReader(file.Content), pdfmodel.VALIDATE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, err := pdfcpu.ExtractPageImages(ctx, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 1 {
+		t.Fatalf("got %d images, want 1", len(images))
+	}
+	for _, img := range images {
+		// 100 points at the default 150 DPI
+		if img.Filter != filter.DCT || img.Width != 208 || img.Height != 208 {
+			t.Errorf("image is %s %dx%d, want a 208x208 %s image", img.Filter, img.Width, img.Height, filter.DCT)
+		}
+	}
+}
+
+func TestRemoveUnusedFonts(t *testing.T) {
+	// The page draws a form selecting F1 and has an annotation appearance selecting F3, F2 is never used
+	data := buildPDF(
+		[]string{"<< /Type /Catalog /Pages 2 0 R >>"},
+		[]string{"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+		[]string{"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents 4 0 R /Annots [8 0 R]" +
+			" /Resources << /Font << /F1 5 0 R /F2 5 0 R /F3 5 0 R >> /XObject << /X1 6 0 R >> >> >>"},
+		[]string{"", "q /X1 Do Q"},
+		[]string{"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"},
+		[]string{"/Type /XObject /Subtype /Form /BBox [0 0 100 100]", "BT /F1 12 Tf (form) Tj ET"},
+		[]string{"/Type /XObject /Subtype /Form /BBox [0 0 10 10]", "BT [/F3] 0 get 9 Tf ET"},
+		[]string{"<< /Type /Annot /Subtype /Square /Rect [0 0 10 10] /AP << /N 7 0 R >> >>"},
+	)
+	conf := pdfmodel.NewDefaultConfiguration()
+	ctx, err := api.ReadAndValidate(bytes.NewReader(data), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := removeUnusedFonts(ctx); err != nil {
+		t.Fatalf("removeUnusedFonts() error = %v", err)
+	}
+
+	pageDict, _, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fonts := pageDict.DictEntry("Resources").DictEntry("Font")
+	var kept []string
+	for name := range fonts {
+		kept = append(kept, name)
+	}
+	sort.Strings(kept)
+	if got := strings.Join(kept, ","); got != "F1,F3" {
+		t.Errorf("kept fonts %s, want F1,F3", got)
+	}
+}
+
+func TestAddContentNames(t *testing.T) {
+	names := map[string]bool{}
+	addContentNames([]byte("/P <</MCID 0>> BDC BT /F1 12 Tf [(a) /F2] TJ (/F3) Tj ET EMC % /F4\n/Im1 Do"), names)
+	for _, name := range []string{"P", "F1", "F2", "Im1"} {
+		if !names[name] {
+			t.Errorf("name %s missing from %v", name, names)
+		}
+	}
+	// Names in strings and comments are not operands
+	for _, name := range []string{"F3", "F4", "MCID"} {
+		if names[name] {
+			t.Errorf("name %s found in %v", name, names)
+		}
+	}
+}
@@ -0,0 +1,451 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// maxOutlineLevel is the deepest heading level taken into an outline, matching Word's nine heading styles
+const maxOutlineLevel = 9
+
+// headingStylePattern matches the names Word gives its built-in heading styles
+var headingStylePattern = regexp.MustCompile(`(?i)^heading\s*([1-9])$`)
+
+// PDFBookmark is an entry of the outline of a PDF, pointing to a page
+type PDFBookmark struct {
+	Title string        `json:"title"`
+	Page  int           `json:"page"`
+	Kids  []PDFBookmark `json:"kids,omitempty"`
+}
+
+// PDFOutlineResult holds the outline of a PDF
+type PDFOutlineResult struct {
+	PageCount int           `json:"page_count"`
+	Bookmarks []PDFBookmark `json:"bookmarks"`
+}
+
+// ProcessPDFBookmarks handles the bookmark request for an uploaded PDF, returning its outline as JSON or, when an
+// outline is given, the PDF with its outline replaced
+func ProcessPDFBookmarks(file io.Reader, opts model.BookmarkOptions) response.APIResponse {
+	if strings.TrimSpace(opts.Outline) == "" {
+		result, err := ReadPDFOutline(file)
+		if err != nil {
+			return newConversionErrorResponse("Reading PDF bookmarks failed", err)
+		}
+		return response.NewSuccessResponse("PDF bookmarks read successfully", result)
+	}
+
+	data, err := SetPDFOutline(file, opts.Outline)
+	if err != nil {
+		return newConversionErrorResponse("Setting PDF bookmarks failed", err)
+	}
+	return response.NewSuccessResponse("PDF bookmarks set successfully", data)
+}
+
+// ReadPDFOutline returns the bookmarks of a PDF as a tree
+func ReadPDFOutline(file io.Reader) (*PDFOutlineResult, error) {
+	ctx, err := readPDF(file, pdfmodel.LISTBOOKMARKS)
+	if err != nil {
+		return nil, err
+	}
+	bookmarks, err := pdfcpu.Bookmarks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bookmarks: %w", err)
+	}
+	return &PDFOutlineResult{PageCount: ctx.PageCount, Bookmarks: pdfBookmarksFromPDFCPU(bookmarks)}, nil
+}
+
+// SetPDFOutline replaces the outline of a PDF by the one given as JSON, either a list of bookmarks with title, page
+// and optional kids, or an object mapping titles to pages
+func SetPDFOutline(file io.Reader, spec string) ([]byte, error) {
+	bookmarks, err := parseOutline(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, err := readPDF(file, pdfmodel.ADDBOOKMARKS)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOutlinePages(bookmarks, ctx.PageCount, 1); err != nil {
+		return nil, err
+	}
+	if err := replacePDFOutline(ctx, bookmarks); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// addPDFOutline adds an outline to a PDF generated by a conversion
+func addPDFOutline(data []byte, bookmarks []PDFBookmark) ([]byte, error) {
+	if len(bookmarks) == 0 {
+		return data, nil
+	}
+	ctx, err := readPDF(bytes.NewReader(data), pdfmodel.ADDBOOKMARKS)
+	if err != nil {
+		return nil, err
+	}
+	if err := replacePDFOutline(ctx, bookmarks); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// replacePDFOutline removes the outline of a PDF and adds the given bookmarks instead
+func replacePDFOutline(ctx *pdfmodel.Context, bookmarks []PDFBookmark) error {
+	if len(bookmarks) == 0 {
+		if _, err := pdfcpu.RemoveBookmarks(ctx); err != nil {
+			return fmt.Errorf("failed to remove bookmarks: %w", err)
+		}
+		return nil
+	}
+	if err := pdfcpu.AddBookmarks(ctx, pdfcpuBookmarks(bookmarks), true); err != nil {
+		return fmt.Errorf("failed to add bookmarks: %w", err)
+	}
+	return nil
+}
+
+// parseOutline parses a JSON outline. Bookmarks given as an object are ordered by page, keeping the order of the
+// object for titles on the same page.
+func parseOutline(spec string) ([]PDFBookmark, error) {
+	spec = strings.TrimSpace(spec)
+	invalid := func(err error) error {
+		return &utils.InvalidInputError{Msg: "outline must be a JSON list of bookmarks or an object mapping titles to pages: " + err.Error()}
+	}
+
+	if !strings.HasPrefix(spec, "{") {
+		var bookmarks []PDFBookmark
+		if err := json.Unmarshal([]byte(spec), &bookmarks); err != nil {
+			return nil, invalid(err)
+		}
+		return bookmarks, nil
+	}
+
+	// Decode token by token, a map would lose the order of the titles
+	decoder := json.NewDecoder(strings.NewReader(spec))
+	if _, err := decoder.Token(); err != nil {
+		return nil, invalid(err)
+	}
+	bookmarks := []PDFBookmark{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, invalid(err)
+		}
+		var page int
+		if err := decoder.Decode(&page); err != nil {
+			return nil, invalid(err)
+		}
+		bookmarks = append(bookmarks, PDFBookmark{Title: token.(string), Page: page})
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, invalid(err)
+	}
+	sort.SliceStable(bookmarks, func(i, j int) bool {
+		return bookmarks[i].Page < bookmarks[j].Page
+	})
+	return bookmarks, nil
+}
+
+// checkOutlinePages checks that every bookmark points to a page of the document, in page order, and that kids
+// do not point before their parent
+func checkOutlinePages(bookmarks []PDFBookmark, pageCount, first int) error {
+	previous := first
+	for _, bm := range bookmarks {
+		if strings.TrimSpace(bm.Title) == "" {
+			return &utils.InvalidInputError{Msg: "bookmarks need a title"}
+		}
+		if bm.Page < 1 || bm.Page > pageCount {
+			return &utils.InvalidInputError{Msg: fmt.Sprintf("bookmark %q points to page %d, the PDF has %d pages", bm.Title, bm.Page, pageCount)}
+		}
+		if bm.Page < previous {
+			return &utils.InvalidInputError{Msg: fmt.Sprintf("bookmark %q points to page %d, before the bookmark preceding it on page %d", bm.Title, bm.Page, previous)}
+		}
+		if err := checkOutlinePages(bm.Kids, pageCount, bm.Page); err != nil {
+			return err
+		}
+		previous = bm.Page
+	}
+	return nil
+}
+
+// pdfcpuBookmarks converts bookmarks to the form pdfcpu writes them from
+func pdfcpuBookmarks(bookmarks []PDFBookmark) []pdfcpu.Bookmark {
+	result := make([]pdfcpu.Bookmark, 0, len(bookmarks))
+	for _, bm := range bookmarks {
+		result = append(result, pdfcpu.Bookmark{Title: bm.Title, PageFrom: bm.Page, Kids: pdfcpuBookmarks(bm.Kids)})
+	}
+	return result
+}
+
+// pdfBookmarksFromPDFCPU converts bookmarks read by pdfcpu
+func pdfBookmarksFromPDFCPU(bookmarks []pdfcpu.Bookmark) []PDFBookmark {
+	result := make([]PDFBookmark, 0, len(bookmarks))
+	for _, bm := range bookmarks {
+		var kids []PDFBookmark
+		if len(bm.Kids) > 0 {
+			kids = pdfBookmarksFromPDFCPU(bm.Kids)
+		}
+		result = append(result, PDFBookmark{Title: bm.Title, Page: bm.PageFrom, Kids: kids})
+	}
+	return result
+}
+
+// docxHeading is a heading paragraph of a Word document
+type docxHeading struct {
+	Level int
+	Text  string
+}
+
+// docxHeadings returns the headings of a Word document in document order. A paragraph is a heading when its style
+// is one of the built-in heading styles or when it, or its style, has an outline level.
+func docxHeadings(data []byte) ([]docxHeading, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open document archive: %w", err)
+	}
+
+	var styles, document []byte
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/styles.xml":
+			styles, err = readZipFile(f)
+		case "word/document.xml":
+			document, err = readZipFile(f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if document == nil {
+		return nil, &utils.InvalidInputError{Msg: "the document has no word/document.xml"}
+	}
+
+	levels := docxStyleLevels(styles)
+	var headings []docxHeading
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	var text strings.Builder
+	var style string
+	level, depth, inText := 0, 0, false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				// Paragraphs nested in text boxes are read as part of the enclosing paragraph
+				if depth++; depth == 1 {
+					text.Reset()
+					style, level = "", 0
+				}
+			case "pStyle":
+				if depth == 1 {
+					style = xmlAttr(t, "val")
+				}
+			case "outlineLvl":
+				if n, err := strconv.Atoi(xmlAttr(t, "val")); depth == 1 && err == nil && n < maxOutlineLevel {
+					level = n + 1
+				}
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteString(" ")
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if depth--; depth > 0 {
+					continue
+				}
+				if level == 0 {
+					level = levels[style]
+				}
+				title := strings.Join(strings.Fields(text.String()), " ")
+				if level > 0 && title != "" {
+					headings = append(headings, docxHeading{Level: level, Text: title})
+				}
+			}
+		}
+	}
+	return headings, nil
+}
+
+// docxStyleLevels returns the outline level of the heading paragraph styles of a Word document by style id
+func docxStyleLevels(styles []byte) map[string]int {
+	levels := map[string]int{}
+	if styles == nil {
+		return levels
+	}
+
+	basedOn := map[string]string{}
+	decoder := xml.NewDecoder(bytes.NewReader(styles))
+	var id string
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "style":
+			id = ""
+			if xmlAttr(start, "type") == "paragraph" {
+				id = xmlAttr(start, "styleId")
+			}
+		case "name":
+			if m := headingStylePattern.FindStringSubmatch(xmlAttr(start, "val")); id != "" && m != nil {
+				levels[id], _ = strconv.Atoi(m[1])
+			}
+		case "basedOn":
+			if id != "" {
+				basedOn[id] = xmlAttr(start, "val")
+			}
+		case "outlineLvl":
+			if n, err := strconv.Atoi(xmlAttr(start, "val")); id != "" && err == nil && n < maxOutlineLevel {
+				if _, ok := levels[id]; !ok {
+					levels[id] = n + 1
+				}
+			}
+		}
+	}
+
+	// Styles derived from a heading style are headings of the same level
+	for id := range basedOn {
+		if _, ok := levels[id]; ok {
+			continue
+		}
+		seen := map[string]bool{id: true}
+		for parent := basedOn[id]; parent != "" && !seen[parent]; parent = basedOn[parent] {
+			if level, ok := levels[parent]; ok {
+				levels[id] = level
+				break
+			}
+			seen[parent] = true
+		}
+	}
+	return levels
+}
+
+// outlineBuilder collects the pages the headings of a document are rendered on and nests them by level
+type outlineBuilder struct {
+	headings []docxHeading
+	next     int
+	entries  []outlineEntry
+}
+
+// outlineEntry is a heading found on a rendered page
+type outlineEntry struct {
+	level int
+	title string
+	page  int
+}
+
+// match records the page of the next pending heading when the rendered paragraph is that heading. Headings the
+// renderer dropped are skipped once a later one is found.
+func (b *outlineBuilder) match(paragraph string, page int) {
+	text := strings.ToLower(strings.Join(strings.Fields(paragraph), " "))
+	if text == "" {
+		return
+	}
+	for i := b.next; i < len(b.headings); i++ {
+		if strings.ToLower(b.headings[i].Text) == text {
+			b.entries = append(b.entries, outlineEntry{level: b.headings[i].Level, title: b.headings[i].Text, page: page})
+			b.next = i + 1
+			return
+		}
+	}
+}
+
+// bookmarks returns the matched headings as a tree, nesting every heading under the closest preceding heading of a
+// higher level
+func (b *outlineBuilder) bookmarks() []PDFBookmark {
+	var roots []PDFBookmark
+	var path []*[]PDFBookmark
+	var levels []int
+	for _, entry := range b.entries {
+		for len(levels) > 0 && levels[len(levels)-1] >= entry.level {
+			levels = levels[:len(levels)-1]
+			path = path[:len(path)-1]
+		}
+		siblings := &roots
+		if len(path) > 0 {
+			parent := path[len(path)-1]
+			siblings = &(*parent)[len(*parent)-1].Kids
+		}
+		*siblings = append(*siblings, PDFBookmark{Title: entry.title, Page: entry.page})
+		path = append(path, siblings)
+		levels = append(levels, entry.level)
+	}
+	return roots
+}
+
+// readZipFile reads an entry of a zip archive, up to the decompression limit
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	limit := config.AppLimits.MaxDecompressedBytes
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, &utils.LimitExceededError{Msg: fmt.Sprintf("%s expands to more than %d bytes", f.Name, limit)}
+	}
+	return data, nil
+}
+
+// xmlAttr returns the value of an attribute by local name
+func xmlAttr(start xml.StartElement, name string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
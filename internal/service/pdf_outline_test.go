@@ -0,0 +1,199 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/utils"
+)
+
+func TestParseOutline(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    string // Bookmarks as JSON
+		wantErr bool
+	}{
+		{
+			name: "list",
+			spec: `[{"title": "Intro", "page": 1, "kids": [{"title": "Scope", "page": 2}]}, {"title": "End", "page": 5}]`,
+			want: `[{"title":"Intro","page":1,"kids":[{"title":"Scope","page":2}]},{"title":"End","page":5}]`,
+		},
+		{
+			name: "object ordered by page",
+			spec: ` {"Summary": 3, "Cover": 1, "Appendix": 3, "Intro": 2} `,
+			want: `[{"title":"Cover","page":1},{"title":"Intro","page":2},{"title":"Summary","page":3},{"title":"Appendix","page":3}]`,
+		},
+		{name: "empty object", spec: `{}`, want: `[]`},
+		{name: "empty list", spec: `[]`, want: `[]`},
+		{name: "not JSON", spec: `Intro: 1`, wantErr: true},
+		{name: "page not a number", spec: `{"Intro": "one"}`, wantErr: true},
+		{name: "unterminated object", spec: `{"Intro": 1`, wantErr: true},
+		{name: "wrong list entries", spec: `[1, 2]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bookmarks, err := parseOutline(tt.spec)
+			if tt.wantErr {
+				if !errorIsType(err, &utils.InvalidInputError{}) {
+					t.Errorf("parseOutline(%q) error = %v, want invalid input", tt.spec, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseOutline(%q): %v", tt.spec, err)
+			}
+			got, _ := json.Marshal(bookmarks)
+			if string(got) != tt.want {
+				t.Errorf("parseOutline(%q) = %s, want %s", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckOutlinePages(t *testing.T) {
+	tests := []struct {
+		name      string
+		bookmarks []PDFBookmark
+		wantErr   string
+	}{
+		{name: "valid", bookmarks: []PDFBookmark{{Title: "A", Page: 1, Kids: []PDFBookmark{{Title: "A.1", Page: 1}, {Title: "A.2", Page: 3}}}, {Title: "B", Page: 3}}},
+		{name: "empty", bookmarks: nil},
+		{name: "missing title", bookmarks: []PDFBookmark{{Title: " ", Page: 1}}, wantErr: "bookmarks need a title"},
+		{name: "page zero", bookmarks: []PDFBookmark{{Title: "A", Page: 0}}, wantErr: `bookmark "A" points to page 0, the PDF has 4 pages`},
+		{name: "beyond last page", bookmarks: []PDFBookmark{{Title: "A", Page: 5}}, wantErr: `bookmark "A" points to page 5, the PDF has 4 pages`},
+		{
+			name:      "out of order",
+			bookmarks: []PDFBookmark{{Title: "A", Page: 3}, {Title: "B", Page: 2}},
+			wantErr:   `bookmark "B" points to page 2, before the bookmark preceding it on page 3`,
+		},
+		{
+			name:      "kid before parent",
+			bookmarks: []PDFBookmark{{Title: "A", Page: 3, Kids: []PDFBookmark{{Title: "A.1", Page: 2}}}},
+			wantErr:   `bookmark "A.1" points to page 2, before the bookmark preceding it on page 3`,
+		},
+		{
+			name:      "invalid kid",
+			bookmarks: []PDFBookmark{{Title: "A", Page: 1, Kids: []PDFBookmark{{Title: "A.1", Page: 9}}}},
+			wantErr:   `bookmark "A.1" points to page 9, the PDF has 4 pages`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOutlinePages(tt.bookmarks, 4, 1)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkOutlinePages: %v", err)
+				}
+				return
+			}
+			inputErr, ok := err.(*utils.InvalidInputError)
+			if !ok || inputErr.Msg != tt.wantErr {
+				t.Errorf("checkOutlinePages error = %v, want invalid input %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOutlineBuilder(t *testing.T) {
+	b := &outlineBuilder{headings: []docxHeading{
+		{Level: 1, Text: "Introduction"},
+		{Level: 2, Text: "Scope"},
+		{Level: 3, Text: "Dropped"},
+		{Level: 2, Text: "Terms"},
+		{Level: 1, Text: "Results"},
+		{Level: 3, Text: "Raw data"},
+	}}
+	for page, paragraphs := range [][]string{
+		{"", "introduction", "Body text", "Scope"},
+		{"Terms", "Results"},
+		{"Raw   data", "Scope"},
+	} {
+		for _, paragraph := range paragraphs {
+			b.match(paragraph, page+1)
+		}
+	}
+
+	got, _ := json.Marshal(b.bookmarks())
+	want := `[{"title":"Introduction","page":1,"kids":[{"title":"Scope","page":1},{"title":"Terms","page":2}]},` +
+		`{"title":"Results","page":2,"kids":[{"title":"Raw data","page":3}]}]`
+	if string(got) != want {
+		t.Errorf("bookmarks = %s, want %s", got, want)
+	}
+}
+
+// testDOCXStyles defines a heading style, a style derived from it, a style with an outline level and a plain style
+const testDOCXStyles = `<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/></w:style>
+<w:style w:type="paragraph" w:styleId="Heading2"><w:name w:val="heading 2"/></w:style>
+<w:style w:type="paragraph" w:styleId="Chapter"><w:name w:val="Chapter"/><w:basedOn w:val="Heading1"/></w:style>
+<w:style w:type="paragraph" w:styleId="Section"><w:name w:val="Section"/><w:pPr><w:outlineLvl w:val="2"/></w:pPr></w:style>
+<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/></w:style>
+<w:style w:type="character" w:styleId="Heading1Char"><w:name w:val="heading 1"/></w:style>
+</w:styles>`
+
+// testDOCXDocument has paragraphs styled in every way a heading is recognised, and some that are not headings
+const testDOCXDocument = `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Intro</w:t></w:r><w:r><w:t xml:space="preserve">duction </w:t></w:r></w:p>
+<w:p><w:r><w:t>Body text</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Heading2"/></w:pPr><w:r><w:t>Scope</w:t><w:tab/><w:t>and aims</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Chapter"/></w:pPr><w:r><w:t>Derived</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Section"/></w:pPr><w:r><w:t>Styled level</w:t></w:r></w:p>
+<w:p><w:pPr><w:outlineLvl w:val="1"/></w:pPr><w:r><w:t>Direct level</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Quote"/></w:pPr><w:r><w:t>Not a heading</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr></w:p>
+</w:body></w:document>`
+
+// testDOCX returns a Word document archive of the given parts
+func testDOCX(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDocxStyleLevels(t *testing.T) {
+	want := map[string]int{"Heading1": 1, "Heading2": 2, "Chapter": 1, "Section": 3}
+	if got := docxStyleLevels([]byte(testDOCXStyles)); !reflect.DeepEqual(got, want) {
+		t.Errorf("docxStyleLevels() = %v, want %v", got, want)
+	}
+	if got := docxStyleLevels(nil); len(got) != 0 {
+		t.Errorf("docxStyleLevels(nil) = %v, want none", got)
+	}
+}
+
+func TestDocxHeadings(t *testing.T) {
+	data := testDOCX(t, map[string]string{"word/styles.xml": testDOCXStyles, "word/document.xml": testDOCXDocument})
+	headings, err := docxHeadings(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []docxHeading{{1, "Introduction"}, {2, "Scope and aims"}, {1, "Derived"}, {3, "Styled level"}, {2, "Direct level"}}
+	if !reflect.DeepEqual(headings, want) {
+		t.Errorf("docxHeadings() = %+v, want %+v", headings, want)
+	}
+
+	if _, err := docxHeadings(testDOCX(t, map[string]string{"word/styles.xml": testDOCXStyles})); !errorIsType(err, &utils.InvalidInputError{}) {
+		t.Errorf("docxHeadings without a document = %v, want invalid input", err)
+	}
+
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxDecompressedBytes = 100
+	if _, err := docxHeadings(data); !errorIsType(err, &utils.LimitExceededError{}) {
+		t.Errorf("docxHeadings over the decompression limit = %v, want limit exceeded", err)
+	}
+}
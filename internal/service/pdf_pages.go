@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// pageSlot is a page of the document being rearranged; source 0 is an inserted blank page
+type pageSlot struct {
+	source int
+	rotate int
+}
+
+// EditPDFPages handles the page manipulation request for an uploaded PDF
+func EditPDFPages(file io.Reader, opts model.PageOptions) response.APIResponse {
+	data, err := ApplyPDFPageOps(file, opts.Ops)
+	if err != nil {
+		return newConversionErrorResponse("PDF page manipulation failed", err)
+	}
+	return response.NewSuccessResponse("PDF pages updated successfully", data)
+}
+
+// ApplyPDFPageOps applies page operations in order and writes the result in one pass. Every operation refers
+// to the page numbers as left by the operations before it:
+//
+//	rotate:<ranges>:<degrees>  rotate pages clockwise by a multiple of 90 degrees
+//	delete:<ranges>            remove pages
+//	order:<ranges>             rearrange the pages; every page must be listed exactly once
+//	blank:<after>[:<count>]    insert blank pages after a page, 0 inserts before the first page
+func ApplyPDFPageOps(file io.Reader, ops []string) ([]byte, error) {
+	ctx, err := readPDF(file, pdfmodel.INSERTPAGESAFTER)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := make([]pageSlot, ctx.PageCount)
+	for i := range slots {
+		slots[i].source = i + 1
+	}
+	for _, op := range ops {
+		if slots, err = applyPageOp(slots, op); err != nil {
+			return nil, err
+		}
+		if err := checkPDFPages(len(slots)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Every original page occurs at most once, so rotations can be applied to the source pages
+	var sources []int
+	var blanksAfter []int
+	for _, slot := range slots {
+		if slot.source == 0 {
+			blanksAfter = append(blanksAfter, len(sources))
+			continue
+		}
+		sources = append(sources, slot.source)
+		if slot.rotate != 0 {
+			if err := pdfcpu.RotatePages(ctx, types.IntSet{slot.source: true}, slot.rotate); err != nil {
+				return nil, fmt.Errorf("failed to rotate page %d: %w", slot.source, err)
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return nil, &utils.InvalidInputError{Msg: "the operations leave no pages of the original document"}
+	}
+
+	dest, err := pdfcpu.ExtractPages(ctx, sources, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rearrange pages: %w", err)
+	}
+
+	// Insert the blank pages from the back so earlier page numbers stay valid
+	for i := len(blanksAfter) - 1; i >= 0; i-- {
+		// Blank pages take the size of the page they follow, or of the first page
+		after, before := blanksAfter[i], false
+		if after == 0 {
+			after, before = 1, true
+		}
+		if err := dest.InsertBlankPages(types.IntSet{after: true}, nil, before); err != nil {
+			return nil, fmt.Errorf("failed to insert blank page: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(dest, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// applyPageOp applies a single page operation to the current pages
+func applyPageOp(slots []pageSlot, op string) ([]pageSlot, error) {
+	name, args, _ := strings.Cut(op, ":")
+	switch name {
+	case "rotate":
+		spec, angle, ok := strings.Cut(args, ":")
+		if !ok {
+			return nil, pageOpError(op, errors.New("expected rotate:<pages>:<degrees>"))
+		}
+		degrees, err := strconv.Atoi(angle)
+		if err != nil || degrees%90 != 0 {
+			return nil, pageOpError(op, fmt.Errorf("rotation %q is not a multiple of 90 degrees", angle))
+		}
+		pages, err := parsePageSet(spec, len(slots))
+		if err != nil {
+			return nil, pageOpError(op, err)
+		}
+		for page := range pages {
+			slots[page-1].rotate = ((slots[page-1].rotate+degrees)%360 + 360) % 360
+		}
+		return slots, nil
+
+	case "delete":
+		pages, err := parsePageSet(args, len(slots))
+		if err != nil {
+			return nil, pageOpError(op, err)
+		}
+		kept := make([]pageSlot, 0, len(slots)-len(pages))
+		for i, slot := range slots {
+			if !pages[i+1] {
+				kept = append(kept, slot)
+			}
+		}
+		return kept, nil
+
+	case "order":
+		ranges, err := parsePageRanges(args, len(slots))
+		if err != nil {
+			return nil, pageOpError(op, err)
+		}
+		ordered := make([]pageSlot, 0, len(slots))
+		listed := make(map[int]bool, len(slots))
+		for _, pages := range ranges {
+			for _, page := range pages {
+				if listed[page] {
+					return nil, pageOpError(op, fmt.Errorf("page %d is listed more than once", page))
+				}
+				listed[page] = true
+				ordered = append(ordered, slots[page-1])
+			}
+		}
+		for page := 1; page <= len(slots); page++ {
+			if !listed[page] {
+				return nil, pageOpError(op, fmt.Errorf("page %d is missing, use delete to remove pages", page))
+			}
+		}
+		return ordered, nil
+
+	case "blank":
+		position, countArg, hasCount := strings.Cut(args, ":")
+		after, err := strconv.Atoi(position)
+		if err != nil || after < 0 || after > len(slots) {
+			return nil, pageOpError(op, fmt.Errorf("position %q is not between 0 and the last page %d", position, len(slots)))
+		}
+		count := 1
+		if hasCount {
+			if count, err = strconv.Atoi(countArg); err != nil || count < 1 {
+				return nil, pageOpError(op, fmt.Errorf("count %q is not a positive number", countArg))
+			}
+		}
+		// The count is checked before allocating, as the page limit is only checked after each operation
+		if count > config.AppLimits.MaxPDFPages-len(slots) {
+			return nil, &utils.LimitExceededError{Msg: fmt.Sprintf("operation %q: inserting %d pages exceeds the limit of %d pages", op, count, config.AppLimits.MaxPDFPages)}
+		}
+		inserted := make([]pageSlot, 0, len(slots)+count)
+		inserted = append(inserted, slots[:after]...)
+		inserted = append(inserted, make([]pageSlot, count)...)
+		return append(inserted, slots[after:]...), nil
+
+	default:
+		return nil, pageOpError(op, fmt.Errorf("unknown operation %q, expected rotate, delete, order or blank", name))
+	}
+}
+
+// parsePageSet parses page ranges into the set of pages they cover
+func parsePageSet(spec string, pageCount int) (map[int]bool, error) {
+	ranges, err := parsePageRanges(spec, pageCount)
+	if err != nil {
+		return nil, err
+	}
+	pages := make(map[int]bool)
+	for _, r := range ranges {
+		for _, page := range r {
+			pages[page] = true
+		}
+	}
+	return pages, nil
+}
+
+// pageOpError reports a failed page operation as invalid input naming the operation
+func pageOpError(op string, err error) error {
+	var inputErr *utils.InvalidInputError
+	if errors.As(err, &inputErr) {
+		return &utils.InvalidInputError{Msg: fmt.Sprintf("operation %q: %s", op, inputErr.Msg)}
+	}
+	return &utils.InvalidInputError{Msg: fmt.Sprintf("operation %q: %s", op, err.Error())}
+}
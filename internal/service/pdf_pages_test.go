@@ -0,0 +1,110 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/utils"
+)
+
+// formatSlots writes page slots as their source pages, with the rotation after an r and blank pages as 0
+func formatSlots(slots []pageSlot) string {
+	var pages []string
+	for _, slot := range slots {
+		page := fmt.Sprint(slot.source)
+		if slot.rotate != 0 {
+			page += fmt.Sprintf("r%d", slot.rotate)
+		}
+		pages = append(pages, page)
+	}
+	return strings.Join(pages, " ")
+}
+
+func TestApplyPageOp(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     []string
+		want    string
+		wantErr interface{} // Type of the expected error
+	}{
+		{name: "rotate", ops: []string{"rotate:1-2:90"}, want: "1r90 2r90 3 4"},
+		{name: "rotate twice", ops: []string{"rotate:1:90", "rotate:1:270"}, want: "1 2 3 4"},
+		{name: "rotate negative", ops: []string{"rotate:4:-90"}, want: "1 2 3 4r270"},
+		{name: "delete", ops: []string{"delete:2,4"}, want: "1 3"},
+		{name: "delete renumbers", ops: []string{"delete:1", "delete:1"}, want: "3 4"},
+		{name: "order", ops: []string{"order:4,1-3"}, want: "4 1 2 3"},
+		{name: "order keeps rotation", ops: []string{"rotate:1:180", "order:2-4,1"}, want: "2 3 4 1r180"},
+		{name: "blank after", ops: []string{"blank:2"}, want: "1 2 0 3 4"},
+		{name: "blank before", ops: []string{"blank:0:2"}, want: "0 0 1 2 3 4"},
+		{name: "blank at end", ops: []string{"blank:4"}, want: "1 2 3 4 0"},
+		{name: "rotate blank", ops: []string{"blank:0", "rotate:1:90"}, want: "0r90 1 2 3 4"},
+		{name: "unknown operation", ops: []string{"flip:1"}, wantErr: &utils.InvalidInputError{}},
+		{name: "rotate without angle", ops: []string{"rotate:1"}, wantErr: &utils.InvalidInputError{}},
+		{name: "rotate odd angle", ops: []string{"rotate:1:45"}, wantErr: &utils.InvalidInputError{}},
+		{name: "rotate beyond last page", ops: []string{"rotate:5:90"}, wantErr: &utils.InvalidInputError{}},
+		{name: "order missing page", ops: []string{"order:1-3"}, wantErr: &utils.InvalidInputError{}},
+		{name: "order duplicate page", ops: []string{"order:1-4,2"}, wantErr: &utils.InvalidInputError{}},
+		{name: "blank beyond last page", ops: []string{"blank:5"}, wantErr: &utils.InvalidInputError{}},
+		{name: "blank zero count", ops: []string{"blank:1:0"}, wantErr: &utils.InvalidInputError{}},
+		{name: "blank beyond page limit", ops: []string{"blank:1:7"}, wantErr: &utils.LimitExceededError{}},
+		{name: "blank huge count", ops: []string{"blank:1:9223372036854775807"}, wantErr: &utils.LimitExceededError{}},
+	}
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxPDFPages = 10
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots := []pageSlot{{source: 1}, {source: 2}, {source: 3}, {source: 4}}
+			var err error
+			for _, op := range tt.ops {
+				if slots, err = applyPageOp(slots, op); err != nil {
+					break
+				}
+			}
+			if tt.wantErr != nil {
+				if !errorIsType(err, tt.wantErr) {
+					t.Errorf("applyPageOp(%q) error = %v, want %T", tt.ops, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyPageOp(%q): %v", tt.ops, err)
+			}
+			if got := formatSlots(slots); got != tt.want {
+				t.Errorf("applyPageOp(%q) = %q, want %q", tt.ops, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPDFPageOps(t *testing.T) {
+	data, err := ApplyPDFPageOps(bytes.NewReader(testPDF(t, 3)), []string{"delete:2", "blank:0", "rotate:2:90"})
+	if err != nil {
+		t.Fatalf("ApplyPDFPageOps: %v", err)
+	}
+	ctx, err := readPDF(bytes.NewReader(data), pdfmodel.VALIDATE)
+	if err != nil {
+		t.Fatalf("reading the result: %v", err)
+	}
+	if ctx.PageCount != 3 {
+		t.Errorf("page count = %d, want 3", ctx.PageCount)
+	}
+	// The blank page comes first, so the rotated page is the original first page
+	for page, want := range map[int]int{1: 0, 2: 90, 3: 0} {
+		_, _, attrs, err := ctx.PageDict(page, false)
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		if attrs.Rotate != want {
+			t.Errorf("page %d rotation = %d, want %d", page, attrs.Rotate, want)
+		}
+	}
+
+	if _, err := ApplyPDFPageOps(bytes.NewReader(testPDF(t, 2)), []string{"delete:1-2"}); !errorIsType(err, &utils.InvalidInputError{}) {
+		t.Errorf("deleting every page: error = %v, want invalid input", err)
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// SplitPDF handles the split request for an uploaded PDF
+func SplitPDF(file io.Reader, opts model.SplitOptions) response.APIResponse {
+	result, err := SplitPDFPages(file, opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF split failed", err)
+	}
+	return response.NewSuccessResponse("PDF split successfully", result)
+}
+
+// SplitPDFPages splits a PDF by page ranges, into chunks of opts.Every pages, or into one file per page.
+// A single part is returned as a PDF, several parts as a ZIP of PDFs named after their pages.
+// With opts.Single the selected ranges are extracted into one PDF instead.
+func SplitPDFPages(file io.Reader, opts model.SplitOptions) (model.ConvertedFile, error) {
+	ctx, err := readPDF(file, pdfmodel.SPLIT)
+	if err != nil {
+		return model.ConvertedFile{}, err
+	}
+
+	var parts [][]int
+	switch {
+	case opts.Ranges != "" && opts.Every > 0:
+		return model.ConvertedFile{}, &utils.InvalidInputError{Msg: "ranges and every cannot be combined"}
+	case opts.Ranges != "":
+		if parts, err = parsePageRanges(opts.Ranges, ctx.PageCount); err != nil {
+			return model.ConvertedFile{}, err
+		}
+	default:
+		every := max(opts.Every, 1)
+		for first := 1; first <= ctx.PageCount; first += every {
+			part := make([]int, 0, every)
+			for p := first; p < first+every && p <= ctx.PageCount; p++ {
+				part = append(part, p)
+			}
+			parts = append(parts, part)
+		}
+	}
+
+	if opts.Single {
+		var pages []int
+		for _, part := range parts {
+			pages = append(pages, part...)
+		}
+		parts = [][]int{pages}
+	}
+
+	entries := make([]utils.ZipEntry, 0, len(parts))
+	for _, pages := range parts {
+		data, err := extractPDFPages(ctx, pages)
+		if err != nil {
+			return model.ConvertedFile{}, err
+		}
+		entries = append(entries, utils.ZipEntry{Name: pagesFilename(pages), Data: data})
+	}
+
+	if len(entries) == 1 {
+		return model.ConvertedFile{Content: entries[0].Data, Filename: entries[0].Name}, nil
+	}
+	data, err := utils.CreateZip(entries)
+	if err != nil {
+		return model.ConvertedFile{}, err
+	}
+	return model.ConvertedFile{Content: data, Filename: "split.zip"}, nil
+}
+
+// extractPDFPages writes the given pages of a PDF, in the given order, as a new PDF
+func extractPDFPages(ctx *pdfmodel.Context, pages []int) ([]byte, error) {
+	part, err := pdfcpu.ExtractPages(ctx, pages, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract pages: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(part, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pagesFilename names a split part after its pages, e.g. pages_1-3.pdf or page_5.pdf
+func pagesFilename(pages []int) string {
+	first, last := pages[0], pages[len(pages)-1]
+	contiguous := last-first == len(pages)-1
+	switch {
+	case len(pages) == 1:
+		return fmt.Sprintf("page_%d.pdf", first)
+	case contiguous:
+		return fmt.Sprintf("pages_%d-%d.pdf", first, last)
+	default:
+		return fmt.Sprintf("pages_%d-%d_selection.pdf", first, last)
+	}
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// maxPDFPageSize is the largest page width or height PDF allows, 200 inches in points
+const maxPDFPageSize = 14400
+
+var (
+	// Text drawn with runs of spaces between its parts, as monospaced reports do, spans several cells
+	cellPaddingPattern = regexp.MustCompile(`\s{2,}`)
+	// Numbers with leading zeros, such as account numbers, and too many digits for a float stay text
+	amountPattern = regexp.MustCompile(`^-?(0|[1-9]\d{0,2}(,\d{3}){1,4}|[1-9]\d{0,14})(\.\d+)?$`)
+)
+
+// pdfTable holds the rows of the table found on a PDF page, every row having a cell per column
+type pdfTable struct {
+	page int
+	rows [][]string
+}
+
+// textCell is a piece of a table row: runs close enough to each other to belong to the same cell
+type textCell struct {
+	text   string
+	x0, x1 float64
+}
+
+// ConvertPDFToTable extracts the tables of the selected pages of a text-based PDF as CSV, or as XLSX with a sheet
+// per page. Runs sharing a baseline make up a row and columns are found where the cells of the rows leave a vertical
+// gap, unless explicit column boundaries are given.
+func ConvertPDFToTable(file io.Reader, targetFormat string, opts model.ConvertOptions) ([]byte, error) {
+	tables, err := extractPDFTables(file, opts)
+	if err != nil {
+		return nil, err
+	}
+	if targetFormat == "xlsx" {
+		return writeTablesXLSX(tables)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for _, table := range tables {
+		if err := w.WriteAll(table.rows); err != nil {
+			return nil, fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// extractPDFTables returns the rows of text of the selected pages split into columns
+func extractPDFTables(file io.Reader, opts model.ConvertOptions) ([]pdfTable, error) {
+	text, err := ExtractPDFText(file, model.ConvertOptions{Pages: opts.Pages, Password: opts.Password})
+	if err != nil {
+		return nil, err
+	}
+	boundaries, err := parseColumnBoundaries(opts.Columns, text.PageCount)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []pdfTable
+	for _, page := range text.Pages {
+		rows := tableRows(visibleRuns(page))
+		if len(rows) == 0 {
+			continue
+		}
+		columns := boundaryColumns(boundaries[page.Page])
+		if columns == nil {
+			columns = detectTableColumns(rows)
+		}
+		tables = append(tables, pdfTable{page: page.Page, rows: fillTableColumns(rows, columns)})
+	}
+	if len(tables) == 0 {
+		return nil, &utils.InvalidInputError{Msg: "the PDF has no text to extract tables from, scanned pages need OCR first"}
+	}
+	return tables, nil
+}
+
+// visibleRuns returns the runs of a page that start on it, text outside the page not being shown
+func visibleRuns(page PDFTextPage) []PDFTextRun {
+	if page.Width <= 0 || page.Height <= 0 {
+		return page.Runs
+	}
+	var runs []PDFTextRun
+	for _, run := range page.Runs {
+		if run.X >= 0 && run.X < page.Width && run.Y >= 0 && run.Y < page.Height {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// tableRows groups runs sharing a baseline into rows from the top of the page down, each split into cells at gaps
+// wider than a space
+func tableRows(runs []PDFTextRun) [][]textCell {
+	fontSize := medianFontSize(runs)
+	if fontSize <= 0 {
+		return nil
+	}
+	sorted := append([]PDFTextRun(nil), runs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Y > sorted[j].Y })
+
+	var lines [][]PDFTextRun
+	baseline := math.Inf(1)
+	for _, run := range sorted {
+		if baseline-run.Y > fontSize/2 {
+			lines = append(lines, nil)
+			baseline = run.Y
+		}
+		lines[len(lines)-1] = append(lines[len(lines)-1], run)
+	}
+
+	rows := make([][]textCell, 0, len(lines))
+	for _, line := range lines {
+		var parts []textCell
+		for _, run := range line {
+			parts = append(parts, splitPaddedRun(run)...)
+		}
+		sort.SliceStable(parts, func(i, j int) bool { return parts[i].x0 < parts[j].x0 })
+
+		// Parts closer than a wide space, such as words drawn one by one, are joined into one cell
+		row := []textCell{parts[0]}
+		for _, part := range parts[1:] {
+			last := &row[len(row)-1]
+			if part.x0-last.x1 < fontSize {
+				last.text = strings.TrimSpace(last.text) + " " + strings.TrimSpace(part.text)
+				last.x1 = math.Max(last.x1, part.x1)
+				continue
+			}
+			row = append(row, part)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// splitPaddedRun splits a run at runs of spaces, estimating the position of the parts from their share of the
+// characters as the glyph widths are no longer known
+func splitPaddedRun(run PDFTextRun) []textCell {
+	n := float64(utf8.RuneCountInString(run.Text))
+	var cells []textCell
+	start := 0
+	for _, gap := range append(cellPaddingPattern.FindAllStringIndex(run.Text, -1), []int{len(run.Text), len(run.Text)}) {
+		if text := strings.TrimSpace(run.Text[start:gap[0]]); text != "" {
+			x0 := run.X + run.Width*float64(utf8.RuneCountInString(run.Text[:start]))/n
+			x1 := run.X + run.Width*float64(utf8.RuneCountInString(run.Text[:gap[0]]))/n
+			cells = append(cells, textCell{text: text, x0: x0, x1: x1})
+		}
+		start = gap[1]
+	}
+	return cells
+}
+
+// detectTableColumns finds the columns of a page as the horizontal ranges covered by the cells of rows with more than
+// one cell. A few cells crossing a gap, such as a heading spanning the table, do not close it.
+func detectTableColumns(rows [][]textCell) [][2]float64 {
+	var cells []textCell
+	tableRows := 0
+	for _, row := range rows {
+		if len(row) > 1 {
+			cells = append(cells, row...)
+			tableRows++
+		}
+	}
+	if tableRows == 0 {
+		// Without any row of several cells the page is plain text in a single column
+		return [][2]float64{{math.Inf(-1), math.Inf(1)}}
+	}
+
+	minX, maxX := cells[0].x0, cells[0].x1
+	for _, cell := range cells {
+		minX, maxX = math.Min(minX, cell.x0), math.Max(maxX, cell.x1)
+	}
+	// Wider than the largest PDF page only with runs of garbled positions
+	if maxX-minX > maxPDFPageSize {
+		return [][2]float64{{math.Inf(-1), math.Inf(1)}}
+	}
+	coverage := make([]int, int(maxX-minX)+2)
+	for _, cell := range cells {
+		for x := int(cell.x0 - minX); x <= int(cell.x1-minX); x++ {
+			coverage[x]++
+		}
+	}
+
+	threshold := tableRows / 10
+	var columns [][2]float64
+	open := false
+	for x, count := range coverage {
+		switch {
+		case count > threshold && !open:
+			columns = append(columns, [2]float64{minX + float64(x), 0})
+			open = true
+		case count <= threshold && open:
+			columns[len(columns)-1][1] = minX + float64(x)
+			open = false
+		}
+	}
+	if open {
+		columns[len(columns)-1][1] = maxX
+	}
+	return columns
+}
+
+// boundaryColumns turns column boundaries into the ranges between them, the first and last column being open ended
+func boundaryColumns(boundaries []float64) [][2]float64 {
+	if len(boundaries) == 0 {
+		return nil
+	}
+	columns := make([][2]float64, 0, len(boundaries)+1)
+	left := math.Inf(-1)
+	for _, x := range boundaries {
+		columns = append(columns, [2]float64{left, x})
+		left = x
+	}
+	return append(columns, [2]float64{left, math.Inf(1)})
+}
+
+// fillTableColumns places every cell in the column it overlaps most, or the nearest column when it overlaps none;
+// cells of a row landing in the same column are joined
+func fillTableColumns(rows [][]textCell, columns [][2]float64) [][]string {
+	table := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		values := make([]string, len(columns))
+		for _, cell := range row {
+			col := tableColumn(cell, columns)
+			values[col] = strings.TrimSpace(values[col] + " " + cell.text)
+		}
+		table = append(table, values)
+	}
+	return table
+}
+
+// tableColumn returns the index of the column a cell belongs to
+func tableColumn(cell textCell, columns [][2]float64) int {
+	best, bestOverlap, bestDistance := 0, 0.0, math.Inf(1)
+	center := (cell.x0 + cell.x1) / 2
+	for i, column := range columns {
+		overlap := math.Min(cell.x1, column[1]) - math.Max(cell.x0, column[0])
+		distance := math.Max(column[0]-center, center-column[1])
+		switch {
+		case overlap > bestOverlap:
+			best, bestOverlap = i, overlap
+		case bestOverlap == 0 && overlap <= 0 && distance < bestDistance:
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
+
+// parseColumnBoundaries parses explicit column boundaries, the x positions in points between columns. Boundaries
+// apply to every page, e.g. "150,300,420", or to a page selection, e.g. "1:150,300;2-:120,260,400", later groups
+// taking precedence.
+func parseColumnBoundaries(spec string, pageCount int) (map[int][]float64, error) {
+	boundaries := map[int][]float64{}
+	if strings.TrimSpace(spec) == "" {
+		return boundaries, nil
+	}
+	for _, group := range strings.Split(spec, ";") {
+		selection, list := "", group
+		if i := strings.Index(group, ":"); i >= 0 {
+			selection, list = strings.TrimSpace(group[:i]), group[i+1:]
+		}
+
+		var xs []float64
+		for _, s := range strings.Split(list, ",") {
+			x, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil || x < 0 {
+				return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("invalid column boundary %q in %q, expected x positions in points", s, group)}
+			}
+			if len(xs) > 0 && x <= xs[len(xs)-1] {
+				return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("column boundaries %q must increase from left to right", group)}
+			}
+			xs = append(xs, x)
+		}
+
+		pages, err := selectPDFPages(pageCount, selection)
+		if err != nil {
+			return nil, err
+		}
+		for _, page := range pages {
+			boundaries[page] = xs
+		}
+	}
+	return boundaries, nil
+}
+
+// writeTablesXLSX writes the tables to a workbook with a sheet per page, storing amounts such as 1,234.56 as numbers
+func writeTablesXLSX(tables []pdfTable) ([]byte, error) {
+	xl := excelize.NewFile()
+	defer xl.Close()
+
+	for i, table := range tables {
+		sheet := fmt.Sprintf("Page %d", table.page)
+		if i == 0 {
+			if err := xl.SetSheetName("Sheet1", sheet); err != nil {
+				return nil, fmt.Errorf("failed to write XLSX: %w", err)
+			}
+		} else if _, err := xl.NewSheet(sheet); err != nil {
+			return nil, fmt.Errorf("failed to write XLSX: %w", err)
+		}
+
+		for r, row := range table.rows {
+			values := make([]interface{}, len(row))
+			for c, value := range row {
+				values[c] = value
+				if amountPattern.MatchString(value) {
+					if n, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64); err == nil {
+						values[c] = n
+					}
+				}
+			}
+			cell, _ := excelize.CoordinatesToCellName(1, r+1)
+			if err := xl.SetSheetRow(sheet, cell, &values); err != nil {
+				return nil, fmt.Errorf("failed to write XLSX: %w", err)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := xl.Write(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write XLSX: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"synth.com/file_converter/internal/utils"
+)
+
+func TestParseColumnBoundaries(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string // Boundaries of pages 1 to 3
+		wantErr bool
+	}{
+		{spec: "", want: "[] [] []"},
+		{spec: "150,300", want: "[150 300] [150 300] [150 300]"},
+		{spec: " 150 , 300.5 ", want: "[150 300.5] [150 300.5] [150 300.5]"},
+		{spec: "1:100;2-:120,260", want: "[100] [120 260] [120 260]"},
+		{spec: "150,300;3:90", want: "[150 300] [150 300] [90]"},
+		{spec: "odd:50", want: "[50] [] [50]"},
+		{spec: "300,150", wantErr: true},
+		{spec: "150,150", wantErr: true},
+		{spec: "-5", wantErr: true},
+		{spec: "150,,300", wantErr: true},
+		{spec: "wide", wantErr: true},
+		{spec: "4:100", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			boundaries, err := parseColumnBoundaries(tt.spec, 3)
+			if tt.wantErr {
+				if !errorIsType(err, &utils.InvalidInputError{}) {
+					t.Errorf("parseColumnBoundaries(%q) error = %v, want invalid input", tt.spec, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseColumnBoundaries(%q): %v", tt.spec, err)
+			}
+			got := fmt.Sprint(boundaries[1], boundaries[2], boundaries[3])
+			if got != tt.want {
+				t.Errorf("parseColumnBoundaries(%q) = %s, want %s", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectTableColumns(t *testing.T) {
+	row := func(xs ...float64) []textCell {
+		var cells []textCell
+		for i := 0; i+1 < len(xs); i += 2 {
+			cells = append(cells, textCell{text: "cell", x0: xs[i], x1: xs[i+1]})
+		}
+		return cells
+	}
+	tests := []struct {
+		name string
+		rows [][]textCell
+		want string
+	}{
+		{
+			name: "plain text",
+			rows: [][]textCell{row(72, 500), row(72, 480)},
+			want: "[[-Inf +Inf]]",
+		},
+		{
+			name: "two columns",
+			rows: [][]textCell{row(72, 120, 300, 340), row(72, 140, 310, 350), row(80, 110, 300, 330)},
+			want: "[[72 141] [300 351]]",
+		},
+		{
+			name: "title above the table",
+			rows: [][]textCell{row(72, 350), row(72, 120, 300, 340), row(72, 140, 310, 350)},
+			want: "[[72 141] [300 351]]",
+		},
+		{
+			name: "few cells spanning a gap",
+			rows: append([][]textCell{row(72, 250, 300, 340)}, repeatRows(row(72, 120, 300, 340), 19)...),
+			want: "[[72 121] [300 341]]",
+		},
+		{
+			name: "garbled positions",
+			rows: [][]textCell{row(-1e6, 10, 20, 1e6)},
+			want: "[[-Inf +Inf]]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fmt.Sprint(detectTableColumns(tt.rows)); got != tt.want {
+				t.Errorf("detectTableColumns = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// repeatRows returns n copies of a row
+func repeatRows(row []textCell, n int) [][]textCell {
+	rows := make([][]textCell, n)
+	for i := range rows {
+		rows[i] = row
+	}
+	return rows
+}
+
+func TestFillTableColumns(t *testing.T) {
+	columns := boundaryColumns([]float64{200, 400})
+	if got := fmt.Sprint(columns); got != fmt.Sprint([][2]float64{{math.Inf(-1), 200}, {200, 400}, {400, math.Inf(1)}}) {
+		t.Fatalf("boundaryColumns = %s", got)
+	}
+
+	rows := [][]textCell{
+		{{"Name", 72, 110}, {"Qty", 210, 230}, {"Price", 410, 440}},
+		{{"Long", 72, 100}, {"name", 104, 130}, {"1,234.50", 380, 440}},
+		{{"Total", 72, 100}, {"straddling", 190, 260}},
+	}
+	want := "[[Name Qty Price] [Long name  1,234.50] [Total straddling ]]"
+	if got := fmt.Sprint(fillTableColumns(rows, columns)); got != want {
+		t.Errorf("fillTableColumns = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"synth.com/file_converter/internal/utils"
+)
+
+func TestPDFReadError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"wrong password", fmt.Errorf("read: %w", pdfcpu.ErrWrongPassword), http.StatusUnprocessableEntity},
+		{"unknown encryption", pdfcpu.ErrUnknownEncryption, http.StatusUnprocessableEntity},
+		{"already encrypted", errors.New("pdfcpu: this file is already encrypted"), http.StatusUnprocessableEntity},
+		{"not encrypted", errors.New("pdfcpu: this file is not encrypted"), http.StatusUnprocessableEntity},
+		{"permissions", errors.New("pdfcpu: operation restriced via pdfcpu's permission bits setting"), http.StatusUnprocessableEntity},
+		{"parse failure", errors.New("pdfcpu: corrupt xref table"), http.StatusUnprocessableEntity},
+		{"I/O failure", &fs.PathError{Op: "read", Path: "/tmp/upload", Err: fs.ErrClosed}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := utils.StatusCodeForError(pdfReadError(tt.err)); got != tt.wantStatus {
+				t.Errorf("pdfReadError(%v) has status %d, want %d", tt.err, got, tt.wantStatus)
+			}
+		})
+	}
+
+	var corruptErr *utils.CorruptFileError
+	if err := pdfReadError(errors.New("pdfcpu: corrupt xref table")); !errors.As(err, &corruptErr) || corruptErr.Msg != "corrupt xref table" {
+		t.Errorf("pdfReadError() = %v, want a corrupt PDF without the pdfcpu prefix", err)
+	}
+}
+
+func TestParsePageRanges(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string // Ranges as pages joined by space, separated by |
+		wantErr string
+	}{
+		{spec: "1", want: "1"},
+		{spec: "1-3,5", want: "1 2 3|5"},
+		{spec: " 2 - 4 , 9 ", want: "2 3 4|9"},
+		{spec: "8-", want: "8 9 10"},
+		{spec: "3,1", want: "3|1"},
+		{spec: "1-3,2-4", want: "1 2 3|2 3 4"},
+		{spec: "1,,2", wantErr: `empty range in "1,,2"`},
+		{spec: "", wantErr: `empty range in ""`},
+		{spec: "a-3", wantErr: `range "a-3": "a" is not a page number`},
+		{spec: "-3", wantErr: `range "-3": "" is not a page number`},
+		{spec: "0-2", wantErr: `range "0-2": pages start at 1`},
+		{spec: "4-11", wantErr: `range "4-11": page 11 is beyond the last page 10`},
+		{spec: "5-2", wantErr: `range "5-2" ends before it starts`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			ranges, err := parsePageRanges(tt.spec, 10)
+			if tt.wantErr != "" {
+				var invalidErr *utils.InvalidInputError
+				if !errors.As(err, &invalidErr) || invalidErr.Msg != tt.wantErr {
+					t.Fatalf("parsePageRanges(%q) error = %v, want invalid input %q", tt.spec, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePageRanges(%q): %v", tt.spec, err)
+			}
+			var got []string
+			for _, pages := range ranges {
+				got = append(got, strings.Trim(fmt.Sprint(pages), "[]"))
+			}
+			if strings.Join(got, "|") != tt.want {
+				t.Errorf("parsePageRanges(%q) = %q, want %q", tt.spec, strings.Join(got, "|"), tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectPDFPages(t *testing.T) {
+	tests := []struct {
+		selection string
+		want      string
+		wantErr   bool
+	}{
+		{"", "[1 2 3 4 5]", false},
+		{"1-3,5", "[1 2 3 5]", false},
+		{"even", "[2 4]", false},
+		{"4-", "[4 5]", false},
+		{"5,1", "[1 5]", false},
+		{"7-", "", true},
+		{"x", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selection, func(t *testing.T) {
+			pages, err := selectPDFPages(5, tt.selection)
+			if tt.wantErr {
+				if !errorIsType(err, &utils.InvalidInputError{}) {
+					t.Errorf("selectPDFPages(%q) error = %v, want invalid input", tt.selection, err)
+				}
+				return
+			}
+			if err != nil || fmt.Sprint(pages) != tt.want {
+				t.Errorf("selectPDFPages(%q) = %v, %v, want %s", tt.selection, pages, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,649 @@
+package service
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// contentOp is a single operator of a PDF content stream together with its operands
+type contentOp struct {
+	Operator string
+	Operands []interface{}
+}
+
+// pdfName is a name operand (e.g. /F1) of a content stream operator
+type pdfName string
+
+// pdfString is a literal or hex string operand of a content stream operator
+type pdfString string
+
+// parseContentStream splits a PDF content stream into its operators.
+// Operands are decoded to float64, pdfName, pdfString or []interface{} (arrays);
+// dictionaries and inline image data are skipped.
+func parseContentStream(data []byte) []contentOp {
+	var ops []contentOp
+	var operands []interface{}
+	var arrays [][]interface{}
+
+	push := func(v interface{}) {
+		if len(arrays) > 0 {
+			arrays[len(arrays)-1] = append(arrays[len(arrays)-1], v)
+			return
+		}
+		operands = append(operands, v)
+	}
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case isPDFWhitespace(c):
+			i++
+
+		case c == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+
+		case c == '(':
+			s, next := readLiteralString(data, i)
+			push(s)
+			i = next
+
+		case c == '<' && i+1 < len(data) && data[i+1] == '<':
+			i = skipDictionary(data, i)
+			push(nil)
+
+		case c == '<':
+			s, next := readHexString(data, i)
+			push(s)
+			i = next
+
+		case c == '[':
+			arrays = append(arrays, nil)
+			i++
+
+		case c == ']':
+			if len(arrays) > 0 {
+				arr := arrays[len(arrays)-1]
+				arrays = arrays[:len(arrays)-1]
+				push(arr)
+			}
+			i++
+
+		case c == '/':
+			start := i + 1
+			i = start
+			for i < len(data) && !isPDFWhitespace(data[i]) && !isPDFDelimiter(data[i]) {
+				i++
+			}
+			push(pdfName(data[start:i]))
+
+		default:
+			start := i
+			for i < len(data) && !isPDFWhitespace(data[i]) && !isPDFDelimiter(data[i]) {
+				i++
+			}
+			if i == start {
+				// Stray delimiter such as '>' or ')'
+				i++
+				continue
+			}
+			token := string(data[start:i])
+			if f, err := strconv.ParseFloat(token, 64); err == nil {
+				push(f)
+				continue
+			}
+			ops = append(ops, contentOp{Operator: token, Operands: operands})
+			operands = nil
+			arrays = nil
+			if token == "ID" {
+				i = skipInlineImage(data, i)
+			}
+		}
+	}
+	return ops
+}
+
+// extractContentText returns the text painted by the text operators of a content stream. Fonts are looked up by
+// their resource name to decode their character codes.
+func extractContentText(data []byte, fonts map[string]*textFont) string {
+	var sb strings.Builder
+	var font *textFont
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+	}
+
+	for _, op := range parseContentStream(data) {
+		switch op.Operator {
+		case "Tf":
+			if len(op.Operands) == 2 {
+				name, _ := op.Operands[0].(pdfName)
+				font = fonts[string(name)]
+			}
+		case "Tj":
+			writeTextOperand(&sb, op.Operands, font)
+		case "'", "\"":
+			newline()
+			writeTextOperand(&sb, op.Operands, font)
+		case "TJ":
+			if len(op.Operands) == 0 {
+				continue
+			}
+			arr, _ := op.Operands[len(op.Operands)-1].([]interface{})
+			for _, elem := range arr {
+				switch v := elem.(type) {
+				case pdfString:
+					sb.WriteString(font.decode(v))
+				case float64:
+					// Large negative adjustments are used as word gaps
+					if v < -250 {
+						sb.WriteString(" ")
+					}
+				}
+			}
+		case "T*", "Tm":
+			newline()
+		case "Td", "TD":
+			if len(op.Operands) == 2 {
+				if ty, ok := op.Operands[1].(float64); ok && ty != 0 {
+					newline()
+					continue
+				}
+			}
+			if sb.Len() > 0 && !strings.HasSuffix(sb.String(), " ") && !strings.HasSuffix(sb.String(), "\n") {
+				sb.WriteString(" ")
+			}
+		case "ET":
+			newline()
+		}
+	}
+	return sb.String()
+}
+
+// textFont holds what extracting text needs to know about a font: how its character codes map to text and how
+// wide they are
+type textFont struct {
+	Name         string          // Base font name
+	TwoByte      bool            // Composite fonts with two byte character codes
+	ToUnicode    map[int]string  // Text of the character codes, from the ToUnicode CMap
+	Widths       map[int]float64 // Glyph widths in thousandths of the font size
+	DefaultWidth float64         // Width of codes missing from Widths
+}
+
+// codes splits a string into the character codes of the font
+func (f *textFont) codes(s pdfString) []int {
+	b := []byte(s)
+	if f == nil || !f.TwoByte {
+		codes := make([]int, len(b))
+		for i, c := range b {
+			codes[i] = int(c)
+		}
+		return codes
+	}
+	codes := make([]int, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		codes = append(codes, int(b[i])<<8|int(b[i+1]))
+	}
+	return codes
+}
+
+// decode converts a string shown in the font to UTF-8. Codes without a ToUnicode mapping are taken as Unicode,
+// which covers the standard Latin encodings of simple fonts.
+func (f *textFont) decode(s pdfString) string {
+	if f == nil || (f.ToUnicode == nil && !f.TwoByte) {
+		return decodePDFString(s)
+	}
+	var sb strings.Builder
+	for _, code := range f.codes(s) {
+		if text, ok := f.ToUnicode[code]; ok {
+			sb.WriteString(text)
+		} else {
+			sb.WriteRune(rune(code))
+		}
+	}
+	return sb.String()
+}
+
+// width returns the width of a character code in thousandths of the font size
+func (f *textFont) width(code int) float64 {
+	if w, ok := f.Widths[code]; ok {
+		return w
+	}
+	return f.DefaultWidth
+}
+
+// textMatrix is a PDF transformation matrix [a b c d e f]
+type textMatrix [6]float64
+
+var identityMatrix = textMatrix{1, 0, 0, 1, 0, 0}
+
+// multiply returns m × n, applying m first
+func (m textMatrix) multiply(n textMatrix) textMatrix {
+	return textMatrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// apply transforms the point (x, y)
+func (m textMatrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// translation returns the matrix moving points by (tx, ty)
+func translation(tx, ty float64) textMatrix {
+	return textMatrix{1, 0, 0, 1, tx, ty}
+}
+
+// textState is the part of the graphics state that positions text
+type textState struct {
+	ctm         textMatrix
+	font        *textFont
+	fontSize    float64
+	charSpacing float64
+	wordSpacing float64
+	scale       float64 // Horizontal scaling, 1 is 100%
+	leading     float64
+	rise        float64
+}
+
+// extractContentRuns returns the text painted by the text operators of a content stream as runs positioned in
+// default user space, one per text showing operator. Fonts are looked up by their resource name; text in unknown
+// fonts is positioned with an average glyph width.
+func extractContentRuns(data []byte, fonts map[string]*textFont) []PDFTextRun {
+	fallback := &textFont{DefaultWidth: 500}
+	state := textState{ctm: identityMatrix, font: fallback, scale: 1}
+	var stack []textState
+	tm, tlm := identityMatrix, identityMatrix
+	var runs []PDFTextRun
+
+	newLine := func(tx, ty float64) {
+		tlm = translation(tx, ty).multiply(tlm)
+		tm = tlm
+	}
+	// advance moves the text position past a string and returns its text
+	advance := func(s pdfString) string {
+		for _, code := range state.font.codes(s) {
+			tx := state.font.width(code)/1000*state.fontSize + state.charSpacing
+			// Word spacing applies to the single byte code 32 only
+			if !state.font.TwoByte && code == ' ' {
+				tx += state.wordSpacing
+			}
+			tm = translation(tx*state.scale, 0).multiply(tm)
+		}
+		return state.font.decode(s)
+	}
+	// show records the run painted by a text showing operator, paint advancing past its strings
+	show := func(paint func() string) {
+		trm := tm.multiply(state.ctm)
+		x, y := trm.apply(0, state.rise)
+		size := state.fontSize * math.Hypot(trm[2], trm[3])
+		text := paint()
+		endX, endY := tm.multiply(state.ctm).apply(0, state.rise)
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		runs = append(runs, PDFTextRun{
+			Text:     text,
+			X:        x,
+			Y:        y,
+			Width:    math.Hypot(endX-x, endY-y),
+			FontSize: size,
+			Font:     state.font.Name,
+		})
+	}
+	// lastString paints the string operand of Tj, ' and "
+	lastString := func(operands []interface{}) func() string {
+		return func() string {
+			if len(operands) == 0 {
+				return ""
+			}
+			s, _ := operands[len(operands)-1].(pdfString)
+			return advance(s)
+		}
+	}
+
+	for _, op := range parseContentStream(data) {
+		nums := numberOperands(op.Operands)
+		switch op.Operator {
+		case "q":
+			stack = append(stack, state)
+		case "Q":
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case "cm":
+			if len(nums) == 6 {
+				state.ctm = textMatrix(nums).multiply(state.ctm)
+			}
+		case "BT":
+			tm, tlm = identityMatrix, identityMatrix
+		case "Tf":
+			if len(op.Operands) == 2 {
+				name, _ := op.Operands[0].(pdfName)
+				state.font = fallback
+				if f, ok := fonts[string(name)]; ok {
+					state.font = f
+				}
+				state.fontSize, _ = op.Operands[1].(float64)
+			}
+		case "Tc":
+			if len(nums) == 1 {
+				state.charSpacing = nums[0]
+			}
+		case "Tw":
+			if len(nums) == 1 {
+				state.wordSpacing = nums[0]
+			}
+		case "Tz":
+			if len(nums) == 1 {
+				state.scale = nums[0] / 100
+			}
+		case "TL":
+			if len(nums) == 1 {
+				state.leading = nums[0]
+			}
+		case "Ts":
+			if len(nums) == 1 {
+				state.rise = nums[0]
+			}
+		case "Td":
+			if len(nums) == 2 {
+				newLine(nums[0], nums[1])
+			}
+		case "TD":
+			if len(nums) == 2 {
+				state.leading = -nums[1]
+				newLine(nums[0], nums[1])
+			}
+		case "Tm":
+			if len(nums) == 6 {
+				tm = textMatrix(nums)
+				tlm = tm
+			}
+		case "T*":
+			newLine(0, -state.leading)
+		case "Tj":
+			show(lastString(op.Operands))
+		case "'":
+			newLine(0, -state.leading)
+			show(lastString(op.Operands))
+		case "\"":
+			if len(op.Operands) == 3 {
+				state.wordSpacing, _ = op.Operands[0].(float64)
+				state.charSpacing, _ = op.Operands[1].(float64)
+			}
+			newLine(0, -state.leading)
+			show(lastString(op.Operands))
+		case "TJ":
+			if len(op.Operands) == 0 {
+				continue
+			}
+			arr, _ := op.Operands[len(op.Operands)-1].([]interface{})
+			show(func() string {
+				var sb strings.Builder
+				for _, elem := range arr {
+					switch v := elem.(type) {
+					case pdfString:
+						sb.WriteString(advance(v))
+					case float64:
+						tm = translation(-v/1000*state.fontSize*state.scale, 0).multiply(tm)
+						// Large negative adjustments are used as word gaps
+						if v < -250 {
+							sb.WriteString(" ")
+						}
+					}
+				}
+				return sb.String()
+			})
+		}
+	}
+	return runs
+}
+
+// numberOperands returns the operands of an operator as numbers, or nil if any of them is not a number
+func numberOperands(operands []interface{}) []float64 {
+	nums := make([]float64, 0, len(operands))
+	for _, operand := range operands {
+		n, ok := operand.(float64)
+		if !ok {
+			return nil
+		}
+		nums = append(nums, n)
+	}
+	return nums
+}
+
+// writeTextOperand appends the last string operand of a text showing operator
+func writeTextOperand(sb *strings.Builder, operands []interface{}, font *textFont) {
+	if len(operands) == 0 {
+		return
+	}
+	if s, ok := operands[len(operands)-1].(pdfString); ok {
+		sb.WriteString(font.decode(s))
+	}
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap from character codes to text
+func parseToUnicode(data []byte) map[int]string {
+	mapping := make(map[int]string)
+	code := func(operand interface{}) (int, bool) {
+		s, ok := operand.(pdfString)
+		if !ok || len(s) == 0 || len(s) > 4 {
+			return 0, false
+		}
+		v := 0
+		for _, c := range []byte(s) {
+			v = v<<8 | int(c)
+		}
+		return v, true
+	}
+	// Destinations are UTF-16BE without byte order mark
+	text := func(operand interface{}) (string, bool) {
+		s, ok := operand.(pdfString)
+		if !ok {
+			return "", false
+		}
+		return decodePDFString(pdfString("\xfe\xff" + string(s))), true
+	}
+
+	for _, op := range parseContentStream(data) {
+		switch op.Operator {
+		case "endbfchar":
+			for i := 0; i+1 < len(op.Operands); i += 2 {
+				src, ok1 := code(op.Operands[i])
+				dst, ok2 := text(op.Operands[i+1])
+				if ok1 && ok2 {
+					mapping[src] = dst
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(op.Operands); i += 3 {
+				first, ok1 := code(op.Operands[i])
+				last, ok2 := code(op.Operands[i+1])
+				if !ok1 || !ok2 || last < first || last-first > 0xffff {
+					continue
+				}
+				// Either an array with the text of every code or the text of the first code, incremented for the others
+				if arr, ok := op.Operands[i+2].([]interface{}); ok {
+					for j, elem := range arr {
+						if dst, ok := text(elem); ok && first+j <= last {
+							mapping[first+j] = dst
+						}
+					}
+					continue
+				}
+				dst, ok := text(op.Operands[i+2])
+				if !ok || dst == "" {
+					continue
+				}
+				runes := []rune(dst)
+				for c := first; c <= last; c++ {
+					mapping[c] = string(runes[:len(runes)-1]) + string(runes[len(runes)-1]+rune(c-first))
+				}
+			}
+		}
+	}
+	return mapping
+}
+
+// decodePDFString converts a PDF string to UTF-8, handling UTF-16BE strings with a byte order mark.
+// Other strings are treated as single byte encoded, which covers the standard Latin encodings.
+func decodePDFString(s pdfString) string {
+	b := []byte(s)
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		u := make([]uint16, 0, (len(b)-2)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// readLiteralString reads a (...) string starting at data[start], resolving escapes
+func readLiteralString(data []byte, start int) (pdfString, int) {
+	var sb strings.Builder
+	depth := 0
+	i := start
+	for i < len(data) {
+		c := data[i]
+		switch {
+		case c == '(':
+			if depth > 0 {
+				sb.WriteByte(c)
+			}
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+			if depth == 0 {
+				return pdfString(sb.String()), i
+			}
+			sb.WriteByte(c)
+		case c == '\\' && i+1 < len(data):
+			i++
+			e := data[i]
+			switch e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case '\r':
+				// Line continuation
+				if i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					for n := 0; n < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; n++ {
+						v = v*8 + int(data[i]-'0')
+						i++
+					}
+					sb.WriteByte(byte(v))
+					continue
+				}
+				sb.WriteByte(e)
+			}
+			i++
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return pdfString(sb.String()), i
+}
+
+// readHexString reads a <...> string starting at data[start]
+func readHexString(data []byte, start int) (pdfString, int) {
+	var digits []byte
+	i := start + 1
+	for i < len(data) && data[i] != '>' {
+		if isHexDigit(data[i]) {
+			digits = append(digits, data[i])
+		}
+		i++
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for j := range out {
+		v, _ := strconv.ParseUint(string(digits[2*j:2*j+2]), 16, 8)
+		out[j] = byte(v)
+	}
+	return pdfString(out), i + 1
+}
+
+// skipDictionary skips a <<...>> dictionary starting at data[start], including nested ones
+func skipDictionary(data []byte, start int) int {
+	depth := 0
+	i := start
+	for i < len(data) {
+		switch {
+		case data[i] == '(':
+			_, i = readLiteralString(data, i)
+			continue
+		case i+1 < len(data) && data[i] == '<' && data[i+1] == '<':
+			depth++
+			i += 2
+			continue
+		case i+1 < len(data) && data[i] == '>' && data[i+1] == '>':
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+			continue
+		}
+		i++
+	}
+	return i
+}
+
+// skipInlineImage skips the binary data of an inline image up to and including the EI operator
+func skipInlineImage(data []byte, start int) int {
+	for i := start + 1; i+2 <= len(data); i++ {
+		if data[i] == 'E' && data[i+1] == 'I' && isPDFWhitespace(data[i-1]) &&
+			(i+2 == len(data) || isPDFWhitespace(data[i+2])) {
+			return i + 2
+		}
+	}
+	return len(data)
+}
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
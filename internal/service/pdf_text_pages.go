@@ -0,0 +1,339 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"synth.com/file_converter/internal/model"
+)
+
+// PDFTextRun is a piece of text painted by a single text operator. Coordinates are in points from the lower left
+// corner of the page, x and y being the start of the baseline.
+type PDFTextRun struct {
+	Text     string  `json:"text"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	FontSize float64 `json:"font_size"`
+	Font     string  `json:"font,omitempty"`
+}
+
+// PDFTextPage holds the text of a PDF page and the runs it consists of
+type PDFTextPage struct {
+	Page   int          `json:"page"`
+	Width  float64      `json:"width"`
+	Height float64      `json:"height"`
+	Text   string       `json:"text"`
+	Runs   []PDFTextRun `json:"runs"`
+}
+
+// PDFTextResult is the result of a structured PDF text extraction
+type PDFTextResult struct {
+	PageCount int           `json:"page_count"`
+	Pages     []PDFTextPage `json:"pages"`
+}
+
+// ExtractPDFText extracts the text of the selected pages of a PDF page by page, decrypting it with the password if it
+// is encrypted. The text follows the content stream order unless the layout option asks for column reading order.
+func ExtractPDFText(file io.Reader, opts model.ConvertOptions) (*PDFTextResult, error) {
+	ctx, err := readPDFWithPassword(file, pdfmodel.EXTRACTCONTENT, opts.Password)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := selectPDFPages(ctx.PageCount, opts.Pages)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PDFTextResult{PageCount: ctx.PageCount, Pages: make([]PDFTextPage, 0, len(pages))}
+	for _, pageNr := range pages {
+		page, err := extractPDFPageText(ctx, pageNr, opts.Layout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract text from PDF: %w", err)
+		}
+		result.Pages = append(result.Pages, *page)
+	}
+	return result, nil
+}
+
+// extractPDFPageText extracts the text and the positioned runs of a single page
+func extractPDFPageText(ctx *pdfmodel.Context, pageNr int, layout bool) (*PDFTextPage, error) {
+	_, _, inh, err := ctx.PageDict(pageNr, true)
+	if err != nil {
+		return nil, err
+	}
+	page := &PDFTextPage{Page: pageNr, Runs: []PDFTextRun{}}
+
+	r, err := pdfcpu.ExtractPageContent(ctx, pageNr)
+	if err != nil {
+		return nil, err
+	}
+	var content []byte
+	if r != nil {
+		if content, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+
+	fonts := pageTextFonts(ctx, inh.Resources)
+	runs := extractContentRuns(content, fonts)
+	// Positions are given relative to the visible page rather than the origin of user space
+	var originX, originY float64
+	if inh.MediaBox != nil {
+		originX, originY = inh.MediaBox.LL.X, inh.MediaBox.LL.Y
+		page.Width, page.Height = roundPoints(inh.MediaBox.Width()), roundPoints(inh.MediaBox.Height())
+	}
+	for i := range runs {
+		runs[i].X = roundPoints(runs[i].X - originX)
+		runs[i].Y = roundPoints(runs[i].Y - originY)
+		runs[i].Width = roundPoints(runs[i].Width)
+		runs[i].FontSize = roundPoints(runs[i].FontSize)
+	}
+
+	if layout {
+		lines := layoutTextLines(runs, medianFontSize(runs))
+		var sb strings.Builder
+		for _, line := range lines {
+			sb.WriteString(joinTextLine(line))
+			sb.WriteString("\n")
+			page.Runs = append(page.Runs, line...)
+		}
+		page.Text = sb.String()
+	} else {
+		page.Text = extractContentText(content, fonts)
+		page.Runs = append(page.Runs, runs...)
+	}
+	return page, nil
+}
+
+// pageTextFonts returns the text mappings and glyph widths of the fonts in the page resources, keyed by resource name
+func pageTextFonts(ctx *pdfmodel.Context, resources types.Dict) map[string]*textFont {
+	fonts := make(map[string]*textFont)
+	if resources == nil {
+		return fonts
+	}
+	fontDict, err := ctx.DereferenceDict(resources["Font"])
+	if err != nil || fontDict == nil {
+		return fonts
+	}
+	for name, obj := range fontDict {
+		d, err := ctx.DereferenceDict(obj)
+		if err != nil || d == nil {
+			continue
+		}
+		fonts[name] = loadTextFont(ctx, d)
+	}
+	return fonts
+}
+
+// loadTextFont reads the ToUnicode CMap and the glyph widths of a font dictionary. Simple fonts take their widths
+// from Widths or, for the standard 14 fonts, from their built-in metrics; composite fonts from the W array of their
+// descendant font.
+func loadTextFont(ctx *pdfmodel.Context, d types.Dict) *textFont {
+	f := &textFont{Widths: make(map[int]float64), DefaultWidth: 500}
+	if baseFont := d.NameEntry("BaseFont"); baseFont != nil {
+		f.Name = *baseFont
+	}
+	if obj, ok := d.Find("ToUnicode"); ok {
+		if sd, _, err := ctx.DereferenceStreamDict(obj); err == nil && sd != nil && sd.Decode() == nil {
+			f.ToUnicode = parseToUnicode(sd.Content)
+		}
+	}
+
+	if subtype := d.NameEntry("Subtype"); subtype != nil && *subtype == "Type0" {
+		f.TwoByte = true
+		f.DefaultWidth = 1000
+		descendants, err := ctx.DereferenceArray(d["DescendantFonts"])
+		if err != nil || len(descendants) == 0 {
+			return f
+		}
+		cidFont, err := ctx.DereferenceDict(descendants[0])
+		if err != nil || cidFont == nil {
+			return f
+		}
+		if dw, ok := dereferenceNumber(ctx, cidFont["DW"]); ok {
+			f.DefaultWidth = dw
+		}
+		if w, err := ctx.DereferenceArray(cidFont["W"]); err == nil {
+			loadCIDWidths(ctx, w, f.Widths)
+		}
+		return f
+	}
+
+	if descriptor, err := ctx.DereferenceDict(d["FontDescriptor"]); err == nil && descriptor != nil {
+		if mw, ok := dereferenceNumber(ctx, descriptor["MissingWidth"]); ok && mw > 0 {
+			f.DefaultWidth = mw
+		}
+	}
+	widths, err := ctx.DereferenceArray(d["Widths"])
+	if err == nil && len(widths) > 0 {
+		firstChar, _ := dereferenceNumber(ctx, d["FirstChar"])
+		for i, obj := range widths {
+			if w, ok := dereferenceNumber(ctx, obj); ok {
+				f.Widths[int(firstChar)+i] = w
+			}
+		}
+		return f
+	}
+	// The standard 14 fonts may omit their widths
+	if font.IsCoreFont(f.Name) {
+		for code := 0; code < 256; code++ {
+			if w := font.CharWidth(f.Name, rune(code)); w > 0 {
+				f.Widths[code] = float64(w)
+			}
+		}
+	}
+	return f
+}
+
+// loadCIDWidths reads the W array of a CID font, made of "first [w1 w2 ...]" and "first last w" entries
+func loadCIDWidths(ctx *pdfmodel.Context, w types.Array, widths map[int]float64) {
+	for i := 0; i+1 < len(w); {
+		first, ok := dereferenceNumber(ctx, w[i])
+		if !ok {
+			return
+		}
+		if list, err := ctx.DereferenceArray(w[i+1]); err == nil && list != nil {
+			for j, obj := range list {
+				if width, ok := dereferenceNumber(ctx, obj); ok {
+					widths[int(first)+j] = width
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last, ok1 := dereferenceNumber(ctx, w[i+1])
+		width, ok2 := dereferenceNumber(ctx, w[i+2])
+		if !ok1 || !ok2 || last-first > 0xffff {
+			return
+		}
+		for code := int(first); code <= int(last); code++ {
+			widths[code] = width
+		}
+		i += 3
+	}
+}
+
+// dereferenceNumber resolves an integer or real object
+func dereferenceNumber(ctx *pdfmodel.Context, obj types.Object) (float64, bool) {
+	if obj == nil {
+		return 0, false
+	}
+	n, err := ctx.DereferenceNumber(obj)
+	return n, err == nil
+}
+
+// layoutTextLines orders runs into lines in column reading order by recursively cutting the page along whitespace,
+// the recursive XY-cut. Columns separated by a vertical gap of at least the gutter width are read one after the
+// other, left to right; otherwise the runs are split at the widest horizontal gap and read top to bottom.
+func layoutTextLines(runs []PDFTextRun, gutter float64) [][]PDFTextRun {
+	if len(runs) == 0 {
+		return nil
+	}
+	if columns := splitTextColumns(runs, gutter); len(columns) > 1 {
+		var lines [][]PDFTextRun
+		for _, column := range columns {
+			lines = append(lines, layoutTextLines(column, gutter)...)
+		}
+		return lines
+	}
+	if above, below, ok := splitTextRows(runs); ok {
+		return append(layoutTextLines(above, gutter), layoutTextLines(below, gutter)...)
+	}
+
+	// Runs sharing a line are read left to right
+	line := append([]PDFTextRun(nil), runs...)
+	sort.SliceStable(line, func(i, j int) bool { return line[i].X < line[j].X })
+	return [][]PDFTextRun{line}
+}
+
+// splitTextColumns splits runs spanning several lines at vertical gaps of at least the gutter width
+func splitTextColumns(runs []PDFTextRun, gutter float64) [][]PDFTextRun {
+	// Gaps within a single line are spaces between words, not columns
+	minY, maxY := runs[0].Y, runs[0].Y
+	for _, run := range runs {
+		minY, maxY = math.Min(minY, run.Y), math.Max(maxY, run.Y)
+	}
+	if maxY-minY < gutter/2 {
+		return nil
+	}
+
+	sorted := append([]PDFTextRun(nil), runs...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].X < sorted[j].X })
+	columns := [][]PDFTextRun{{sorted[0]}}
+	right := sorted[0].X + sorted[0].Width
+	for _, run := range sorted[1:] {
+		if run.X-right >= gutter {
+			columns = append(columns, nil)
+		}
+		columns[len(columns)-1] = append(columns[len(columns)-1], run)
+		right = math.Max(right, run.X+run.Width)
+	}
+	return columns
+}
+
+// splitTextRows splits runs at the widest horizontal gap between them into the runs above and below it. A run
+// covers its baseline up to roughly the cap height, so that superscripts and subscripts stay on their line.
+func splitTextRows(runs []PDFTextRun) ([]PDFTextRun, []PDFTextRun, bool) {
+	sorted := append([]PDFTextRun(nil), runs...)
+	top := func(run PDFTextRun) float64 { return run.Y + 0.7*run.FontSize }
+	sort.SliceStable(sorted, func(i, j int) bool { return top(sorted[i]) > top(sorted[j]) })
+
+	split, widest := 0, 0.0
+	bottom := sorted[0].Y
+	for i, run := range sorted[1:] {
+		if gap := bottom - top(run); gap > widest {
+			split, widest = i+1, gap
+		}
+		bottom = math.Min(bottom, run.Y)
+	}
+	if split == 0 {
+		return nil, nil, false
+	}
+	return sorted[:split], sorted[split:], true
+}
+
+// joinTextLine joins the runs of a line, separating runs that are apart by a space
+func joinTextLine(line []PDFTextRun) string {
+	var sb strings.Builder
+	for i, run := range line {
+		if i > 0 {
+			prev := line[i-1]
+			gap := run.X - (prev.X + prev.Width)
+			text := sb.String()
+			if gap > 0.15*run.FontSize && !strings.HasSuffix(text, " ") && !strings.HasPrefix(run.Text, " ") {
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString(run.Text)
+	}
+	return sb.String()
+}
+
+// medianFontSize returns the median font size of the runs, which scales the gaps the layout relies on
+func medianFontSize(runs []PDFTextRun) float64 {
+	if len(runs) == 0 {
+		return 0
+	}
+	sizes := make([]float64, len(runs))
+	for i, run := range runs {
+		sizes[i] = run.FontSize
+	}
+	sort.Float64s(sizes)
+	return sizes[len(sizes)/2]
+}
+
+// roundPoints rounds a length in points to two decimals
+func roundPoints(v float64) float64 {
+	return math.Round(v*100) / 100
+}
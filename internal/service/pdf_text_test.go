@@ -0,0 +1,278 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"synth.com/file_converter/internal/model"
+)
+
+// formatOps writes content stream operators with their operands, names with a slash and strings quoted
+func formatOps(ops []contentOp) string {
+	var format func(v interface{}) string
+	format = func(v interface{}) string {
+		switch v := v.(type) {
+		case pdfName:
+			return "/" + string(v)
+		case pdfString:
+			return fmt.Sprintf("%q", string(v))
+		case []interface{}:
+			parts := make([]string, len(v))
+			for i, elem := range v {
+				parts[i] = format(elem)
+			}
+			return "[" + strings.Join(parts, " ") + "]"
+		case nil:
+			return "<<>>"
+		}
+		return fmt.Sprint(v)
+	}
+
+	var out []string
+	for _, op := range ops {
+		parts := []string{}
+		for _, operand := range op.Operands {
+			parts = append(parts, format(operand))
+		}
+		out = append(out, strings.Join(append(parts, op.Operator), " "))
+	}
+	return strings.Join(out, "; ")
+}
+
+func TestParseContentStream(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"text object", "BT /F1 12 Tf 72 700 Td (Hello) Tj ET", `BT; /F1 12 Tf; 72 700 Td; "Hello" Tj; ET`},
+		{"numbers", "1 0 0 1 -2.5 .5 cm +3 -.25 Td", "1 0 0 1 -2.5 0.5 cm; 3 -0.25 Td"},
+		{"arrays", "[(A) -120 (B) [1 2]] TJ", `["A" -120 "B" [1 2]] TJ`},
+		{"hex strings", "<48 65 6C6c6F> Tj <414> Tj", `"Hello" Tj; "A@" Tj`},
+		{"comments", "q % save\n1 w % width\nQ", "q; 1 w; Q"},
+		{"dictionaries", "/Span << /ActualText (x) /Nested << /A 1 >> >> BDC EMC", "/Span <<>> BDC; EMC"},
+		{"names", "/GS1 gs /F#20a 9 Tf", "/GS1 gs; /F#20a 9 Tf"},
+		{"quote operators", "(a) ' 1 2 (b) \"", `"a" '; 1 2 "b" "`},
+		{"inline image", "BI /W 2 /H 1 /BPC 8 ID \x00EI\xffEIx\n EI Q", "BI; /W 2 /H 1 /BPC 8 ID; Q"},
+		{"stray delimiters", ") > } 1 w", "1 w"},
+		{"unterminated array", "[(a) (b) TJ", "TJ"},
+		{"operator without operands", "f*", "f*"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatOps(parseContentStream([]byte(tt.content))); got != tt.want {
+				t.Errorf("parseContentStream(%q) = %s, want %s", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLiteralString(t *testing.T) {
+	tests := []struct {
+		content  string
+		want     string
+		wantNext int
+	}{
+		{`(plain) Tj`, "plain", 7},
+		{`(nested (parens) ok)`, "nested (parens) ok", 20},
+		{`(a\)b\(c)`, "a)b(c", 9},
+		{`(\n\r\t\b\f\\)`, "\n\r\t\b\f\\", 14},
+		{`(\101\60\0618)`, "A0" + "18", 14},
+		{"(line\\\ncontinued)", "linecontinued", 17},
+		{"(line\\\r\ncontinued)", "linecontinued", 18},
+		{`(\q)`, "q", 4},
+		{`(unterminated`, "unterminated", 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			got, next := readLiteralString([]byte(tt.content), 0)
+			if string(got) != tt.want || next != tt.wantNext {
+				t.Errorf("readLiteralString(%q) = %q, %d, want %q, %d", tt.content, got, next, tt.want, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestDecodePDFString(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"Hello", "Hello"},
+		{"caf\xe9", "café"},
+		{"\xfe\xff\x00H\x00i\x20\xac", "Hi€"},
+		{"\xfe\xff\xd8\x3d\xde\x00", "😀"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := decodePDFString(pdfString(tt.s)); got != tt.want {
+			t.Errorf("decodePDFString(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestExtractContentText(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"lines", "BT /F1 12 Tf 72 700 Td (Hello) Tj 0 -14 Td (World) Tj ET", "Hello\nWorld\n"},
+		{"same line", "BT (Hello) Tj 40 0 Td (World) Tj ET", "Hello World\n"},
+		{"word gaps", "BT [(Hel) -20 (lo) -600 (World)] TJ ET", "Hello World\n"},
+		{"next line", "BT 14 TL (one) Tj T* (two) Tj (three) ' ET", "one\ntwo\nthree\n"},
+		{"text objects", "BT (a) Tj ET BT (b) Tj ET", "a\nb\n"},
+		{"unicode", "BT <FEFF00E9> Tj ET", "é\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractContentText([]byte(tt.content), nil); got != tt.want {
+				t.Errorf("extractContentText(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+
+	fonts := map[string]*textFont{"F2": {TwoByte: true, ToUnicode: map[int]string{0x0102: "fi", 0x0103: "x"}}}
+	if got := extractContentText([]byte("BT /F2 10 Tf <01020103> Tj ET"), fonts); got != "fix\n" {
+		t.Errorf("extractContentText with a composite font = %q, want %q", got, "fix\n")
+	}
+}
+
+func TestParseToUnicode(t *testing.T) {
+	cmap := `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange <0000> <FFFF> endcodespacerange
+2 beginbfchar
+<0003> <0020>
+<0011> <00660069>
+endbfchar
+2 beginbfrange
+<0024> <0026> <0041>
+<0030> <0031> [<0078> <D83DDE00>]
+endbfrange
+1 beginbfrange
+<0040> <0010> <0061>
+endbfrange
+endcmap`
+	mapping := parseToUnicode([]byte(cmap))
+
+	want := map[int]string{0x03: " ", 0x11: "fi", 0x24: "A", 0x25: "B", 0x26: "C", 0x30: "x", 0x31: "😀"}
+	if len(mapping) != len(want) {
+		t.Errorf("parseToUnicode mapped %d codes, want %d: %v", len(mapping), len(want), mapping)
+	}
+	for code, text := range want {
+		if mapping[code] != text {
+			t.Errorf("code %04x maps to %q, want %q", code, mapping[code], text)
+		}
+	}
+}
+
+func TestExtractContentRuns(t *testing.T) {
+	fonts := map[string]*textFont{"F1": {Name: "Helvetica", DefaultWidth: 500, Widths: map[int]float64{'i': 250}}}
+	tests := []struct {
+		name, content string
+		want          string // Runs as text@x,y+width/size
+	}{
+		{
+			name:    "positioned",
+			content: "BT /F1 10 Tf 72 700 Td (Hello) Tj 0 -20 Td (hi) Tj ET",
+			want:    "Hello@72,700+25/10 hi@72,680+7.5/10",
+		},
+		{
+			name:    "advance within a line",
+			content: "BT /F1 10 Tf 100 500 Td (ab) Tj (cd) Tj ET",
+			want:    "ab@100,500+10/10 cd@110,500+10/10",
+		},
+		{
+			name:    "text matrix and scale",
+			content: "BT /F1 1 Tf 12 0 0 12 50 400 Tm (aa) Tj ET",
+			want:    "aa@50,400+12/12",
+		},
+		{
+			name:    "transformation matrix",
+			content: "q 2 0 0 2 10 10 cm BT /F1 10 Tf 5 5 Td (a) Tj ET Q BT /F1 10 Tf 5 5 Td (a) Tj ET",
+			want:    "a@20,20+10/20 a@5,5+5/10",
+		},
+		{
+			name:    "kerning and word gap",
+			content: "BT /F1 10 Tf 0 0 Td [(a) -500 (b)] TJ ET",
+			want:    "a b@0,0+15/10",
+		},
+		{
+			name:    "leading and spacing",
+			content: "BT /F1 10 Tf 14 TL 2 Tc 0 100 Td (a b) Tj T* (c) Tj ET",
+			want:    "a b@0,100+21/10 c@0,86+7/10",
+		},
+		{
+			name:    "unknown font",
+			content: "BT /F9 20 Tf 10 10 Td (ab) Tj ET",
+			want:    "ab@10,10+20/20",
+		},
+		{
+			name:    "blank text",
+			content: "BT /F1 10 Tf (   ) Tj ET",
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, run := range extractContentRuns([]byte(tt.content), fonts) {
+				got = append(got, fmt.Sprintf("%s@%g,%g+%g/%g", run.Text, run.X, run.Y, run.Width, run.FontSize))
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("extractContentRuns(%q) = %s, want %s", tt.content, strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutTextLines(t *testing.T) {
+	// Two columns written row by row across the page, as a content stream may paint them, under a full-width title
+	runs := []PDFTextRun{
+		{Text: "Title", X: 50, Y: 760, Width: 60, FontSize: 14},
+		{Text: "left one", X: 50, Y: 700, Width: 40, FontSize: 10},
+		{Text: "right one", X: 320, Y: 700, Width: 45, FontSize: 10},
+		{Text: "left two", X: 50, Y: 686, Width: 40, FontSize: 10},
+		{Text: "right two", X: 320, Y: 686, Width: 45, FontSize: 10},
+		{Text: "three", X: 73, Y: 672, Width: 25, FontSize: 10},
+		{Text: "left", X: 50, Y: 672, Width: 20, FontSize: 10},
+	}
+	var got []string
+	for _, line := range layoutTextLines(runs, 10) {
+		got = append(got, joinTextLine(line))
+	}
+	want := "Title|left one|left two|left three|right one|right two"
+	if strings.Join(got, "|") != want {
+		t.Errorf("layoutTextLines = %s, want %s", strings.Join(got, "|"), want)
+	}
+}
+
+func TestExtractPDFTextLayout(t *testing.T) {
+	content := "BT /F1 10 Tf 50 700 Td (Left one) Tj 270 0 Td (Right one) Tj -270 -14 Td (Left two) Tj 270 0 Td (Right two) Tj ET"
+	data := buildPDF(
+		[]string{"<< /Type /Catalog /Pages 2 0 R >>"},
+		[]string{"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+		[]string{"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>"},
+		[]string{"", content},
+		[]string{"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"},
+	)
+	tests := []struct {
+		layout bool
+		want   string
+	}{
+		{false, "Left one Right one\nLeft two Right two\n"},
+		{true, "Left one\nLeft two\nRight one\nRight two\n"},
+	}
+	for _, tt := range tests {
+		result, err := ExtractPDFText(bytes.NewReader(data), model.ConvertOptions{Layout: tt.layout})
+		if err != nil {
+			t.Fatalf("ExtractPDFText: %v", err)
+		}
+		if len(result.Pages) != 1 {
+			t.Fatalf("got %d pages, want 1", len(result.Pages))
+		}
+		if got := result.Pages[0].Text; got != tt.want {
+			t.Errorf("layout %v: text = %q, want %q", tt.layout, got, tt.want)
+		}
+	}
+}
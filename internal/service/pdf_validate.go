@@ -0,0 +1,233 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// pdfHeaderWindow is how far into a file the %PDF- header may start, as readers tolerate leading garbage
+const pdfHeaderWindow = 1024
+
+var (
+	startXRefPattern = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF`)
+	xrefObjPattern   = regexp.MustCompile(`^\d+\s+\d+\s+obj\b`)
+)
+
+// PDFValidationIssue is a problem found in a PDF by a structure check or by the relaxed or strict validation
+type PDFValidationIssue struct {
+	Check    string `json:"check"`    // structure, relaxed or strict
+	Severity string `json:"severity"` // error, or warning for problems the requested mode tolerates
+	Message  string `json:"message"`
+}
+
+// PDFValidationResult holds the outcome of validating a PDF
+type PDFValidationResult struct {
+	Valid      bool                 `json:"valid"`
+	Mode       string               `json:"mode"`
+	Repairable bool                 `json:"repairable"` // Whether repair mode can rewrite an invalid PDF
+	Version    string               `json:"version,omitempty"`
+	PageCount  int                  `json:"page_count,omitempty"`
+	Issues     []PDFValidationIssue `json:"issues"`
+}
+
+// ProcessPDFValidation handles the validation request for an uploaded PDF: the issues are returned as JSON,
+// or in repair mode the rewritten PDF
+func ProcessPDFValidation(file io.Reader, opts model.ValidateOptions) response.APIResponse {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return newConversionErrorResponse("PDF validation failed", err)
+	}
+
+	if opts.Repair {
+		repaired, err := RepairPDF(data, opts.Password)
+		if err != nil {
+			return newConversionErrorResponse("PDF repair failed", err)
+		}
+		return response.NewSuccessResponse("PDF repaired successfully", repaired)
+	}
+
+	result, err := ValidatePDF(data, opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF validation failed", err)
+	}
+	return response.NewSuccessResponse("PDF validated successfully", result)
+}
+
+// ValidatePDF checks the file structure of a PDF and validates it with pdfcpu in both relaxed and strict mode.
+// The PDF is valid when the requested mode, relaxed by default, finds no error; strict violations are
+// reported as warnings in relaxed mode. pdfcpu validates the file with its header and end of file marker in place,
+// as repair puts them, leaving those problems to the structure check.
+func ValidatePDF(data []byte, opts model.ValidateOptions) (*PDFValidationResult, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = "relaxed"
+	}
+	result := &PDFValidationResult{Mode: mode, Issues: pdfStructureIssues(data)}
+	prepared := prepareRepair(data)
+	relaxedOK := false
+
+	for _, check := range []struct {
+		name string
+		mode int
+	}{{"relaxed", pdfmodel.ValidationRelaxed}, {"strict", pdfmodel.ValidationStrict}} {
+		ctx, err := validatePDFData(prepared, check.mode, opts.Password)
+		if err != nil {
+			// A wrong password or an exceeded limit fails the request instead of being an issue of the file
+			var corruptErr *utils.CorruptFileError
+			if !errors.As(err, &corruptErr) {
+				return nil, err
+			}
+			severity := "error"
+			if check.name == "strict" && mode == "relaxed" {
+				severity = "warning"
+			}
+			result.Issues = append(result.Issues, PDFValidationIssue{Check: check.name, Severity: severity, Message: pdfIssueMessage(err)})
+			continue
+		}
+		if check.name == "relaxed" {
+			relaxedOK = true
+			result.Version = ctx.VersionString()
+			result.PageCount = ctx.PageCount
+		}
+	}
+
+	result.Valid = true
+	for _, issue := range result.Issues {
+		if issue.Severity == "error" {
+			result.Valid = false
+		}
+	}
+	// Repair rewrites what the relaxed validation read, so a PDF it accepts can be repaired
+	result.Repairable = !result.Valid && relaxedOK
+	return result, nil
+}
+
+// RepairPDF rewrites a PDF that relaxed validation accepts, once the header and end of file marker are put in place,
+// with a rebuilt cross-reference table
+func RepairPDF(data []byte, password string) ([]byte, error) {
+	ctx, err := validatePDFData(prepareRepair(data), pdfmodel.ValidationRelaxed, password)
+	if err != nil {
+		var corruptErr *utils.CorruptFileError
+		if errors.As(err, &corruptErr) {
+			corruptErr.Msg = "the PDF cannot be repaired: " + corruptErr.Msg
+		}
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// prepareRepair cuts off garbage before the header and appends a missing end of file marker
+func prepareRepair(data []byte) []byte {
+	if i := bytes.Index(data, []byte("%PDF-")); i > 0 && i < pdfHeaderWindow {
+		data = data[i:]
+	}
+	if !bytes.Contains(pdfTail(data), []byte("%%EOF")) {
+		data = append(append([]byte{}, data...), "\n%%EOF\n"...)
+	}
+	return data
+}
+
+// validatePDFData reads and validates a PDF in the given validation mode. pdfcpu may panic on badly damaged input,
+// which is reported as a corrupt PDF like any other read failure.
+func validatePDFData(data []byte, mode int, password string) (ctx *pdfmodel.Context, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ctx, err = nil, &utils.CorruptFileError{Format: "PDF", Msg: fmt.Sprint(r)}
+		}
+	}()
+
+	conf := pdfmodel.NewDefaultConfiguration()
+	conf.ValidationMode = mode
+	conf.UserPW, conf.OwnerPW = password, password
+	ctx, err = api.ReadContext(bytes.NewReader(data), conf)
+	if err != nil {
+		return nil, pdfReadError(err)
+	}
+	if err := api.ValidateContext(ctx); err != nil {
+		return nil, pdfReadError(err)
+	}
+	if err := checkPDFPages(ctx.PageCount); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// pdfStructureIssues checks the header, the end of file marker and the offset of the cross-reference table, which
+// pdfcpu silently works around by scanning the whole file
+func pdfStructureIssues(data []byte) []PDFValidationIssue {
+	issues := []PDFValidationIssue{}
+	add := func(severity, msg string) {
+		issues = append(issues, PDFValidationIssue{Check: "structure", Severity: severity, Message: msg})
+	}
+
+	header := bytes.Index(data, []byte("%PDF-"))
+	switch {
+	case header < 0 || header >= pdfHeaderWindow:
+		add("error", "the %PDF- header is missing, the file is not a PDF")
+		return issues
+	case header > 0:
+		add("warning", fmt.Sprintf("%d bytes of garbage precede the %%PDF- header", header))
+	}
+
+	tail := pdfTail(data)
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		add("error", "the end of file marker is missing, the file may be truncated")
+		return issues
+	}
+	// The last startxref wins, earlier ones belong to incremental updates
+	matches := startXRefPattern.FindAllSubmatch(tail, -1)
+	if matches == nil {
+		add("error", "the startxref offset of the cross-reference table is missing")
+		return issues
+	}
+	m := matches[len(matches)-1]
+	// Offsets count from the start of the file, or from the header when garbage precedes it
+	offset, err := strconv.Atoi(string(m[1]))
+	if err != nil || !xrefAt(data, offset) && !xrefAt(data, header+offset) {
+		add("error", fmt.Sprintf("startxref points to offset %s, where there is no cross-reference table", m[1]))
+	}
+	return issues
+}
+
+// xrefAt reports whether a cross-reference table or stream starts at the offset
+func xrefAt(data []byte, offset int) bool {
+	if offset >= len(data) {
+		return false
+	}
+	data = bytes.TrimLeft(data[offset:], " \t\r\n")
+	if bytes.HasPrefix(data, []byte("xref")) {
+		return true
+	}
+	// A cross-reference stream is an indirect object
+	return xrefObjPattern.Match(data[:min(len(data), 32)])
+}
+
+// pdfTail returns the end of a PDF, where the end of file marker and the startxref offset are located
+func pdfTail(data []byte) []byte {
+	return bytes.TrimRight(data[max(0, len(data)-pdfHeaderWindow):], "\x00")
+}
+
+// pdfIssueMessage returns the message of a read or validation failure without its error category
+func pdfIssueMessage(err error) string {
+	var corruptErr *utils.CorruptFileError
+	if errors.As(err, &corruptErr) {
+		return corruptErr.Msg
+	}
+	return strings.TrimPrefix(err.Error(), "pdfcpu: ")
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"synth.com/file_converter/internal/model"
+)
+
+// damagedPDFs returns a valid PDF and damaged variants of it
+func damagedPDFs(t *testing.T) map[string][]byte {
+	valid := testPDF(t, 1)
+	eof := bytes.LastIndex(valid, []byte("%%EOF"))
+	startxref := bytes.LastIndex(valid, []byte("startxref"))
+	return map[string][]byte{
+		"valid":           valid,
+		"leading garbage": append([]byte("garbage\n"), valid...),
+		"missing EOF":     valid[:eof],
+		"wrong startxref": append(append([]byte{}, valid[:startxref]...), "startxref\n12\n%%EOF\n"...),
+		"not a PDF":       []byte("hello world"),
+	}
+}
+
+func TestPDFStructureIssues(t *testing.T) {
+	pdfs := damagedPDFs(t)
+	tests := []struct {
+		name string
+		want string // Severity and message prefix of the issues, joined by |
+	}{
+		{"valid", ""},
+		{"leading garbage", "warning: 8 bytes of garbage precede the %PDF- header"},
+		{"missing EOF", "error: the end of file marker is missing"},
+		{"wrong startxref", "error: startxref points to offset 12"},
+		{"not a PDF", "error: the %PDF- header is missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, issue := range pdfStructureIssues(pdfs[tt.name]) {
+				got = append(got, issue.Severity+": "+issue.Message)
+			}
+			joined := strings.Join(got, "|")
+			if tt.want == "" && joined != "" || !strings.HasPrefix(joined, tt.want) {
+				t.Errorf("pdfStructureIssues() = %q, want %q", joined, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePDF(t *testing.T) {
+	pdfs := damagedPDFs(t)
+	tests := []struct {
+		name                  string
+		wantValid, repairable bool
+	}{
+		{"valid", true, false},
+		{"leading garbage", true, false},
+		{"missing EOF", false, true},
+		{"wrong startxref", false, true},
+		{"not a PDF", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ValidatePDF(pdfs[tt.name], model.ValidateOptions{})
+			if err != nil {
+				t.Fatalf("ValidatePDF() error = %v", err)
+			}
+			if result.Valid != tt.wantValid || result.Repairable != tt.repairable {
+				t.Errorf("ValidatePDF() valid %v repairable %v, want %v and %v: %+v", result.Valid, result.Repairable, tt.wantValid, tt.repairable, result.Issues)
+			}
+			// A PDF reported as repairable must repair into a valid one
+			if result.Repairable {
+				repaired, err := RepairPDF(pdfs[tt.name], "")
+				if err != nil {
+					t.Fatalf("RepairPDF() error = %v", err)
+				}
+				if check, err := ValidatePDF(repaired, model.ValidateOptions{}); err != nil || !check.Valid {
+					t.Errorf("repaired PDF is not valid: %v %s", err, fmt.Sprint(check))
+				}
+			}
+		})
+	}
+}
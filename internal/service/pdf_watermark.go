@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	pdfmodel "github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// defaultWatermarkFont is the name pdfcpu registers the bundled Arial under
+const defaultWatermarkFont = "ArialMT"
+
+// defaultWatermarkSize is the font size of text watermarks in points
+const defaultWatermarkSize = 48
+
+var hexColorPattern = regexp.MustCompile(`^#?[0-9a-fA-F]{6}$`)
+
+// WatermarkPDF handles the watermark request for an uploaded PDF and an optional watermark image
+func WatermarkPDF(file io.Reader, stamp *model.File, opts model.WatermarkOptions) response.APIResponse {
+	data, err := AddPDFWatermark(file, stamp, opts)
+	if err != nil {
+		return newConversionErrorResponse("PDF watermarking failed", err)
+	}
+	return response.NewSuccessResponse("PDF watermarked successfully", data)
+}
+
+// AddPDFWatermark stamps the selected pages of a PDF with the watermark text, or with the image when one is given
+func AddPDFWatermark(file io.Reader, stamp *model.File, opts model.WatermarkOptions) ([]byte, error) {
+	if stamp == nil && opts.Text == "" {
+		return nil, &utils.InvalidInputError{Msg: "a watermark text or image is required"}
+	}
+
+	ctx, err := readPDF(file, pdfmodel.ADDWATERMARKS)
+	if err != nil {
+		return nil, err
+	}
+
+	var wm *pdfmodel.Watermark
+	if stamp != nil {
+		wm, err = imageWatermark(stamp, opts)
+	} else {
+		wm, err = textWatermark(opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pages, err := selectPDFPages(ctx.PageCount, opts.Pages)
+	if err != nil {
+		return nil, err
+	}
+	selected := make(types.IntSet, len(pages))
+	for _, page := range pages {
+		selected[page] = true
+	}
+
+	if err := api.WatermarkContext(ctx, selected, wm); err != nil {
+		return nil, fmt.Errorf("failed to add watermark: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// watermarkConvertedPDF stamps a PDF generated by a conversion when a watermark text or image is requested
+func watermarkConvertedPDF(data []byte, opts model.ConvertOptions) ([]byte, error) {
+	if opts.Text == "" && opts.WatermarkImage == nil {
+		return data, nil
+	}
+	return AddPDFWatermark(bytes.NewReader(data), opts.WatermarkImage, opts.WatermarkOptions)
+}
+
+// textWatermark builds a text watermark in the requested font, size and colour
+func textWatermark(opts model.WatermarkOptions) (*pdfmodel.Watermark, error) {
+	fontName, err := stampFont(opts.Font)
+	if err != nil {
+		return nil, err
+	}
+
+	size := opts.FontSize
+	if size == 0 {
+		size = defaultWatermarkSize
+	}
+	// Absolute scaling keeps the text at the given font size instead of fitting it to the page width
+	params := []string{"fontname:" + fontName, fmt.Sprintf("points:%d", size), "scalefactor:1 abs"}
+	if opts.Color != "" {
+		if !hexColorPattern.MatchString(opts.Color) {
+			return nil, &utils.InvalidInputError{Msg: fmt.Sprintf("invalid watermark colour %q, expected #rrggbb", opts.Color)}
+		}
+		params = append(params, "fillcolor:#"+strings.TrimPrefix(opts.Color, "#"))
+	}
+	params = append(params, watermarkLayout(opts)...)
+
+	wm, err := api.TextWatermark(opts.Text, strings.Join(params, ", "), opts.Mode != "watermark", false, types.POINTS)
+	if err != nil {
+		return nil, &utils.InvalidInputError{Msg: "invalid watermark: " + err.Error()}
+	}
+	return wm, nil
+}
+
+// imageWatermark builds a watermark from an uploaded image, scaled relative to the page width
+func imageWatermark(stamp *model.File, opts model.WatermarkOptions) (*pdfmodel.Watermark, error) {
+	file, err := stamp.FileContent.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", stamp.Filename, err)
+	}
+	defer file.Close()
+
+	// Re-encode the image as PNG, which pdfcpu embeds for every format the service decodes, including SVG
+	var png []byte
+	if strings.ToLower(filepath.Ext(stamp.Filename)) == ".svg" {
+		png, err = ConvertSVG(file, "png", model.ConvertOptions{})
+	} else {
+		var img image.Image
+		if img, _, err = decodeImage(file); err == nil {
+			png, err = encodeImage(img, "png")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", stamp.Filename, err)
+	}
+
+	params := []string{"scalefactor:0.5 rel"}
+	if opts.Scale > 0 {
+		params[0] = fmt.Sprintf("scalefactor:%g rel", opts.Scale)
+	}
+	params = append(params, watermarkLayout(opts)...)
+
+	wm, err := api.ImageWatermarkForReader(bytes.NewReader(png), strings.Join(params, ", "), opts.Mode != "watermark", false, types.POINTS)
+	if err != nil {
+		return nil, &utils.InvalidInputError{Msg: "invalid watermark: " + err.Error()}
+	}
+	return wm, nil
+}
+
+// watermarkLayout returns the pdfcpu parameters shared by text and image watermarks
+func watermarkLayout(opts model.WatermarkOptions) []string {
+	var params []string
+	if opts.Position != "" {
+		params = append(params, "position:"+opts.Position)
+	}
+	if opts.Opacity != nil {
+		params = append(params, fmt.Sprintf("opacity:%g", *opts.Opacity))
+	}
+	if opts.Rotation != nil {
+		params = append(params, fmt.Sprintf("rotation:%g", *opts.Rotation))
+	}
+	return params
+}
+
+// InstallFonts installs the bundled Arial as pdfcpu user font for text watermarks, headers and footers. It is called
+// once at startup, the pdfcpu user font directory is shared by all requests.
+func InstallFonts() error {
+	// The default configuration locates the pdfcpu user font directory
+	pdfmodel.NewDefaultConfiguration()
+	if font.IsUserFont(defaultWatermarkFont) {
+		return nil
+	}
+	if err := font.InstallTrueTypeFont(font.UserFontDir, defaultFontPath); err != nil {
+		return fmt.Errorf("failed to install font: %w", err)
+	}
+	return font.LoadUserFonts()
+}
+
+// stampFont resolves the requested font to a pdfcpu core font or the bundled Arial installed by InstallFonts
+func stampFont(name string) (string, error) {
+	if name == "" || strings.EqualFold(name, "arial") {
+		return defaultWatermarkFont, nil
+	}
+	if !font.SupportedFont(name) {
+		names := font.CoreFontNames()
+		sort.Strings(names)
+		return "", &utils.InvalidInputError{Msg: fmt.Sprintf("unsupported font %q, available fonts are Arial, %s",
+			name, strings.Join(names, ", "))}
+	}
+	return name, nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"synth.com/file_converter/internal/model"
+)
+
+func TestWatermarkConvertedPDF(t *testing.T) {
+	data := testPDF(t, 2)
+	tests := []struct {
+		name    string
+		opts    model.ConvertOptions
+		stamped bool
+	}{
+		{"none", model.ConvertOptions{}, false},
+		{"text", model.ConvertOptions{WatermarkOptions: model.WatermarkOptions{Text: "Draft"}}, true},
+		{"image", model.ConvertOptions{WatermarkImage: &testUploads(t, image.Pt(20, 10))[0]}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := watermarkConvertedPDF(data, tt.opts)
+			if err != nil {
+				t.Fatalf("watermarkConvertedPDF: %v", err)
+			}
+			stamped, err := api.HasWatermarks(bytes.NewReader(got), nil)
+			if err != nil {
+				t.Fatalf("HasWatermarks: %v", err)
+			}
+			if stamped != tt.stamped {
+				t.Errorf("stamped = %v, want %v", stamped, tt.stamped)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/nfnt/resize"
+)
+
+// Placeholder size limits; analysing a small copy of the image is enough and keeps it fast
+const (
+	placeholderAnalysisSize = 64
+	placeholderPreviewSize  = 16
+	defaultBlurHashX        = 4
+	defaultBlurHashY        = 3
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// ImagePlaceholder holds low-quality placeholders that can be shown while an image loads
+type ImagePlaceholder struct {
+	BlurHash       string `json:"blurhash"`
+	DominantColor  string `json:"dominant_color"`
+	AverageColor   string `json:"average_color"`
+	Preview        string `json:"preview"` // Tiny PNG as a base64 data URI
+	OriginalWidth  int    `json:"original_width"`
+	OriginalHeight int    `json:"original_height"`
+}
+
+// ImageConversionResult is the JSON result of an image conversion with placeholders
+type ImageConversionResult struct {
+	Image       []byte            `json:"image"` // Base64 encoded in JSON
+	Format      string            `json:"format"`
+	Placeholder *ImagePlaceholder `json:"placeholder"`
+}
+
+// GenerateImagePlaceholder computes the BlurHash, dominant and average colour and a tiny preview of an image
+func GenerateImagePlaceholder(img image.Image, componentsX, componentsY int) (*ImagePlaceholder, error) {
+	if componentsX == 0 {
+		componentsX = defaultBlurHashX
+	}
+	if componentsY == 0 {
+		componentsY = defaultBlurHashY
+	}
+
+	bounds := img.Bounds()
+	small := resize.Thumbnail(placeholderAnalysisSize, placeholderAnalysisSize, img, resize.Bilinear)
+
+	var preview bytes.Buffer
+	if err := png.Encode(&preview, resize.Thumbnail(placeholderPreviewSize, placeholderPreviewSize, img, resize.Bilinear)); err != nil {
+		return nil, fmt.Errorf("failed to encode preview: %w", err)
+	}
+
+	dominant, average := analyseColors(small)
+	return &ImagePlaceholder{
+		BlurHash:       encodeBlurHash(small, componentsX, componentsY),
+		DominantColor:  dominant,
+		AverageColor:   average,
+		Preview:        "data:image/png;base64," + base64.StdEncoding.EncodeToString(preview.Bytes()),
+		OriginalWidth:  bounds.Dx(),
+		OriginalHeight: bounds.Dy(),
+	}, nil
+}
+
+// Headers returns the placeholder as HTTP response headers
+func (p *ImagePlaceholder) Headers() map[string]string {
+	return map[string]string{
+		"X-Image-Blurhash":       p.BlurHash,
+		"X-Image-Dominant-Color": p.DominantColor,
+		"X-Image-Average-Color":  p.AverageColor,
+		"X-Image-Preview":        p.Preview,
+	}
+}
+
+// analyseColors returns the dominant and the average colour of an image as hex strings.
+// The dominant colour is the mean of the most populated bucket of a 4-bit per channel histogram.
+func analyseColors(img image.Image) (string, string) {
+	type bucket struct {
+		r, g, b, n uint64
+	}
+	buckets := make(map[uint32]*bucket)
+	var total bucket
+
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			// Mostly transparent pixels do not contribute to the visible colour
+			if a < 0x8000 {
+				continue
+			}
+			r8, g8, b8 := uint64(r>>8), uint64(g>>8), uint64(bl>>8)
+			key := uint32(r8>>4)<<8 | uint32(g8>>4)<<4 | uint32(b8>>4)
+			bk, ok := buckets[key]
+			if !ok {
+				bk = &bucket{}
+				buckets[key] = bk
+			}
+			bk.r, bk.g, bk.b, bk.n = bk.r+r8, bk.g+g8, bk.b+b8, bk.n+1
+			total.r, total.g, total.b, total.n = total.r+r8, total.g+g8, total.b+b8, total.n+1
+		}
+	}
+	if total.n == 0 {
+		return "#000000", "#000000"
+	}
+
+	var dominant *bucket
+	var dominantKey uint32
+	for key, bk := range buckets {
+		// Break ties on the key so the result does not depend on map iteration order
+		if dominant == nil || bk.n > dominant.n || (bk.n == dominant.n && key < dominantKey) {
+			dominant, dominantKey = bk, key
+		}
+	}
+
+	hex := func(bk *bucket) string {
+		return fmt.Sprintf("#%02x%02x%02x", bk.r/bk.n, bk.g/bk.n, bk.b/bk.n)
+	}
+	return hex(dominant), hex(&total)
+}
+
+// encodeBlurHash encodes an image as a BlurHash string with the given number of components
+func encodeBlurHash(img image.Image, componentsX, componentsY int) string {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+
+	// Convert to linear RGB once, the basis functions iterate over every pixel per component
+	linear := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			linear[y*width+x] = [3]float64{sRGBToLinear(r >> 8), sRGBToLinear(g >> 8), sRGBToLinear(bl >> 8)}
+		}
+	}
+
+	factors := make([][3]float64, 0, componentsX*componentsY)
+	for j := 0; j < componentsY; j++ {
+		for i := 0; i < componentsX; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1.0
+			}
+			var f [3]float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+					px := linear[y*width+x]
+					f[0] += basis * px[0]
+					f[1] += basis * px[1]
+					f[2] += basis * px[2]
+				}
+			}
+			scale := normalisation / float64(width*height)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+	writeBase83(&sb, (componentsX-1)+(componentsY-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+		writeBase83(&sb, quantisedMax, 1)
+	} else {
+		writeBase83(&sb, 0, 1)
+	}
+
+	writeBase83(&sb, linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4)
+	for _, f := range ac {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		writeBase83(&sb, quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2)
+	}
+	return sb.String()
+}
+
+func writeBase83(sb *strings.Builder, value, length int) {
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		sb.WriteByte(base83Chars[digit])
+	}
+}
+
+func sRGBToLinear(value uint32) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// testGradient returns an image whose red channel grows to the right and green channel downwards
+func testGradient() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 8), uint8(y * 10), 128, 255})
+		}
+	}
+	return img
+}
+
+// testSplit returns an image with a red left half and a blue right half
+func testSplit() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			c := color.RGBA{200, 30, 30, 255}
+			if x >= 10 {
+				c = color.RGBA{20, 40, 220, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestEncodeBlurHash(t *testing.T) {
+	// Expected hashes are those of the reference implementation
+	tests := []struct {
+		name                     string
+		img                      image.Image
+		componentsX, componentsY int
+		want                     string
+	}{
+		{"gradient", testGradient(), 4, 3, "LxH27k2swxX8mHWWjtf7gJfjfQfj"},
+		{"gradient average", testGradient(), 1, 1, "00H27k"},
+		{"split", testSplit(), 4, 3, "L,G;#w|AsQOIoNn~jsa}fQfQfQfQ"},
+		{"split all components", testSplit(), 9, 9, "|,G;#w|AsQOIfQwusQW@fQ" +
+			strings.Repeat("oNn~jsa}fQjsjsfRfQfQfQfQfQfQfQfQfQfQ", 4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeBlurHash(tt.img, tt.componentsX, tt.componentsY); got != tt.want {
+				t.Errorf("encodeBlurHash(%dx%d) = %q, want %q", tt.componentsX, tt.componentsY, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyseColors(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	img.Set(1, 0, color.NRGBA{255, 0, 0, 255})
+	img.Set(2, 0, color.NRGBA{0, 0, 255, 255})
+	img.Set(3, 0, color.NRGBA{0, 255, 0, 0}) // Transparent, ignored
+
+	dominant, average := analyseColors(img)
+	if dominant != "#ff0000" || average != "#aa0055" {
+		t.Errorf("analyseColors = %s, %s, want #ff0000, #aa0055", dominant, average)
+	}
+	if dominant, average := analyseColors(image.NewNRGBA(image.Rect(0, 0, 2, 2))); dominant != "#000000" || average != "#000000" {
+		t.Errorf("analyseColors of a transparent image = %s, %s, want black", dominant, average)
+	}
+}
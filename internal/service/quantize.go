@@ -0,0 +1,338 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// defaultMonochromeThreshold is the luminance at and above which a pixel becomes white
+const defaultMonochromeThreshold = 128
+
+// bayerMatrix is the 8x8 threshold map used for ordered dithering
+var bayerMatrix = [8][8]float64{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// checkQuantizeFormat rejects palette reduction for JPEG output, which cannot store indexed images and would spread
+// the reduced palette again when compressing
+func checkQuantizeFormat(targetFormat string, opts model.ConvertOptions) error {
+	if targetFormat == "jpg" && (opts.Colors > 0 || opts.Monochrome) {
+		return &utils.InvalidInputError{Msg: "colors and monochrome need an indexed output format such as png or gif, not jpg"}
+	}
+	return nil
+}
+
+// quantizeImage reduces an image to an indexed palette as requested by the conversion options
+func quantizeImage(img image.Image, opts model.ConvertOptions) *image.Paletted {
+	if opts.Monochrome {
+		threshold := defaultMonochromeThreshold
+		if opts.Threshold != nil {
+			threshold = *opts.Threshold
+		}
+		return monochromeImage(img, threshold, opts.Dither)
+	}
+
+	colors := opts.Colors
+	if colors == 0 {
+		colors = 256
+	}
+
+	var palette color.Palette
+	if opts.Quantizer == "octree" {
+		palette = octreePalette(img, colors)
+	} else {
+		palette = medianCutPalette(img, colors)
+	}
+	return ditherImage(img, palette, opts.Dither)
+}
+
+// ditherImage maps every pixel to the palette using the requested dithering method
+func ditherImage(img image.Image, palette color.Palette, dither string) *image.Paletted {
+	b := img.Bounds()
+	dst := image.NewPaletted(b, palette)
+
+	switch dither {
+	case "floyd_steinberg":
+		draw.FloydSteinberg.Draw(dst, b, img, b.Min)
+	case "ordered":
+		// Spread the threshold map over roughly one palette step per channel
+		spread := 255 / math.Max(1, math.Cbrt(float64(len(palette))))
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				offset := (bayerMatrix[y&7][x&7]/64 - 0.5) * spread
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				c.R, c.G, c.B = clampChannel(float64(c.R)+offset), clampChannel(float64(c.G)+offset), clampChannel(float64(c.B)+offset)
+				dst.SetColorIndex(x, y, uint8(palette.Index(c)))
+			}
+		}
+	default:
+		draw.Draw(dst, b, img, b.Min, draw.Src)
+	}
+	return dst
+}
+
+// monochromeImage converts an image to 1-bit black and white around the luminance threshold
+func monochromeImage(img image.Image, threshold int, dither string) *image.Paletted {
+	b := img.Bounds()
+	dst := image.NewPaletted(b, color.Palette{color.Black, color.White})
+	width, height := b.Dx(), b.Dy()
+
+	// Work on luminance values so error diffusion can carry fractions between pixels
+	lum := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			lum[y*width+x] = float64(color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y)
+		}
+	}
+
+	t := float64(threshold)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := lum[y*width+x]
+			if dither == "ordered" {
+				v += (bayerMatrix[y&7][x&7]/64 - 0.5) * 255
+			}
+
+			out := 0.0
+			if v >= t {
+				out = 255
+				dst.SetColorIndex(b.Min.X+x, b.Min.Y+y, 1)
+			}
+
+			if dither == "floyd_steinberg" {
+				diffuse := func(dx, dy int, weight float64) {
+					nx, ny := x+dx, y+dy
+					if nx >= 0 && nx < width && ny < height {
+						lum[ny*width+nx] += (v - out) * weight
+					}
+				}
+				diffuse(1, 0, 7.0/16)
+				diffuse(-1, 1, 3.0/16)
+				diffuse(0, 1, 5.0/16)
+				diffuse(1, 1, 1.0/16)
+			}
+		}
+	}
+	return dst
+}
+
+// medianCutPalette builds a palette by repeatedly splitting the colour box with the widest channel range at its median
+func medianCutPalette(img image.Image, colors int) color.Palette {
+	pixels, hasTransparency := opaquePixels(img)
+	if hasTransparency {
+		colors--
+	}
+
+	boxes := [][]color.NRGBA{pixels}
+	for len(boxes) < colors {
+		// Split the box with the largest channel range that still has more than one pixel
+		best, bestRange, bestChannel := -1, -1, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			channel, rng := widestChannel(box)
+			if rng > bestRange {
+				best, bestRange, bestChannel = i, rng, channel
+			}
+		}
+		if best < 0 || bestRange == 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.Slice(box, func(a, b int) bool {
+			return channelValue(box[a], bestChannel) < channelValue(box[b], bestChannel)
+		})
+		mid := len(box) / 2
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes)+1)
+	for _, box := range boxes {
+		if len(box) == 0 {
+			continue
+		}
+		var r, g, b int
+		for _, c := range box {
+			r, g, b = r+int(c.R), g+int(c.G), b+int(c.B)
+		}
+		n := len(box)
+		palette = append(palette, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: 255})
+	}
+	if hasTransparency {
+		palette = append(palette, color.Transparent)
+	}
+	return palette
+}
+
+// octreeNode is a node of the colour octree; leaves accumulate the colours that fall into them
+type octreeNode struct {
+	children   [8]*octreeNode
+	r, g, b, n int
+	leaf       bool
+}
+
+// octreePalette builds a palette by inserting every colour into an octree and merging
+// the deepest nodes until no more than the requested number of leaves remain
+func octreePalette(img image.Image, colors int) color.Palette {
+	pixels, hasTransparency := opaquePixels(img)
+	if hasTransparency {
+		colors--
+	}
+
+	const maxDepth = 8
+	root := &octreeNode{}
+	var levels [maxDepth][]*octreeNode
+	leaves := 0
+
+	for _, c := range pixels {
+		node := root
+		for depth := 0; depth < maxDepth; depth++ {
+			if node.leaf {
+				break
+			}
+			shift := 7 - depth
+			idx := (int(c.R)>>shift&1)<<2 | (int(c.G)>>shift&1)<<1 | int(c.B)>>shift&1
+			if node.children[idx] == nil {
+				child := &octreeNode{leaf: depth == maxDepth-1}
+				node.children[idx] = child
+				if child.leaf {
+					leaves++
+				} else {
+					levels[depth+1] = append(levels[depth+1], child)
+				}
+			}
+			node = node.children[idx]
+		}
+		node.r, node.g, node.b, node.n = node.r+int(c.R), node.g+int(c.G), node.b+int(c.B), node.n+1
+	}
+
+	// Merge the children of the deepest inner nodes into their parent until the palette fits
+	for depth := maxDepth - 1; depth >= 0 && leaves > colors; depth-- {
+		nodes := levels[depth]
+		if depth == 0 {
+			nodes = []*octreeNode{root}
+		}
+		// Merge the least populated nodes first so frequent colours keep their detail
+		sort.Slice(nodes, func(a, b int) bool { return subtreeCount(nodes[a]) < subtreeCount(nodes[b]) })
+		for _, node := range nodes {
+			if leaves <= colors {
+				break
+			}
+			merged := 0
+			for i, child := range node.children {
+				if child == nil {
+					continue
+				}
+				node.r, node.g, node.b, node.n = node.r+child.r, node.g+child.g, node.b+child.b, node.n+child.n
+				node.children[i] = nil
+				merged++
+			}
+			node.leaf = true
+			leaves -= merged - 1
+		}
+	}
+
+	var palette color.Palette
+	var collect func(node *octreeNode)
+	collect = func(node *octreeNode) {
+		if node.leaf {
+			if node.n > 0 {
+				palette = append(palette, color.NRGBA{R: uint8(node.r / node.n), G: uint8(node.g / node.n), B: uint8(node.b / node.n), A: 255})
+			}
+			return
+		}
+		for _, child := range node.children {
+			if child != nil {
+				collect(child)
+			}
+		}
+	}
+	collect(root)
+
+	if len(palette) == 0 {
+		palette = append(palette, color.Black)
+	}
+	if hasTransparency {
+		palette = append(palette, color.Transparent)
+	}
+	return palette
+}
+
+// subtreeCount returns the number of pixels stored below an octree node
+func subtreeCount(node *octreeNode) int {
+	n := node.n
+	for _, child := range node.children {
+		if child != nil {
+			n += subtreeCount(child)
+		}
+	}
+	return n
+}
+
+// opaquePixels returns the visible pixels of an image and whether it has transparent ones
+func opaquePixels(img image.Image) ([]color.NRGBA, bool) {
+	b := img.Bounds()
+	pixels := make([]color.NRGBA, 0, b.Dx()*b.Dy())
+	hasTransparency := false
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				hasTransparency = true
+				continue
+			}
+			pixels = append(pixels, c)
+		}
+	}
+	return pixels, hasTransparency
+}
+
+// widestChannel returns the RGB channel with the largest value range within a box, and that range
+func widestChannel(box []color.NRGBA) (int, int) {
+	lo := [3]int{255, 255, 255}
+	hi := [3]int{}
+	for _, c := range box {
+		for ch := 0; ch < 3; ch++ {
+			v := channelValue(c, ch)
+			lo[ch], hi[ch] = min(lo[ch], v), max(hi[ch], v)
+		}
+	}
+	channel := 0
+	for ch := 1; ch < 3; ch++ {
+		if hi[ch]-lo[ch] > hi[channel]-lo[channel] {
+			channel = ch
+		}
+	}
+	return channel, hi[channel] - lo[channel]
+}
+
+func channelValue(c color.NRGBA, channel int) int {
+	switch channel {
+	case 0:
+		return int(c.R)
+	case 1:
+		return int(c.G)
+	default:
+		return int(c.B)
+	}
+}
+
+func clampChannel(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// testColorGradient returns an image whose pixels run through many colours, with a transparent corner
+func testColorGradient() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 4), G: uint8(y * 4), B: uint8((x + y) * 2), A: 255})
+		}
+	}
+	img.SetNRGBA(0, 0, color.NRGBA{})
+	return img
+}
+
+// testGrayRamp returns an image whose columns are the gray levels 0 to 255
+func testGrayRamp() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 256, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 256; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x)})
+		}
+	}
+	return img
+}
+
+func TestQuantizePaletteSize(t *testing.T) {
+	for _, quantizer := range []string{"median_cut", "octree"} {
+		for _, colors := range []int{2, 16, 256} {
+			for _, dither := range []string{"none", "floyd_steinberg", "ordered"} {
+				img := quantizeImage(testColorGradient(), model.ConvertOptions{Colors: colors, Quantizer: quantizer, Dither: dither})
+				if len(img.Palette) > colors {
+					t.Errorf("%s with %d colours and %s dithering: palette has %d colours", quantizer, colors, dither, len(img.Palette))
+				}
+				if len(img.Palette) < 2 {
+					t.Errorf("%s with %d colours: palette has only %d colours", quantizer, colors, len(img.Palette))
+				}
+			}
+		}
+	}
+}
+
+func TestMonochromeImage(t *testing.T) {
+	for _, dither := range []string{"none", "floyd_steinberg", "ordered"} {
+		img := quantizeImage(testColorGradient(), model.ConvertOptions{Monochrome: true, Dither: dither})
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if g := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y; g != 0 && g != 255 {
+					t.Fatalf("%s dithering: pixel (%d, %d) is %d, want 0 or 255", dither, x, y, g)
+				}
+			}
+		}
+	}
+}
+
+func TestMonochromeThreshold(t *testing.T) {
+	// Without dithering the first white column of the ramp is the threshold
+	for _, threshold := range []int{0, 64, 128, 200, 255} {
+		opts := model.ConvertOptions{Monochrome: true, Threshold: &threshold}
+		if threshold == defaultMonochromeThreshold {
+			opts.Threshold = nil
+		}
+		img := quantizeImage(testGrayRamp(), opts)
+		firstWhite := -1
+		for x := 0; x < 256; x++ {
+			if img.ColorIndexAt(x, 0) == 1 {
+				firstWhite = x
+				break
+			}
+		}
+		if firstWhite != threshold {
+			t.Errorf("threshold %d: first white column is %d", threshold, firstWhite)
+		}
+	}
+}
+
+func TestCheckQuantizeFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		opts    model.ConvertOptions
+		wantErr interface{}
+	}{
+		{"png", model.ConvertOptions{Colors: 16}, nil},
+		{"gif", model.ConvertOptions{Monochrome: true}, nil},
+		{"jpg", model.ConvertOptions{}, nil},
+		{"jpg", model.ConvertOptions{Colors: 16}, &utils.InvalidInputError{}},
+		{"jpg", model.ConvertOptions{Monochrome: true}, &utils.InvalidInputError{}},
+	}
+	for _, tt := range tests {
+		if err := checkQuantizeFormat(tt.format, tt.opts); !errorIsType(err, tt.wantErr) {
+			t.Errorf("checkQuantizeFormat(%s, %+v) = %v, want %T", tt.format, tt.opts, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nfnt/resize"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/response"
+	"synth.com/file_converter/internal/utils"
+)
+
+// namedImage is a decoded upload together with its original filename
+type namedImage struct {
+	Name  string
+	Image image.Image
+}
+
+// SpritePosition is the location of a single sprite within the sheet
+type SpritePosition struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// SpriteMap describes the sheet image and the position of every sprite in it
+type SpriteMap struct {
+	Image   string                    `json:"image"`
+	Width   int                       `json:"width"`
+	Height  int                       `json:"height"`
+	Sprites map[string]SpritePosition `json:"sprites"`
+}
+
+var cssNameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// CreateSpriteSheet handles the sprite sheet request for a set of uploaded images
+func CreateSpriteSheet(files []model.File, opts model.SpriteOptions) response.APIResponse {
+	data, err := ConvertImagesToSpriteSheet(files, opts)
+	if err != nil {
+		return newConversionErrorResponse("Sprite sheet generation failed", err)
+	}
+	return response.NewSuccessResponse("Sprite sheet generated successfully", data)
+}
+
+// CreateContactSheet handles the contact sheet request for a set of uploaded images
+func CreateContactSheet(files []model.File, opts model.ContactSheetOptions) response.APIResponse {
+	data, err := ConvertImagesToContactSheet(files, opts)
+	if err != nil {
+		return newConversionErrorResponse("Contact sheet generation failed", err)
+	}
+	return response.NewSuccessResponse("Contact sheet generated successfully", data)
+}
+
+// ConvertImagesToSpriteSheet packs the images into a single sheet and returns a ZIP
+// containing the sheet together with a JSON and a CSS map of the sprite positions
+func ConvertImagesToSpriteSheet(files []model.File, opts model.SpriteOptions) ([]byte, error) {
+	if opts.Format == "" {
+		opts.Format = "png"
+	}
+
+	images, err := decodeUploadedImages(files, opts.SpriteWidth, opts.SpriteHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	var positions []SpritePosition
+	var width, height int
+	if opts.Layout == "packed" {
+		positions, width, height = packShelves(images, opts.Padding, opts.MaxWidth)
+	} else {
+		positions, width, height = packGrid(images, opts.Padding, opts.Columns)
+	}
+
+	if (opts.MaxWidth > 0 && width > opts.MaxWidth) || (opts.MaxHeight > 0 && height > opts.MaxHeight) {
+		return nil, &utils.LimitExceededError{Msg: fmt.Sprintf("sprite sheet of %dx%d exceeds the maximum size of %dx%d", width, height, opts.MaxWidth, opts.MaxHeight)}
+	}
+	if err := checkImagePixels(width, height); err != nil {
+		return nil, err
+	}
+
+	// Draw every sprite at its packed position on a transparent sheet
+	sheet := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i, img := range images {
+		p := positions[i]
+		draw.Draw(sheet, image.Rect(p.X, p.Y, p.X+p.Width, p.Y+p.Height), img.Image, img.Image.Bounds().Min, draw.Src)
+	}
+
+	sheetData, err := encodeImage(sheet, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	sheetName := "sprite." + opts.Format
+	spriteMap := SpriteMap{Image: sheetName, Width: width, Height: height, Sprites: make(map[string]SpritePosition)}
+	names := make([]string, len(images))
+	for i, img := range images {
+		names[i] = uniqueSpriteName(spriteMap.Sprites, img.Name)
+		spriteMap.Sprites[names[i]] = positions[i]
+	}
+
+	jsonData, err := json.MarshalIndent(spriteMap, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write sprite map: %w", err)
+	}
+
+	// Write one CSS class per sprite, in upload order
+	var css strings.Builder
+	fmt.Fprintf(&css, ".sprite {\n  background-image: url(%s);\n  background-repeat: no-repeat;\n  display: inline-block;\n}\n", sheetName)
+	for i, name := range names {
+		p := positions[i]
+		fmt.Fprintf(&css, "\n.sprite-%s {\n  background-position: %dpx %dpx;\n  width: %dpx;\n  height: %dpx;\n}\n", name, -p.X, -p.Y, p.Width, p.Height)
+	}
+
+	return utils.CreateZip([]utils.ZipEntry{
+		{Name: sheetName, Data: sheetData},
+		{Name: "sprite.json", Data: jsonData},
+		{Name: "sprite.css", Data: []byte(css.String())},
+	})
+}
+
+// ConvertImagesToContactSheet renders the images as captioned thumbnails on a single sheet
+func ConvertImagesToContactSheet(files []model.File, opts model.ContactSheetOptions) ([]byte, error) {
+	if opts.Format == "" {
+		opts.Format = "png"
+	}
+	if opts.ThumbSize == 0 {
+		opts.ThumbSize = 200
+	}
+	if opts.Padding == 0 {
+		opts.Padding = 10
+	}
+	if opts.FontSize == 0 {
+		opts.FontSize = 12
+	}
+
+	images, err := decodeUploadedImages(files, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	face, err := loadFontFace(opts.FontSize)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	columns := opts.Columns
+	if columns == 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(len(images)))))
+	}
+	rows := (len(images) + columns - 1) / columns
+
+	thumbSize := int(opts.ThumbSize)
+	captionHeight := int(math.Ceil(opts.FontSize * 1.5))
+	cellWidth := thumbSize + opts.Padding
+	cellHeight := thumbSize + captionHeight + opts.Padding
+	width := columns*cellWidth + opts.Padding
+	height := rows*cellHeight + opts.Padding
+	if err := checkImagePixels(width, height); err != nil {
+		return nil, err
+	}
+
+	sheet := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(sheet, sheet.Bounds(), image.White, image.Point{}, draw.Src)
+
+	drawer := &font.Drawer{Dst: sheet, Src: image.NewUniform(color.Black), Face: face}
+	for i, img := range images {
+		cellX := opts.Padding + (i%columns)*cellWidth
+		cellY := opts.Padding + (i/columns)*cellHeight
+
+		// Center the thumbnail within its cell
+		thumb := resize.Thumbnail(opts.ThumbSize, opts.ThumbSize, img.Image, resize.Lanczos3)
+		tb := thumb.Bounds()
+		x := cellX + (thumbSize-tb.Dx())/2
+		y := cellY + (thumbSize-tb.Dy())/2
+		draw.Draw(sheet, image.Rect(x, y, x+tb.Dx(), y+tb.Dy()), thumb, tb.Min, draw.Over)
+
+		// Draw the caption centered beneath the thumbnail
+		caption := fitCaption(drawer, img.Name, thumbSize)
+		captionWidth := drawer.MeasureString(caption).Ceil()
+		drawer.Dot = fixed.P(cellX+(thumbSize-captionWidth)/2, cellY+thumbSize+int(opts.FontSize*1.2))
+		drawer.DrawString(caption)
+	}
+
+	return encodeImage(sheet, opts.Format)
+}
+
+// decodeUploadedImages decodes every upload, resizing them when a width or height is given. The images are held
+// together, so their size after resizing counts against the pixel limit in total; every image is checked before
+// it is decoded or resized.
+func decodeUploadedImages(files []model.File, width, height uint) ([]namedImage, error) {
+	if len(files) == 0 {
+		return nil, &utils.InvalidInputError{Msg: "no images uploaded"}
+	}
+
+	images := make([]namedImage, 0, len(files))
+	var total int64
+	for _, f := range files {
+		file, err := f.FileContent.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", f.Filename, err)
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Filename, err)
+		}
+
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to decode image: %w", f.Filename, err)
+		}
+		w, h := resizedSize(cfg.Width, cfg.Height, width, height)
+		if err := checkImagePixels(w, h); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Filename, err)
+		}
+		total += int64(w) * int64(h)
+		if total > config.AppLimits.MaxImagePixels {
+			return nil, &utils.LimitExceededError{Msg: fmt.Sprintf("the uploaded images have more than %d pixels in total", config.AppLimits.MaxImagePixels)}
+		}
+
+		img, _, err := decodeImage(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Filename, err)
+		}
+		if width > 0 || height > 0 {
+			img = resize.Resize(width, height, img, resize.Lanczos3)
+		}
+		images = append(images, namedImage{Name: f.Filename, Image: img})
+	}
+	return images, nil
+}
+
+// resizedSize returns the size of an image after resize.Resize, which keeps the aspect ratio for a zero width or height
+func resizedSize(imgWidth, imgHeight int, width, height uint) (int, int) {
+	// Sizes beyond any limit are clamped so they cannot overflow into negative numbers
+	width, height = min(width, math.MaxInt32), min(height, math.MaxInt32)
+	switch {
+	case width == 0 && height == 0, imgWidth == 0 || imgHeight == 0:
+		return imgWidth, imgHeight
+	case width == 0:
+		return int(float64(imgWidth) * float64(height) / float64(imgHeight)), int(height)
+	case height == 0:
+		return int(width), int(float64(imgHeight) * float64(width) / float64(imgWidth))
+	}
+	return int(width), int(height)
+}
+
+// packGrid places the images in a grid of equally sized cells
+func packGrid(images []namedImage, padding, columns int) ([]SpritePosition, int, int) {
+	if columns == 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(len(images)))))
+	}
+
+	var cellWidth, cellHeight int
+	for _, img := range images {
+		cellWidth = max(cellWidth, img.Image.Bounds().Dx())
+		cellHeight = max(cellHeight, img.Image.Bounds().Dy())
+	}
+
+	positions := make([]SpritePosition, len(images))
+	for i, img := range images {
+		b := img.Image.Bounds()
+		positions[i] = SpritePosition{
+			X:      padding + (i%columns)*(cellWidth+padding),
+			Y:      padding + (i/columns)*(cellHeight+padding),
+			Width:  b.Dx(),
+			Height: b.Dy(),
+		}
+	}
+
+	rows := (len(images) + columns - 1) / columns
+	return positions, padding + columns*(cellWidth+padding), padding + rows*(cellHeight+padding)
+}
+
+// packShelves bin-packs the images into shelves, tallest first, so the sheet stays compact
+func packShelves(images []namedImage, padding, maxWidth int) ([]SpritePosition, int, int) {
+	order := make([]int, len(images))
+	var area, widest int
+	for i, img := range images {
+		order[i] = i
+		b := img.Image.Bounds()
+		area += (b.Dx() + padding) * (b.Dy() + padding)
+		widest = max(widest, b.Dx()+2*padding)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return images[order[a]].Image.Bounds().Dy() > images[order[b]].Image.Bounds().Dy()
+	})
+
+	// Aim for a roughly square sheet unless a maximum width is given
+	shelfWidth := max(widest, int(math.Ceil(math.Sqrt(float64(area)))))
+	if maxWidth > 0 {
+		shelfWidth = max(widest, maxWidth)
+	}
+
+	positions := make([]SpritePosition, len(images))
+	x, y, shelfHeight, width := padding, padding, 0, 0
+	for _, i := range order {
+		b := images[i].Image.Bounds()
+		if x+b.Dx()+padding > shelfWidth && x > padding {
+			x = padding
+			y += shelfHeight + padding
+			shelfHeight = 0
+		}
+		positions[i] = SpritePosition{X: x, Y: y, Width: b.Dx(), Height: b.Dy()}
+		x += b.Dx() + padding
+		width = max(width, x)
+		shelfHeight = max(shelfHeight, b.Dy())
+	}
+	return positions, width, y + shelfHeight + padding
+}
+
+// uniqueSpriteName derives a CSS friendly name from a filename that is not yet used in the map
+func uniqueSpriteName(used map[string]SpritePosition, filename string) string {
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	base = strings.Trim(cssNameInvalidChars.ReplaceAllString(base, "-"), "-")
+	if base == "" {
+		base = "sprite"
+	}
+
+	name := base
+	for n := 2; ; n++ {
+		if _, ok := used[name]; !ok {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// loadFontFace loads the bundled font at the given size
+func loadFontFace(size float64) (font.Face, error) {
+	data, err := os.ReadFile(defaultFontPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font: %w", err)
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %w", err)
+	}
+	return face, nil
+}
+
+// fitCaption shortens a caption with an ellipsis until it fits within the given width
+func fitCaption(drawer *font.Drawer, caption string, width int) string {
+	if drawer.MeasureString(caption).Ceil() <= width {
+		return caption
+	}
+	runes := []rune(caption)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		shortened := string(runes) + "…"
+		if drawer.MeasureString(shortened).Ceil() <= width {
+			return shortened
+		}
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"math"
+	"testing"
+
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// testUploads builds uploads of blank PNGs of the given sizes
+func testUploads(t *testing.T, sizes ...image.Point) []model.File {
+	t.Helper()
+	var files []testFile
+	for _, size := range sizes {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, size.X, size.Y))); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, testFile{name: "image.png", data: buf.Bytes()})
+	}
+	return testUploadFiles(t, files...)
+}
+
+func TestResizedSize(t *testing.T) {
+	tests := []struct {
+		name                string
+		imgWidth, imgHeight int
+		width, height       uint
+		wantW, wantH        int
+	}{
+		{"unchanged", 200, 100, 0, 0, 200, 100},
+		{"width only", 200, 100, 50, 0, 50, 25},
+		{"height only", 200, 100, 0, 50, 100, 50},
+		{"both", 200, 100, 30, 40, 30, 40},
+		{"clamped", 200, 100, math.MaxUint64, 0, math.MaxInt32, math.MaxInt32 / 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h := resizedSize(tt.imgWidth, tt.imgHeight, tt.width, tt.height)
+			if w != tt.wantW || h != tt.wantH {
+				t.Errorf("resizedSize() = %dx%d, want %dx%d", w, h, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestDecodeUploadedImagesLimits(t *testing.T) {
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxImagePixels = 10000
+
+	tests := []struct {
+		name          string
+		sizes         []image.Point
+		width, height uint
+		wantErr       interface{}
+	}{
+		{"no uploads", nil, 0, 0, &utils.InvalidInputError{}},
+		{"within the limit", []image.Point{{50, 50}, {50, 50}}, 0, 0, nil},
+		{"total over the limit", []image.Point{{50, 50}, {50, 50}, {50, 50}, {50, 51}}, 0, 0, &utils.LimitExceededError{}},
+		{"resized over the limit", []image.Point{{10, 10}}, 200000, 200000, &utils.LimitExceededError{}},
+		{"resized within the limit", []image.Point{{50, 50}, {50, 50}, {50, 50}, {50, 50}, {50, 50}}, 40, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeUploadedImages(testUploads(t, tt.sizes...), tt.width, tt.height)
+			if !errorIsType(err, tt.wantErr) {
+				t.Fatalf("decodeUploadedImages() error = %v, want %T", err, tt.wantErr)
+			}
+		})
+	}
+}
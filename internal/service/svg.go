@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+// svgBaseDPI is the resolution at which one SVG user unit is one pixel
+const svgBaseDPI = 96
+
+const svgNamespace = "http://www.w3.org/2000/svg"
+
+// svgTargetFormats are the formats an SVG can be rendered to
+var svgTargetFormats = []string{"pdf", "jpg", "png", "webp", "gif", "avif"}
+
+// svgSupportedElements are the SVG elements the renderer draws; anything else would silently go missing
+var svgSupportedElements = map[string]bool{
+	"svg": true, "g": true, "line": true, "rect": true, "circle": true, "ellipse": true,
+	"polyline": true, "polygon": true, "path": true, "use": true, "defs": true, "style": true,
+	"linearGradient": true, "radialGradient": true, "stop": true, "title": true, "desc": true,
+	"metadata": true,
+}
+
+// svgUnsupportedAttributes are the presentation attributes the renderer ignores although they change the drawing,
+// mapped to the values that leave the drawing unchanged
+var svgUnsupportedAttributes = map[string][]string{
+	"clip-path": {"none"}, "mask": {"none"}, "filter": {"none"},
+	"marker-start": {"none"}, "marker-mid": {"none"}, "marker-end": {"none"},
+	"display": {"inline", "block", "inherit"}, "visibility": {"visible", "inherit"},
+}
+
+// ConvertSVG rasterizes an SVG at the requested size or DPI and encodes it as an image or places it in a PDF
+func ConvertSVG(file io.Reader, targetFormat string, opts model.ConvertOptions) ([]byte, error) {
+	if err := checkQuantizeFormat(targetFormat, opts); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SVG: %w", err)
+	}
+
+	img, err := rasterizeSVG(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch targetFormat {
+	case "pdf":
+		return imageToPDF(img, "image.svg")
+	case "jpg":
+		// JPEG has no alpha channel, so render transparent areas as white instead of black
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, image.Point{}, draw.Over)
+		img = flat
+	}
+
+	if opts.Colors > 0 || opts.Monochrome {
+		img = quantizeImage(img, opts)
+	}
+	return encodeImage(img, targetFormat)
+}
+
+// rasterizeSVG renders an SVG document into an RGBA image
+func rasterizeSVG(data []byte, opts model.ConvertOptions) (image.Image, error) {
+	if err := checkSVGElements(data); err != nil {
+		return nil, err
+	}
+
+	// The strict error mode would also reject the metadata and editor elements checkSVGElements lets through
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.IgnoreErrorMode)
+	if err != nil {
+		return nil, &utils.InvalidInputError{Msg: "invalid SVG: " + err.Error()}
+	}
+	if !(icon.ViewBox.W > 0 && icon.ViewBox.H > 0) {
+		return nil, &utils.InvalidInputError{Msg: "SVG has no size, it needs a viewBox or width and height"}
+	}
+
+	width, height := svgRasterSize(icon.ViewBox.W, icon.ViewBox.H, opts)
+	if err := checkImagePixels(width, height); err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	icon.SetTarget(0, 0, float64(width), float64(height))
+	scanner := rasterx.NewScannerGV(width, height, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(width, height, scanner), 1)
+	return img, nil
+}
+
+// svgRasterSize returns the output size in pixels. An explicit width or height wins, keeping the aspect
+// ratio when only one is given; otherwise the SVG size is scaled by the DPI. Sizes are clamped to just above the
+// pixel limit before the conversion to int, so huge sizes fail the limit check instead of overflowing.
+func svgRasterSize(viewWidth, viewHeight float64, opts model.ConvertOptions) (int, int) {
+	width, height := float64(opts.Width), float64(opts.Height)
+	switch {
+	case width > 0 && height > 0:
+	case width > 0:
+		height = viewHeight * width / viewWidth
+	case height > 0:
+		width = viewWidth * height / viewHeight
+	default:
+		dpi := opts.DPI
+		if dpi == 0 {
+			dpi = svgBaseDPI
+		}
+		scale := float64(dpi) / svgBaseDPI
+		width, height = viewWidth*scale, viewHeight*scale
+	}
+	largest := float64(config.AppLimits.MaxImagePixels) + 1
+	return max(1, int(min(math.Round(width), largest))), max(1, int(min(math.Round(height), largest)))
+}
+
+// checkSVGElements reports SVG elements and attributes the renderer does not support, such as text, images,
+// filters or clipping. Elements of other namespaces (editor data) and the contents of metadata are ignored.
+func checkSVGElements(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	unsupported := make(map[string]bool)
+	unsupportedAttrs := make(map[string]bool)
+	metadataDepth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return &utils.InvalidInputError{Msg: "invalid SVG: " + err.Error()}
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if metadataDepth > 0 || t.Name.Local == "metadata" {
+				metadataDepth++
+				continue
+			}
+			if t.Name.Space != svgNamespace && t.Name.Space != "" {
+				continue
+			}
+			if !svgSupportedElements[t.Name.Local] {
+				unsupported[t.Name.Local] = true
+			}
+			for _, attr := range t.Attr {
+				if attr.Name.Space != "" {
+					continue
+				}
+				if attr.Name.Local == "style" {
+					// Inline styles hold the same properties as declarations
+					for _, declaration := range strings.Split(attr.Value, ";") {
+						if name, value, ok := strings.Cut(declaration, ":"); ok && !svgAttributeSupported(strings.TrimSpace(name), value) {
+							unsupportedAttrs[strings.TrimSpace(name)] = true
+						}
+					}
+				} else if !svgAttributeSupported(attr.Name.Local, attr.Value) {
+					unsupportedAttrs[attr.Name.Local] = true
+				}
+			}
+		case xml.EndElement:
+			if metadataDepth > 0 {
+				metadataDepth--
+			}
+		}
+	}
+
+	var problems []string
+	if len(unsupported) > 0 {
+		problems = append(problems, "unsupported SVG elements: "+sortedNames(unsupported))
+	}
+	if len(unsupportedAttrs) > 0 {
+		problems = append(problems, "unsupported SVG attributes: "+sortedNames(unsupportedAttrs))
+	}
+	if len(problems) > 0 {
+		return &utils.InvalidInputError{Msg: strings.Join(problems, "; ")}
+	}
+	return nil
+}
+
+// svgAttributeSupported reports whether the renderer draws an attribute with the given value as specified
+func svgAttributeSupported(name, value string) bool {
+	harmless, ok := svgUnsupportedAttributes[strings.ToLower(name)]
+	if !ok {
+		return true
+	}
+	return utils.Contains(harmless, strings.ToLower(strings.TrimSpace(value)))
+}
+
+// sortedNames returns the names of a set as a sorted, comma separated list
+func sortedNames(set map[string]bool) string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"synth.com/file_converter/internal/config"
+	"synth.com/file_converter/internal/model"
+	"synth.com/file_converter/internal/utils"
+)
+
+func TestRasterizeSVGSize(t *testing.T) {
+	defer func(limits config.Limits) { config.AppLimits = limits }(config.AppLimits)
+	config.AppLimits.MaxImagePixels = 10000
+
+	tests := []struct {
+		name       string
+		svg        string
+		opts       model.ConvertOptions
+		wantWidth  int
+		wantHeight int
+		wantErr    interface{}
+	}{
+		{"view box", `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 20"/>`, model.ConvertOptions{}, 40, 20, nil},
+		{"width keeps aspect ratio", `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 20"/>`, model.ConvertOptions{Width: 80}, 80, 40, nil},
+		{"dpi", `<svg xmlns="http://www.w3.org/2000/svg" width="40" height="20"/>`, model.ConvertOptions{DPI: 192}, 80, 40, nil},
+		{"no size", `<svg xmlns="http://www.w3.org/2000/svg"/>`, model.ConvertOptions{}, 0, 0, &utils.InvalidInputError{}},
+		{"over limit", `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 200 200"/>`, model.ConvertOptions{}, 0, 0, &utils.LimitExceededError{}},
+		// 2^32 squared wraps a 64-bit pixel count to zero
+		{"huge view box", `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 4294967296 4294967296"/>`, model.ConvertOptions{}, 0, 0, &utils.LimitExceededError{}},
+		{"huge width and height", `<svg xmlns="http://www.w3.org/2000/svg" width="4294967296" height="4294967296"/>`, model.ConvertOptions{}, 0, 0, &utils.LimitExceededError{}},
+		{"huge requested width", `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 20"/>`, model.ConvertOptions{Width: 1 << 40}, 0, 0, &utils.LimitExceededError{}},
+		{"huge dpi", `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1e300 1e300"/>`, model.ConvertOptions{DPI: 2400}, 0, 0, &utils.LimitExceededError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := rasterizeSVG([]byte(tt.svg), tt.opts)
+			if !errorIsType(err, tt.wantErr) {
+				t.Fatalf("rasterizeSVG error = %v, want %T", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if b := img.Bounds(); b.Dx() != tt.wantWidth || b.Dy() != tt.wantHeight {
+				t.Errorf("image size = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestCheckSVGElements(t *testing.T) {
+	tests := []struct {
+		name    string
+		svg     string
+		wantErr interface{}
+	}{
+		{"supported", `<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1" fill="red" filter="none" style="stroke:blue; display:inline"/></svg>`, nil},
+		{"editor data", `<svg xmlns="http://www.w3.org/2000/svg" xmlns:i="http://www.inkscape.org/namespaces/inkscape"><i:namedview/><metadata><rdf/></metadata></svg>`, nil},
+		{"text element", `<svg xmlns="http://www.w3.org/2000/svg"><text>Hi</text></svg>`, &utils.InvalidInputError{}},
+		{"clip path attribute", `<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1" clip-path="url(#c)"/></svg>`, &utils.InvalidInputError{}},
+		{"filter in style", `<svg xmlns="http://www.w3.org/2000/svg"><g style="fill:red;filter:url(#blur)"/></svg>`, &utils.InvalidInputError{}},
+		{"hidden element", `<svg xmlns="http://www.w3.org/2000/svg"><rect width="1" height="1" display="none"/></svg>`, &utils.InvalidInputError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkSVGElements([]byte(tt.svg)); !errorIsType(err, tt.wantErr) {
+				t.Errorf("checkSVGElements = %v, want %T", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConvertSVGTargetFormats(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 4 4"><rect width="2" height="2"/></svg>`
+	for _, format := range []string{"txt", "csv", "dzi", "frames"} {
+		if resp := ConvertFile(strings.NewReader(svg), "a.svg", format, model.ConvertOptions{}); resp.Code != 400 {
+			t.Errorf("converting an SVG to %s: code = %d, want 400", format, resp.Code)
+		}
+	}
+	if resp := ConvertFile(strings.NewReader(svg), "a.svg", "png", model.ConvertOptions{}); resp.Status != "success" {
+		t.Errorf("converting an SVG to png failed: %s", resp.Message)
+	}
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"os"
+
+	"synth.com/file_converter/internal/model"
+)
+
+// Tile pyramid defaults, matching the Deep Zoom and slippy map conventions
+const (
+	defaultDZITileSize = 254
+	defaultDZIOverlap  = 1
+	defaultXYZTileSize = 256
+	tilePyramidName    = "image"
+)
+
+// xyzDescriptor describes an XYZ tile pyramid for viewers that need the source dimensions
+type xyzDescriptor struct {
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	TileSize int    `json:"tile_size"`
+	MinZoom  int    `json:"min_zoom"`
+	MaxZoom  int    `json:"max_zoom"`
+	Format   string `json:"format"`
+	URL      string `json:"url"`
+}
+
+// ConvertImageToTilePyramid builds a multi-resolution tile pyramid from an image and writes it as a ZIP to a
+// temporary file, either as a Deep Zoom image (.dzi descriptor and _files folder) or as XYZ z/x/y tiles. It returns
+// the path of the file, which the caller removes once it has been sent.
+// Levels are built from the full resolution downwards, each by halving the previous one, and tiles are encoded
+// into the archive as they are cut, so the encoded tiles never pile up in memory. At its peak a request holds the
+// decoded image and the next level at a quarter of its pixels, which the pixel limit on the source bounds.
+func ConvertImageToTilePyramid(file io.Reader, opts model.ConvertOptions) (string, error) {
+	img, _, err := decodeImage(file)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := os.CreateTemp("", "tiles-*.zip")
+	if err != nil {
+		return "", fmt.Errorf("failed to create tile archive: %w", err)
+	}
+	err = writeTilePyramid(out, img, opts)
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write tile archive: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// writeTilePyramid writes the ZIP of the tile pyramid of a decoded image
+func writeTilePyramid(w io.Writer, img image.Image, opts model.ConvertOptions) error {
+	tileFormat := opts.TileFormat
+	if tileFormat == "" {
+		tileFormat = "jpg"
+	}
+
+	zw := zip.NewWriter(w)
+	var err error
+	if opts.TileLayout == "xyz" {
+		err = writeXYZPyramid(zw, img, opts, tileFormat)
+	} else {
+		err = writeDZIPyramid(zw, img, opts, tileFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write ZIP: %w", err)
+	}
+	return nil
+}
+
+// writeDZIPyramid writes a Deep Zoom pyramid, where level 0 is 1x1 pixel and the highest level is the full image
+func writeDZIPyramid(zw *zip.Writer, img image.Image, opts model.ConvertOptions, tileFormat string) error {
+	tileSize := opts.TileSize
+	if tileSize == 0 {
+		tileSize = defaultDZITileSize
+	}
+	overlap := defaultDZIOverlap
+	if opts.TileOverlap != nil {
+		overlap = *opts.TileOverlap
+	}
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	maxLevel := int(math.Ceil(math.Log2(float64(max(width, height)))))
+
+	descriptor := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Image xmlns="http://schemas.microsoft.com/deepzoom/2008" TileSize="%d" Overlap="%d" Format="%s">
+  <Size Width="%d" Height="%d"/>
+</Image>
+`, tileSize, overlap, tileFormat, width, height)
+	if err := writeZipEntry(zw, tilePyramidName+".dzi", []byte(descriptor)); err != nil {
+		return err
+	}
+
+	level := img
+	for l := maxLevel; l >= 0; l-- {
+		if l < maxLevel {
+			level = halveImage(level)
+		}
+
+		b := level.Bounds()
+		for row := 0; row*tileSize < b.Dy(); row++ {
+			for col := 0; col*tileSize < b.Dx(); col++ {
+				// Tiles share `overlap` pixels with each of their neighbours
+				rect := image.Rect(col*tileSize-overlap, row*tileSize-overlap, (col+1)*tileSize+overlap, (row+1)*tileSize+overlap).
+					Add(b.Min).Intersect(b)
+				name := fmt.Sprintf("%s_files/%d/%d_%d.%s", tilePyramidName, l, col, row, tileFormat)
+				if err := writeTile(zw, name, subImage(level, rect), tileFormat); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeXYZPyramid writes z/x/y tiles, where zoom 0 fits the whole image into a single tile
+func writeXYZPyramid(zw *zip.Writer, img image.Image, opts model.ConvertOptions, tileFormat string) error {
+	tileSize := opts.TileSize
+	if tileSize == 0 {
+		tileSize = defaultXYZTileSize
+	}
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	maxZoom := max(0, int(math.Ceil(math.Log2(float64(max(width, height))/float64(tileSize)))))
+
+	descriptor, err := json.MarshalIndent(xyzDescriptor{
+		Width:    width,
+		Height:   height,
+		TileSize: tileSize,
+		MinZoom:  0,
+		MaxZoom:  maxZoom,
+		Format:   tileFormat,
+		URL:      "{z}/{x}/{y}." + tileFormat,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to write tile descriptor: %w", err)
+	}
+	if err := writeZipEntry(zw, "tiles.json", descriptor); err != nil {
+		return err
+	}
+
+	level := img
+	for z := maxZoom; z >= 0; z-- {
+		if z < maxZoom {
+			level = halveImage(level)
+		}
+
+		b := level.Bounds()
+		for y := 0; y*tileSize < b.Dy(); y++ {
+			for x := 0; x*tileSize < b.Dx(); x++ {
+				rect := image.Rect(x*tileSize, y*tileSize, (x+1)*tileSize, (y+1)*tileSize).Add(b.Min)
+				tile := subImage(level, rect.Intersect(b))
+
+				// Slippy map viewers expect full tiles, so edge tiles are padded
+				if tile.Bounds().Dx() < tileSize || tile.Bounds().Dy() < tileSize {
+					padded := image.NewNRGBA(image.Rect(0, 0, tileSize, tileSize))
+					if tileFormat == "jpg" {
+						draw.Draw(padded, padded.Bounds(), image.White, image.Point{}, draw.Src)
+					}
+					draw.Draw(padded, tile.Bounds().Sub(tile.Bounds().Min), tile, tile.Bounds().Min, draw.Over)
+					tile = padded
+				}
+
+				name := fmt.Sprintf("%d/%d/%d.%s", z, x, y, tileFormat)
+				if err := writeTile(zw, name, tile, tileFormat); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// halveImage scales a pyramid level down to half its size, rounding up, by averaging blocks of 2x2 pixels. The level
+// is read in strips of two rows, so apart from the result only one strip is held at a time.
+func halveImage(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, (b.Dx()+1)/2, (b.Dy()+1)/2))
+	strip := image.NewRGBA(image.Rect(0, 0, b.Dx(), 2))
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		// The last strip of an odd height has a single row, as the last block of an odd width has a single column
+		rows := min(2, b.Dy()-2*y)
+		draw.Draw(strip, image.Rect(0, 0, b.Dx(), rows), img, image.Pt(b.Min.X, b.Min.Y+2*y), draw.Src)
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			cols := min(2, b.Dx()-2*x)
+			var sum [4]int
+			for dy := 0; dy < rows; dy++ {
+				for dx := 0; dx < cols; dx++ {
+					i := strip.PixOffset(2*x+dx, dy)
+					for c := range sum {
+						sum[c] += int(strip.Pix[i+c])
+					}
+				}
+			}
+			n := rows * cols
+			j := dst.PixOffset(x, y)
+			for c := range sum {
+				dst.Pix[j+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+// subImage returns the part of an image within rect, without copying when the image supports it
+func subImage(img image.Image, rect image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+
+	dst := image.NewNRGBA(rect)
+	draw.Draw(dst, rect, img, rect.Min, draw.Src)
+	return dst
+}
+
+// writeTile encodes a tile and adds it to the archive
+func writeTile(zw *zip.Writer, name string, tile image.Image, tileFormat string) error {
+	data, err := encodeImage(tile, tileFormat)
+	if err != nil {
+		return err
+	}
+	return writeZipEntry(zw, name, data)
+}
+
+// writeZipEntry adds a single file to an archive that is being written
+func writeZipEntry(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to ZIP: %w", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to ZIP: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"synth.com/file_converter/internal/model"
+)
+
+// readTileArchive returns the size of every image in a tile archive, keyed by entry name, and the other entries
+func readTileArchive(t *testing.T, data []byte) (map[string]image.Point, map[string][]byte) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiles, others := map[string]image.Point{}, map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(content)); err == nil {
+			tiles[f.Name] = image.Pt(cfg.Width, cfg.Height)
+		} else {
+			others[f.Name] = content
+		}
+	}
+	return tiles, others
+}
+
+// testColorGradientSized returns an opaque image of the given size whose pixels run through many colours
+func testColorGradientSized(width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x + y), A: 255})
+		}
+	}
+	return img
+}
+
+// sortedTileNames returns the names of the tiles in order
+func sortedTileNames(tiles map[string]image.Point) []string {
+	names := make([]string, 0, len(tiles))
+	for name := range tiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestHalveImage(t *testing.T) {
+	tests := []struct {
+		width, height int
+		want          image.Point
+	}{
+		{300, 200, image.Pt(150, 100)},
+		{5, 3, image.Pt(3, 2)},
+		{1, 1, image.Pt(1, 1)},
+		{1, 4, image.Pt(1, 2)},
+	}
+	for _, tt := range tests {
+		img := image.NewNRGBA(image.Rect(10, 10, 10+tt.width, 10+tt.height))
+		if got := halveImage(img).Bounds().Size(); got != tt.want {
+			t.Errorf("halving %dx%d gives %v, want %v", tt.width, tt.height, got, tt.want)
+		}
+	}
+
+	// Blocks are averaged, and a last column of an odd width only averages its own pixels
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(img.Pix, []uint8{0, 255, 100, 255, 0, 200})
+	half := halveImage(img)
+	for x, want := range []uint8{128, 150} {
+		if got := color.GrayModel.Convert(half.At(x, 0)).(color.Gray).Y; got != want {
+			t.Errorf("pixel %d = %d, want %d", x, got, want)
+		}
+	}
+}
+
+func TestWriteTilePyramidDZI(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTilePyramid(&buf, testColorGradientSized(300, 200), model.ConvertOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	tiles, others := readTileArchive(t, buf.Bytes())
+	if !strings.Contains(string(others["image.dzi"]), `<Size Width="300" Height="200"/>`) {
+		t.Errorf("descriptor %q lacks the image size", others["image.dzi"])
+	}
+
+	// The full image is level 9, as 2^9 is the first power of two covering 300 pixels; tiles overlap by a pixel
+	want := map[string]image.Point{
+		"image_files/9/0_0.jpg": image.Pt(255, 200),
+		"image_files/9/1_0.jpg": image.Pt(47, 200),
+		"image_files/8/0_0.jpg": image.Pt(150, 100),
+		"image_files/1/0_0.jpg": image.Pt(2, 1),
+		"image_files/0/0_0.jpg": image.Pt(1, 1),
+	}
+	for name, size := range want {
+		if tiles[name] != size {
+			t.Errorf("tile %s is %v, want %v", name, tiles[name], size)
+		}
+	}
+	// Levels 0 to 8 fit a tile each, level 9 takes two
+	if len(tiles) != 11 {
+		t.Errorf("got %d tiles, want 11: %v", len(tiles), sortedTileNames(tiles))
+	}
+}
+
+func TestWriteTilePyramidXYZ(t *testing.T) {
+	var buf bytes.Buffer
+	opts := model.ConvertOptions{TileLayout: "xyz", TileFormat: "png"}
+	if err := writeTilePyramid(&buf, testColorGradientSized(300, 200), opts); err != nil {
+		t.Fatal(err)
+	}
+	tiles, others := readTileArchive(t, buf.Bytes())
+
+	var descriptor xyzDescriptor
+	if err := json.Unmarshal(others["tiles.json"], &descriptor); err != nil {
+		t.Fatal(err)
+	}
+	if descriptor.Width != 300 || descriptor.Height != 200 || descriptor.MaxZoom != 1 || descriptor.URL != "{z}/{x}/{y}.png" {
+		t.Errorf("descriptor = %+v", descriptor)
+	}
+
+	// Zoom 1 is the full image over two tiles, zoom 0 the half size image in one, all padded to full tiles
+	if got := strings.Join(sortedTileNames(tiles), ","); got != "0/0/0.png,1/0/0.png,1/1/0.png" {
+		t.Errorf("tiles = %s", got)
+	}
+	for name, size := range tiles {
+		if size != image.Pt(256, 256) {
+			t.Errorf("tile %s is %v, want 256x256", name, size)
+		}
+	}
+}
+
+func TestConvertImageToTilePyramid(t *testing.T) {
+	data, err := encodeImage(testColorGradientSized(40, 30), "png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := ConvertImageToTilePyramid(bytes.NewReader(data), model.ConvertOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	archive, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tiles, _ := readTileArchive(t, archive); tiles["image_files/6/0_0.jpg"] != image.Pt(40, 30) {
+		t.Errorf("tiles = %v", tiles)
+	}
+
+	if path, err := ConvertImageToTilePyramid(strings.NewReader("not an image"), model.ConvertOptions{}); err == nil {
+		os.Remove(path)
+		t.Error("a file that is not an image was tiled")
+	}
+}
@@ -1,6 +1,10 @@
 package utils
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
 
 // Custom error for file conversion
 type FileConversionError struct {
@@ -10,3 +14,42 @@ type FileConversionError struct {
 func (e *FileConversionError) Error() string {
 	return fmt.Sprintf("File Conversion Error: %s", e.Msg)
 }
+
+// LimitExceededError reports an input whose content exceeds the configured limits
+type LimitExceededError struct {
+	Msg string
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("Limit Exceeded: %s", e.Msg)
+}
+
+// InvalidInputError reports an input or option that cannot be processed as given
+type InvalidInputError struct {
+	Msg string
+}
+
+func (e *InvalidInputError) Error() string {
+	return fmt.Sprintf("Invalid Input: %s", e.Msg)
+}
+
+// CorruptFileError reports an upload that is damaged or not of the format its name claims
+type CorruptFileError struct {
+	Format string
+	Msg    string
+}
+
+func (e *CorruptFileError) Error() string {
+	return fmt.Sprintf("Corrupt %s: %s", e.Format, e.Msg)
+}
+
+// StatusCodeForError maps an error to the HTTP status code it should be reported with
+func StatusCodeForError(err error) int {
+	var limitErr *LimitExceededError
+	var inputErr *InvalidInputError
+	var corruptErr *CorruptFileError
+	if errors.As(err, &limitErr) || errors.As(err, &inputErr) || errors.As(err, &corruptErr) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
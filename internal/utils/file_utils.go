@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"archive/zip"
 	"bytes"
 	"fmt"
 	"image"
+	"image/draw"
 	"image/png"
 	"io/ioutil"
 	"os"
@@ -30,6 +32,14 @@ func SaveImageToTempFile(img image.Image, filename string) (*os.File, error) {
 		return nil, err
 	}
 
+	// Store 8 bits per channel; decoders such as HEIC return images that PNG would write with 16 bits,
+	// which the PDF generator cannot embed
+	if _, ok := img.(*image.NRGBA); !ok {
+		nrgba := image.NewNRGBA(img.Bounds())
+		draw.Draw(nrgba, nrgba.Bounds(), img, img.Bounds().Min, draw.Src)
+		img = nrgba
+	}
+
 	err = png.Encode(tmpFile, img)
 	if err != nil {
 		tmpFile.Close()
@@ -61,7 +71,9 @@ func GeneratePDFFromImage(imageFile *os.File) ([]byte, error) {
 
 	// Convert the image to fit within the A4 page dimensions
 	// Resize the image if necessary to fit within the page
-	pdf.ImageFrom(img, 0, 0, nil)
+	if err := pdf.ImageFrom(img, 0, 0, nil); err != nil {
+		return nil, fmt.Errorf("failed to add image to PDF: %w", err)
+	}
 
 	// Write PDF to buffer
 	err = pdf.Write(&buf)
@@ -71,3 +83,28 @@ func GeneratePDFFromImage(imageFile *os.File) ([]byte, error) {
 
 	return buf.Bytes(), nil
 }
+
+// ZipEntry is a single file to be written into a ZIP archive
+type ZipEntry struct {
+	Name string
+	Data []byte
+}
+
+// CreateZip packages the given entries into a ZIP archive
+func CreateZip(entries []ZipEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		w, err := zw.Create(entry.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to ZIP: %w", entry.Name, err)
+		}
+		if _, err := w.Write(entry.Data); err != nil {
+			return nil, fmt.Errorf("failed to write %s to ZIP: %w", entry.Name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write ZIP: %w", err)
+	}
+	return buf.Bytes(), nil
+}